@@ -0,0 +1,149 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"sync"
+)
+
+const (
+	// BusQueueLen is the number of published messages that can be
+	// waiting for the dispatcher before new messages are dropped
+	BusQueueLen = 64
+
+	// BusBufLen is the number of messages buffered for each subscriber
+	// before new messages for that subscriber are dropped
+	BusBufLen = 16
+)
+
+type subscription struct {
+	ch   chan *Message
+	addr Address
+	all  bool
+}
+
+// Bus distributes messages received from the Insteon network to any
+// number of subscribers.  Direct messages are delivered to the subscribers
+// of the message's source address.  Broadcast and all-link broadcast
+// messages are delivered to every subscriber.  Publishing never blocks:
+// messages are queued for a dispatcher goroutine and the dispatcher never
+// waits on a slow subscriber.  If either the queue or a subscriber's
+// buffer is full then the message is dropped (for that subscriber)
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[<-chan *Message]*subscription
+	queue       chan *Message
+	closed      bool
+	done        chan struct{}
+}
+
+// NewBus returns a Bus with its dispatcher running.  Close should be called
+// to stop the dispatcher when the bus is no longer needed
+func NewBus() *Bus {
+	bus := &Bus{
+		subscribers: make(map[<-chan *Message]*subscription),
+		queue:       make(chan *Message, BusQueueLen),
+		done:        make(chan struct{}),
+	}
+	go bus.dispatch()
+	return bus
+}
+
+func (bus *Bus) dispatch() {
+	for msg := range bus.queue {
+		bus.mu.Lock()
+		for _, sub := range bus.subscribers {
+			if sub.all || sub.addr == msg.Src || msg.Broadcast() {
+				select {
+				case sub.ch <- msg:
+				default:
+					Log.Infof("Subscriber for %v is full, dropping %v", sub.addr, msg)
+				}
+			}
+		}
+		bus.mu.Unlock()
+	}
+
+	bus.mu.Lock()
+	for ch, sub := range bus.subscribers {
+		close(sub.ch)
+		delete(bus.subscribers, ch)
+	}
+	bus.mu.Unlock()
+	close(bus.done)
+}
+
+// Publish queues the message for delivery to subscribers.  The return
+// value indicates whether the message was queued
+func (bus *Bus) Publish(msg *Message) bool {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.closed {
+		return false
+	}
+
+	select {
+	case bus.queue <- msg:
+		return true
+	default:
+		Log.Infof("Message bus is full, dropping %v", msg)
+	}
+	return false
+}
+
+func (bus *Bus) subscribe(sub *subscription) <-chan *Message {
+	sub.ch = make(chan *Message, BusBufLen)
+	bus.mu.Lock()
+	if bus.closed {
+		close(sub.ch)
+	} else {
+		bus.subscribers[sub.ch] = sub
+	}
+	bus.mu.Unlock()
+	return sub.ch
+}
+
+// Subscribe returns a channel that receives messages sent by the device
+// with the given address as well as all broadcast messages
+func (bus *Bus) Subscribe(addr Address) <-chan *Message {
+	return bus.subscribe(&subscription{addr: addr})
+}
+
+// SubscribeAll returns a channel that receives every message
+func (bus *Bus) SubscribeAll() <-chan *Message {
+	return bus.subscribe(&subscription{all: true})
+}
+
+// Unsubscribe stops delivery to, and closes, the given channel
+func (bus *Bus) Unsubscribe(ch <-chan *Message) {
+	bus.mu.Lock()
+	if sub, found := bus.subscribers[ch]; found {
+		close(sub.ch)
+		delete(bus.subscribers, ch)
+	}
+	bus.mu.Unlock()
+}
+
+// Close stops the dispatcher once all queued messages have been delivered
+// and closes every subscriber channel
+func (bus *Bus) Close() {
+	bus.mu.Lock()
+	if !bus.closed {
+		bus.closed = true
+		close(bus.queue)
+	}
+	bus.mu.Unlock()
+	<-bus.done
+}
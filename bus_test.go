@@ -0,0 +1,127 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"testing"
+	"time"
+)
+
+func receiveAll(ch <-chan *Message) (msgs []*Message) {
+	for {
+		select {
+		case msg := <-ch:
+			msgs = append(msgs, msg)
+		case <-time.After(10 * time.Millisecond):
+			return msgs
+		}
+	}
+}
+
+func TestBusRouting(t *testing.T) {
+	addr1 := Address{1, 2, 3}
+	addr2 := Address{4, 5, 6}
+	direct1 := &Message{Src: addr1, Flags: StandardDirectAck}
+	direct2 := &Message{Src: addr2, Flags: StandardDirectAck}
+	broadcast := &Message{Src: addr2, Flags: StandardBroadcast}
+	allLink := &Message{Src: Address{7, 8, 9}, Flags: StandardAllLinkBroadcast}
+
+	bus := NewBus()
+	defer bus.Close()
+	ch1 := bus.Subscribe(addr1)
+	ch2 := bus.Subscribe(addr2)
+	all := bus.SubscribeAll()
+
+	for _, msg := range []*Message{direct1, direct2, broadcast, allLink} {
+		if !bus.Publish(msg) {
+			t.Fatalf("failed to publish %v", msg)
+		}
+	}
+
+	tests := []struct {
+		desc string
+		ch   <-chan *Message
+		want []*Message
+	}{
+		{"address 1", ch1, []*Message{direct1, broadcast, allLink}},
+		{"address 2", ch2, []*Message{direct2, broadcast, allLink}},
+		{"all", all, []*Message{direct1, direct2, broadcast, allLink}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := receiveAll(test.ch)
+			if len(got) != len(test.want) {
+				t.Fatalf("want %d messages got %d", len(test.want), len(got))
+			}
+
+			for i, msg := range got {
+				if msg != test.want[i] {
+					t.Errorf("want message %d to be %v got %v", i, test.want[i], msg)
+				}
+			}
+		})
+	}
+}
+
+func TestBusSlowSubscriber(t *testing.T) {
+	addr := Address{1, 2, 3}
+	bus := NewBus()
+	defer bus.Close()
+	slow := bus.Subscribe(addr)
+
+	// publishing must never block, even when the subscriber
+	// is not reading
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < BusQueueLen+BusBufLen+10; i++ {
+			bus.Publish(&Message{Src: addr, Flags: StandardDirectAck})
+		}
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Publish blocked")
+	}
+
+	if got := len(receiveAll(slow)); got > BusBufLen+BusQueueLen || got < BusBufLen {
+		t.Errorf("want between %d and %d buffered messages got %d", BusBufLen, BusBufLen+BusQueueLen, got)
+	}
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	bus := NewBus()
+	ch := bus.SubscribeAll()
+	bus.Unsubscribe(ch)
+	if _, open := <-ch; open {
+		t.Errorf("expected channel to be closed")
+	}
+
+	ch = bus.SubscribeAll()
+	bus.Close()
+	if _, open := <-ch; open {
+		t.Errorf("expected channel to be closed")
+	}
+
+	if bus.Publish(&Message{}) {
+		t.Errorf("expected Publish to fail after Close")
+	}
+
+	if _, open := <-bus.SubscribeAll(); open {
+		t.Errorf("expected channel to be closed")
+	}
+}
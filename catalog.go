@@ -0,0 +1,406 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Capability is a set of flags describing the features of a product
+type Capability int
+
+// Product capabilities
+const (
+	// Dimmable products can be set to levels other than on and off
+	Dimmable Capability = 1 << iota
+
+	// BatteryPowered products sleep most of the time and usually only
+	// respond to commands shortly after they have sent a message
+	BatteryPowered
+
+	// MultiButton products (such as keypads and remotes) have more than
+	// one button and control a group for each button
+	MultiButton
+
+	// I2Cs products use the I2CS engine and must be linked to the modem
+	// before they will respond to most commands
+	I2Cs
+
+	// RFOnly products only communicate over RF and not over the powerline
+	RFOnly
+)
+
+var capabilityNames = []struct {
+	capability Capability
+	name       string
+}{
+	{Dimmable, "dimmable"},
+	{BatteryPowered, "battery"},
+	{MultiButton, "multibutton"},
+	{I2Cs, "i2cs"},
+	{RFOnly, "rfonly"},
+}
+
+// Has returns true if all of the given capabilities are set
+func (c Capability) Has(capability Capability) bool {
+	return c&capability == capability
+}
+
+func (c Capability) names() []string {
+	names := []string{}
+	for _, cn := range capabilityNames {
+		if c.Has(cn.capability) {
+			names = append(names, cn.name)
+		}
+	}
+	return names
+}
+
+// String returns a comma separated list of the capability names
+func (c Capability) String() string {
+	return strings.Join(c.names(), ",")
+}
+
+// MarshalJSON will convert the capabilities to a JSON list of names
+func (c Capability) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.names())
+}
+
+// UnmarshalJSON will populate the capabilities from a JSON list of names
+func (c *Capability) UnmarshalJSON(data []byte) error {
+	var names []string
+	err := json.Unmarshal(data, &names)
+	if err != nil {
+		return err
+	}
+
+	*c = 0
+	for _, name := range names {
+		found := false
+		for _, cn := range capabilityNames {
+			if cn.name == name {
+				*c |= cn.capability
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("unknown capability %q", name)
+		}
+	}
+	return nil
+}
+
+var categoryClasses = map[Category]string{
+	0x00: "Generalized Controllers",
+	0x01: "Dimmable Lighting Control",
+	0x02: "Switched Lighting Control",
+	0x03: "Network Bridges",
+	0x04: "Irrigation Control",
+	0x05: "Climate Control",
+	0x06: "Pool and Spa Control",
+	0x07: "Sensors and Actuators",
+	0x08: "Home Entertainment",
+	0x09: "Energy Management",
+	0x0a: "Built-In Appliance Control",
+	0x0b: "Plumbing",
+	0x0c: "Communication",
+	0x0d: "Computer Control",
+	0x0e: "Window Coverings",
+	0x0f: "Access Control",
+	0x10: "Security, Health, Safety",
+	0x11: "Surveillance",
+	0x12: "Automotive",
+	0x13: "Pet Care",
+	0x14: "Toys",
+	0x15: "Timekeeping",
+	0x16: "Holiday",
+}
+
+// Class returns the name of the device class for the category, such
+// as "Dimmable Lighting Control"
+func (c Category) Class() string {
+	if class, found := categoryClasses[c]; found {
+		return class
+	}
+	return sprintf("Unknown Category %02x", byte(c))
+}
+
+// Product describes a specific Insteon product
+type Product struct {
+	DevCat       DevCat
+	Model        string
+	Name         string
+	Capabilities Capability
+}
+
+// Class returns the device class of the product (determined by the
+// product's category)
+func (p Product) Class() string {
+	return p.DevCat.Category().Class()
+}
+
+// String returns the model number and name of the product
+func (p Product) String() string {
+	return sprintf("%s %s", p.Model, p.Name)
+}
+
+// ProductCatalog maps device categories to the products they identify.
+// ProductCatalog is safe to use from multiple goroutines
+type ProductCatalog struct {
+	mutex    sync.RWMutex
+	products map[DevCat]Product
+}
+
+// Products is the global catalog of known Insteon products.  It is populated
+// with the published Insteon device categories and can be extended using
+// the Add and Load methods
+var Products = &ProductCatalog{}
+
+func init() {
+	Products.Add(builtinProducts...)
+}
+
+// Add puts the products in the catalog, replacing any existing products
+// with the same device category
+func (pc *ProductCatalog) Add(products ...Product) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	if pc.products == nil {
+		pc.products = make(map[DevCat]Product)
+	}
+
+	for _, product := range products {
+		pc.products[product.DevCat] = product
+	}
+}
+
+// Find returns the product for the given device category
+func (pc *ProductCatalog) Find(devCat DevCat) (product Product, found bool) {
+	pc.mutex.RLock()
+	product, found = pc.products[devCat]
+	pc.mutex.RUnlock()
+	return product, found
+}
+
+// Describe returns the product's model and name if the device category is
+// in the catalog, otherwise the device class and raw device category are
+// returned
+func (pc *ProductCatalog) Describe(devCat DevCat) string {
+	if product, found := pc.Find(devCat); found {
+		return product.String()
+	}
+	return sprintf("%s (%s)", devCat.Category().Class(), devCat)
+}
+
+// Load reads a JSON list of products from r and adds them to the catalog.
+// Each product is an object of the form:
+//
+//	{"DevCat": "01.20", "Model": "2477D", "Name": "SwitchLinc Dimmer (Dual-Band)", "Capabilities": ["dimmable"]}
+//
+// Products in the list replace any products already in the catalog with
+// the same device category
+func (pc *ProductCatalog) Load(r io.Reader) error {
+	var products []Product
+	err := json.NewDecoder(r).Decode(&products)
+	if err == nil {
+		pc.Add(products...)
+	}
+	return err
+}
+
+var builtinProducts = []Product{
+	// Generalized Controllers
+	{DevCat{0x00, 0x04}, "2430", "ControLinc", MultiButton},
+	{DevCat{0x00, 0x05}, "2440", "RemoteLinc", MultiButton | BatteryPowered | RFOnly},
+	{DevCat{0x00, 0x06}, "2830", "Icon Tabletop Controller", MultiButton},
+	{DevCat{0x00, 0x09}, "2442", "SignaLinc RF Signal Enhancer", 0},
+	{DevCat{0x00, 0x0b}, "2443", "Access Point (Wireless Phase Coupler)", 0},
+	{DevCat{0x00, 0x10}, "2444A2xx4", "RemoteLinc 2 Keypad, 4 Scene", MultiButton | BatteryPowered | RFOnly},
+	{DevCat{0x00, 0x11}, "2444A3xx", "RemoteLinc 2 Switch", BatteryPowered | RFOnly},
+	{DevCat{0x00, 0x12}, "2444A2xx8", "RemoteLinc 2 Keypad, 8 Scene", MultiButton | BatteryPowered | RFOnly},
+	{DevCat{0x00, 0x14}, "2342-432", "Mini Remote - 4 Scene (869 MHz)", MultiButton | BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x00, 0x15}, "2342-442", "Mini Remote - Switch (869 MHz)", BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x00, 0x16}, "2342-422", "Mini Remote - 8 Scene (869 MHz)", MultiButton | BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x00, 0x17}, "2342-532", "Mini Remote - 4 Scene (921 MHz)", MultiButton | BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x00, 0x18}, "2342-542", "Mini Remote - Switch (921 MHz)", BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x00, 0x19}, "2342-522", "Mini Remote - 8 Scene (921 MHz)", MultiButton | BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x00, 0x1a}, "2342-222", "Mini Remote - 8 Scene (915 MHz)", MultiButton | BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x00, 0x1b}, "2342-232", "Mini Remote - 4 Scene (915 MHz)", MultiButton | BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x00, 0x1c}, "2342-242", "Mini Remote - Switch (915 MHz)", BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x00, 0x1d}, "2992-222", "Range Extender", I2Cs | RFOnly},
+
+	// Dimmable Lighting Control
+	{DevCat{0x01, 0x00}, "2456D3", "LampLinc 3-Pin", Dimmable},
+	{DevCat{0x01, 0x01}, "2476D", "SwitchLinc Dimmer", Dimmable},
+	{DevCat{0x01, 0x02}, "2475D", "In-LineLinc Dimmer", Dimmable},
+	{DevCat{0x01, 0x03}, "2876DB", "ICON Dimmer Switch", Dimmable},
+	{DevCat{0x01, 0x04}, "2476DH", "SwitchLinc Dimmer (High Wattage)", Dimmable},
+	{DevCat{0x01, 0x05}, "2484DWH8", "Keypad Countdown Timer w/ Dimmer", Dimmable | MultiButton},
+	{DevCat{0x01, 0x06}, "2456D2", "LampLinc Dimmer (2-Pin)", Dimmable},
+	{DevCat{0x01, 0x07}, "2856D2B", "ICON LampLinc", Dimmable},
+	{DevCat{0x01, 0x09}, "2486D", "KeypadLinc Dimmer", Dimmable | MultiButton},
+	{DevCat{0x01, 0x0a}, "2886D", "Icon In-Wall Controller", Dimmable},
+	{DevCat{0x01, 0x0c}, "2486DWH8", "KeypadLinc Dimmer", Dimmable | MultiButton},
+	{DevCat{0x01, 0x0d}, "2454D", "SocketLinc", Dimmable},
+	{DevCat{0x01, 0x0e}, "2457D2", "LampLinc (Dual-Band)", Dimmable},
+	{DevCat{0x01, 0x13}, "2676D-B", "ICON SwitchLinc Dimmer Lixar/Bell Canada", Dimmable},
+	{DevCat{0x01, 0x17}, "2466D", "ToggleLinc Dimmer", Dimmable},
+	{DevCat{0x01, 0x18}, "2474D", "Icon SwitchLinc Dimmer Inline Companion", Dimmable},
+	{DevCat{0x01, 0x19}, "2476D", "SwitchLinc Dimmer [with beeper]", Dimmable},
+	{DevCat{0x01, 0x1a}, "2475D", "In-LineLinc Dimmer [with beeper]", Dimmable},
+	{DevCat{0x01, 0x1b}, "2486DWH6", "KeypadLinc Dimmer", Dimmable | MultiButton},
+	{DevCat{0x01, 0x1c}, "2486DWH8", "KeypadLinc Dimmer", Dimmable | MultiButton},
+	{DevCat{0x01, 0x1d}, "2476DH", "SwitchLinc Dimmer (High Wattage) [with beeper]", Dimmable},
+	{DevCat{0x01, 0x1e}, "2876DB", "ICON Switch Dimmer", Dimmable},
+	{DevCat{0x01, 0x1f}, "2466Dx", "ToggleLinc Dimmer [with beeper]", Dimmable},
+	{DevCat{0x01, 0x20}, "2477D", "SwitchLinc Dimmer (Dual-Band)", Dimmable},
+	{DevCat{0x01, 0x21}, "2472D", "OutletLinc Dimmer (Dual-Band)", Dimmable},
+	{DevCat{0x01, 0x22}, "2457D2X", "LampLinc", Dimmable},
+	{DevCat{0x01, 0x23}, "2457D2EZ", "LampLinc Dual-Band EZ", Dimmable},
+	{DevCat{0x01, 0x24}, "2474DWH", "SwitchLinc 2-Wire Dimmer (RF)", Dimmable},
+	{DevCat{0x01, 0x25}, "2475DA2", "In-LineLinc 0-10VDC Dimmer/Dual-SwitchDB", Dimmable},
+	{DevCat{0x01, 0x2d}, "2477DH", "SwitchLinc Dimmer (Dual-Band, 1000W)", Dimmable},
+	{DevCat{0x01, 0x2e}, "2475F", "FanLinc", Dimmable | MultiButton},
+	{DevCat{0x01, 0x2f}, "2484DST6", "KeypadLinc Schedule Timer with Dimmer", Dimmable | MultiButton},
+	{DevCat{0x01, 0x30}, "2476D", "SwitchLinc Dimmer", Dimmable},
+	{DevCat{0x01, 0x31}, "2478D", "SwitchLinc Dimmer 240V-50/60Hz Dual-Band", Dimmable},
+	{DevCat{0x01, 0x32}, "2475DA1", "In-LineLinc Dimmer (Dual Band)", Dimmable},
+	{DevCat{0x01, 0x34}, "2452-222", "DIN Rail Dimmer (915 MHz)", Dimmable | I2Cs},
+	{DevCat{0x01, 0x35}, "2442-222", "Micro Dimmer (915 MHz)", Dimmable | I2Cs},
+	{DevCat{0x01, 0x36}, "2452-422", "DIN Rail Dimmer (869 MHz)", Dimmable | I2Cs},
+	{DevCat{0x01, 0x37}, "2452-522", "DIN Rail Dimmer (921 MHz)", Dimmable | I2Cs},
+	{DevCat{0x01, 0x38}, "2442-422", "Micro Dimmer (869 MHz)", Dimmable | I2Cs},
+	{DevCat{0x01, 0x39}, "2442-522", "Micro Dimmer (921 MHz)", Dimmable | I2Cs},
+	{DevCat{0x01, 0x3a}, "2672-222", "LED Bulb 240V (915 MHz)", Dimmable | I2Cs},
+	{DevCat{0x01, 0x3b}, "2672-422", "LED Bulb 240V Edison (869 MHz)", Dimmable | I2Cs},
+	{DevCat{0x01, 0x3c}, "2672-522", "LED Bulb 240V Bayonet (921 MHz)", Dimmable | I2Cs},
+	{DevCat{0x01, 0x41}, "2334-222", "Keypad Dimmer Dual-Band, 8 Button", Dimmable | MultiButton | I2Cs},
+	{DevCat{0x01, 0x42}, "2334-232", "Keypad Dimmer Dual-Band, 6 Button", Dimmable | MultiButton | I2Cs},
+	{DevCat{0x01, 0x49}, "2674-222", "LED Bulb PAR38 (915 MHz)", Dimmable | I2Cs},
+
+	// Switched Lighting Control
+	{DevCat{0x02, 0x05}, "2486SWH8", "KeypadLinc 8-button On/Off Switch", MultiButton},
+	{DevCat{0x02, 0x06}, "2456S3E", "Outdoor ApplianceLinc", 0},
+	{DevCat{0x02, 0x07}, "2456S3T", "TimerLinc", 0},
+	{DevCat{0x02, 0x08}, "2473S", "OutletLinc", 0},
+	{DevCat{0x02, 0x09}, "2456S3", "ApplianceLinc (3-Pin)", 0},
+	{DevCat{0x02, 0x0a}, "2476S", "SwitchLinc Relay", 0},
+	{DevCat{0x02, 0x0b}, "2876S", "ICON On/Off Switch", 0},
+	{DevCat{0x02, 0x0c}, "2856S3", "Icon Appliance Module", 0},
+	{DevCat{0x02, 0x0d}, "2466S", "ToggleLinc Relay", 0},
+	{DevCat{0x02, 0x0e}, "2476ST", "SwitchLinc Relay Countdown Timer", 0},
+	{DevCat{0x02, 0x0f}, "2486SWH6", "KeypadLinc On/Off", MultiButton},
+	{DevCat{0x02, 0x10}, "2475S", "In-LineLinc Relay", 0},
+	{DevCat{0x02, 0x12}, "2474S/D", "ICON In-lineLinc Relay", 0},
+	{DevCat{0x02, 0x13}, "2676R-B", "ICON SwitchLinc Relay Lixar/Bell Canada", 0},
+	{DevCat{0x02, 0x14}, "2475S2", "In-LineLinc Relay with Sense", 0},
+	{DevCat{0x02, 0x15}, "2476SS", "SwitchLinc Relay with Sense", 0},
+	{DevCat{0x02, 0x16}, "2876S", "ICON On/Off Switch (25 max links)", 0},
+	{DevCat{0x02, 0x17}, "2856S3B", "ICON Appliance Module", 0},
+	{DevCat{0x02, 0x18}, "2494S220", "SwitchLinc 220V Relay", 0},
+	{DevCat{0x02, 0x19}, "2494S220", "SwitchLinc 220V Relay [with beeper]", 0},
+	{DevCat{0x02, 0x1a}, "2466Sx", "ToggleLinc Relay [with beeper]", 0},
+	{DevCat{0x02, 0x1c}, "2476S", "SwitchLinc Relay", 0},
+	{DevCat{0x02, 0x1e}, "2487S", "KeypadLinc On/Off (Dual-Band)", MultiButton},
+	{DevCat{0x02, 0x1f}, "2475SDB", "In-LineLinc On/Off (Dual-Band)", 0},
+	{DevCat{0x02, 0x25}, "2484SWH8", "KeypadLinc 8-Button Countdown On/Off Switch Timer", MultiButton},
+	{DevCat{0x02, 0x26}, "2485SWH6", "Keypad Schedule Timer with On/Off Switch", MultiButton},
+	{DevCat{0x02, 0x29}, "2476ST", "SwitchLinc Relay Countdown Timer", 0},
+	{DevCat{0x02, 0x2a}, "2477S", "SwitchLinc Relay (Dual-Band)", 0},
+	{DevCat{0x02, 0x2b}, "2475SDB-50", "In-LineLinc On/Off (Dual Band, 50/60 Hz)", 0},
+	{DevCat{0x02, 0x2c}, "2487S", "KeypadLinc On/Off (Dual-Band, 50/60 Hz)", MultiButton},
+	{DevCat{0x02, 0x2d}, "2633-422", "On/Off Outlet 220V (869 MHz)", I2Cs},
+	{DevCat{0x02, 0x2e}, "2453-222", "DIN Rail On/Off (915 MHz)", I2Cs},
+	{DevCat{0x02, 0x2f}, "2443-222", "Micro On/Off (915 MHz)", I2Cs},
+	{DevCat{0x02, 0x37}, "2635-222", "On/Off Module (915 MHz)", I2Cs},
+	{DevCat{0x02, 0x38}, "2634-222", "On/Off Outdoor Module (Dual-Band)", I2Cs},
+	{DevCat{0x02, 0x39}, "2663-222", "On/Off Outlet", I2Cs},
+
+	// Network Bridges
+	{DevCat{0x03, 0x01}, "2414S", "PowerLinc Serial Controller", 0},
+	{DevCat{0x03, 0x02}, "2414U", "PowerLinc USB Controller", 0},
+	{DevCat{0x03, 0x03}, "2814S", "ICON PowerLinc Serial", 0},
+	{DevCat{0x03, 0x04}, "2814U", "ICON PowerLinc USB", 0},
+	{DevCat{0x03, 0x05}, "2412S", "PowerLinc Serial Modem", 0},
+	{DevCat{0x03, 0x06}, "2411R", "IRLinc Receiver", 0},
+	{DevCat{0x03, 0x07}, "2411T", "IRLinc Transmitter", 0},
+	{DevCat{0x03, 0x09}, "2600RF", "SmartLabs RF Developer's Board", RFOnly},
+	{DevCat{0x03, 0x0a}, "2410S", "SeriaLinc - INSTEON to RS232", 0},
+	{DevCat{0x03, 0x0b}, "2412U", "PowerLinc USB Modem", 0},
+	{DevCat{0x03, 0x10}, "2412N", "SmartLinc", 0},
+	{DevCat{0x03, 0x11}, "2413S", "PowerLinc Serial Modem (Dual Band)", 0},
+	{DevCat{0x03, 0x13}, "2412UH", "PowerLinc USB Modem for HouseLinc", 0},
+	{DevCat{0x03, 0x14}, "2412SH", "PowerLinc Serial Modem for HouseLinc", 0},
+	{DevCat{0x03, 0x15}, "2413U", "PowerLinc USB Modem (Dual Band)", 0},
+	{DevCat{0x03, 0x19}, "2413SH", "PowerLinc Serial Modem (Dual Band) for HouseLinc", 0},
+	{DevCat{0x03, 0x1a}, "2413UH", "PowerLinc USB Modem (Dual Band) for HouseLinc", 0},
+
+	// Irrigation Control
+	{DevCat{0x04, 0x00}, "31270", "EZRain/EZFlora 8-zone Irrigation Controller", 0},
+
+	// Climate Control
+	{DevCat{0x05, 0x00}, "SMSC080", "Broan Exhaust Fan", 0},
+	{DevCat{0x05, 0x02}, "EZTherm", "EZTherm", 0},
+	{DevCat{0x05, 0x03}, "SMSC110", "Broan Exhaust Fan", 0},
+	{DevCat{0x05, 0x04}, "2441V", "INSTEON Thermostat Adapter", 0},
+	{DevCat{0x05, 0x07}, "EZThermx", "EZThermx", 0},
+	{DevCat{0x05, 0x0a}, "2441ZTH", "Wireless Thermostat", BatteryPowered | RFOnly},
+	{DevCat{0x05, 0x0b}, "2441TH", "Thermostat", 0},
+	{DevCat{0x05, 0x0e}, "2491T", "All-Weather Thermostat", 0},
+
+	// Sensors and Actuators
+	{DevCat{0x07, 0x00}, "2450", "I/OLinc", 0},
+	{DevCat{0x07, 0x01}, "EZSns1W", "EZSns1W Sensor Module", 0},
+	{DevCat{0x07, 0x02}, "EZIO8T", "EZIO8T I/O Module", 0},
+	{DevCat{0x07, 0x03}, "EZIO2X4", "EZIO2X4 I/O Module", 0},
+	{DevCat{0x07, 0x09}, "2423A5", "SynchroLinc", 0},
+	{DevCat{0x07, 0x0d}, "2450", "I/OLinc 50/60Hz Auto Detect", 0},
+
+	// Energy Management
+	{DevCat{0x09, 0x00}, "2423A1", "iMeter Solo", 0},
+	{DevCat{0x09, 0x07}, "2477SA1", "220/240V 30A Load Controller NO (Dual-Band)", 0},
+	{DevCat{0x09, 0x08}, "2477SA2", "220/240V 30A Load Controller NC (Dual-Band)", 0},
+
+	// Window Coverings
+	{DevCat{0x0e, 0x00}, "2444A1", "Somfy Drape Controller RF Bridge", 0},
+	{DevCat{0x0e, 0x01}, "2444-222", "Micro Open/Close (915 MHz)", I2Cs},
+	{DevCat{0x0e, 0x02}, "2444-422", "Micro Open/Close (869 MHz)", I2Cs},
+	{DevCat{0x0e, 0x03}, "2444-522", "Micro Open/Close (921 MHz)", I2Cs},
+
+	// Access Control
+	{DevCat{0x0f, 0x06}, "2458A1", "MorningLinc", 0},
+
+	// Security, Health, Safety
+	{DevCat{0x10, 0x01}, "2842-222", "Motion Sensor (915 MHz)", BatteryPowered | MultiButton | RFOnly},
+	{DevCat{0x10, 0x02}, "2843-222", "TriggerLinc Open/Close Sensor (915 MHz)", BatteryPowered | RFOnly},
+	{DevCat{0x10, 0x03}, "2842-422", "Motion Sensor (869 MHz)", BatteryPowered | MultiButton | RFOnly},
+	{DevCat{0x10, 0x04}, "2842-522", "Motion Sensor (921 MHz)", BatteryPowered | MultiButton | RFOnly},
+	{DevCat{0x10, 0x05}, "2843-422", "Open/Close Sensor (869 MHz)", BatteryPowered | RFOnly},
+	{DevCat{0x10, 0x06}, "2843-522", "Open/Close Sensor (921 MHz)", BatteryPowered | RFOnly},
+	{DevCat{0x10, 0x08}, "2852-222", "Leak Sensor", BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x10, 0x0a}, "2982-222", "Smoke Bridge", I2Cs},
+	{DevCat{0x10, 0x0b}, "2852-422", "Leak Sensor (869 MHz)", BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x10, 0x0c}, "2852-522", "Leak Sensor (921 MHz)", BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x10, 0x11}, "2845-222", "Hidden Door Sensor", BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x10, 0x14}, "2845-422", "Hidden Door Sensor (869 MHz)", BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x10, 0x15}, "2845-522", "Hidden Door Sensor (921 MHz)", BatteryPowered | I2Cs | RFOnly},
+	{DevCat{0x10, 0x16}, "2844-222", "Motion Sensor II", BatteryPowered | MultiButton | I2Cs | RFOnly},
+}
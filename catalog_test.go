@@ -0,0 +1,177 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestCapabilityString(t *testing.T) {
+	tests := []struct {
+		input Capability
+		want  string
+	}{
+		{0, ""},
+		{Dimmable, "dimmable"},
+		{BatteryPowered | RFOnly, "battery,rfonly"},
+		{Dimmable | MultiButton | I2Cs, "dimmable,multibutton,i2cs"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.String(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestCapabilityJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Capability
+		wantErr bool
+	}{
+		{`[]`, 0, false},
+		{`["dimmable","i2cs"]`, Dimmable | I2Cs, false},
+		{`["battery","multibutton","rfonly"]`, BatteryPowered | MultiButton | RFOnly, false},
+		{`["flying"]`, 0, true},
+		{`"dimmable"`, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var got Capability
+			err := json.Unmarshal([]byte(test.input), &got)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("want error %v got %v", test.wantErr, err)
+			} else if err != nil {
+				return
+			}
+
+			if got != test.want {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+
+			buf, _ := json.Marshal(got)
+			if string(buf) != test.input {
+				t.Errorf("want %s got %s", test.input, buf)
+			}
+		})
+	}
+}
+
+func TestCategoryClass(t *testing.T) {
+	tests := []struct {
+		input Category
+		want  string
+	}{
+		{0x01, "Dimmable Lighting Control"},
+		{0x10, "Security, Health, Safety"},
+		{0x42, "Unknown Category 42"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.Class(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestProductCatalog(t *testing.T) {
+	tests := []struct {
+		input     DevCat
+		wantFound bool
+		want      string
+		wantClass string
+	}{
+		{DevCat{0x01, 0x20}, true, "2477D SwitchLinc Dimmer (Dual-Band)", "Dimmable Lighting Control"},
+		{DevCat{0x02, 0x2a}, true, "2477S SwitchLinc Relay (Dual-Band)", "Switched Lighting Control"},
+		{DevCat{0x10, 0x08}, true, "2852-222 Leak Sensor", "Security, Health, Safety"},
+		{DevCat{0x01, 0xfe}, false, "Dimmable Lighting Control (01.fe)", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input.String(), func(t *testing.T) {
+			product, found := Products.Find(test.input)
+			if found != test.wantFound {
+				t.Fatalf("want found %v got %v", test.wantFound, found)
+			}
+
+			if found && product.Class() != test.wantClass {
+				t.Errorf("want class %q got %q", test.wantClass, product.Class())
+			}
+
+			if got := Products.Describe(test.input); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestProductCatalogLoad(t *testing.T) {
+	catalog := &ProductCatalog{}
+	catalog.Add(Product{DevCat{0x01, 0x20}, "2477D", "SwitchLinc Dimmer", Dimmable})
+
+	input := `[
+  {"DevCat": "01.20", "Model": "2477D", "Name": "Hallway Dimmer", "Capabilities": ["dimmable", "i2cs"]},
+  {"DevCat": "42.01", "Model": "X1", "Name": "Prototype", "Capabilities": []}
+]`
+	if err := catalog.Load(bytes.NewBufferString(input)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Product{
+		{DevCat{0x01, 0x20}, "2477D", "Hallway Dimmer", Dimmable | I2Cs},
+		{DevCat{0x42, 0x01}, "X1", "Prototype", 0},
+	}
+	for _, w := range want {
+		if got, found := catalog.Find(w.DevCat); !found {
+			t.Errorf("expected %v to be found", w.DevCat)
+		} else if got != w {
+			t.Errorf("want %+v got %+v", w, got)
+		}
+	}
+
+	if err := catalog.Load(bytes.NewBufferString(`[{"Capabilities": ["flying"]}]`)); err == nil {
+		t.Errorf("expected an error loading an unknown capability")
+	}
+}
+
+func TestProductName(t *testing.T) {
+	tests := []struct {
+		input DevCat
+		want  string
+	}{
+		{DevCat{0x01, 0x20}, "2477D SwitchLinc Dimmer (Dual-Band) (01.02.03)"},
+		{DevCat{0x01, 0xfe}, "Dimmer (01.02.03)"},
+		{DevCat{0x02, 0x2a}, "2477S SwitchLinc Relay (Dual-Band) (01.02.03)"},
+		{DevCat{0x02, 0xfe}, "Switch (01.02.03)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input.String(), func(t *testing.T) {
+			device, _ := Devices.New(DeviceInfo{DevCat: test.input, EngineVersion: VerI2}, &testConnection{addr: Address{1, 2, 3}}, 0)
+			if got := fmt.Sprintf("%v", device); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
@@ -109,6 +109,13 @@ func printDevInfo(device insteon.Device, extra string) (err error) {
 
 	if err == nil {
 		fmt.Printf("     Category: %v\n", devCat)
+		if product, found := insteon.Products.Find(devCat); found {
+			fmt.Printf("      Product: %v\n", product)
+			fmt.Printf("        Class: %v\n", product.Class())
+			fmt.Printf(" Capabilities: %v\n", product.Capabilities)
+		} else {
+			fmt.Printf("        Class: %v\n", devCat.Category().Class())
+		}
 		fmt.Printf("     Firmware: %v\n", firmware)
 
 		if extra != "" {
@@ -116,7 +123,7 @@ func printDevInfo(device insteon.Device, extra string) (err error) {
 		}
 
 		err = devLink(device, func(linkable insteon.LinkableDevice) error {
-			return util.PrintLinks(os.Stdout, linkable)
+			return printLinks(linkable)
 		})
 	}
 	return err
@@ -132,61 +139,74 @@ func (dev *device) versionCmd() error {
 
 func (dev *device) editCmd() error {
 	return devLink(dev.Device, func(linkable insteon.LinkableDevice) error {
-		dbLinks, _ := linkable.Links()
-		if len(dbLinks) == 0 {
-			return fmt.Errorf("No links to edit")
-		}
+		return editLinks(linkable)
+	})
+}
 
-		tmpfile, err := ioutil.TempFile("", "insteon_")
-		if err != nil {
-			return err
-		}
-		defer os.Remove(tmpfile.Name())
-
-		buf := bytes.NewBuffer(nil)
-		fmt.Fprintf(buf, "#\n")
-		fmt.Fprintf(buf, "# Lines beginning with a # are ignored\n")
-		fmt.Fprintf(buf, "# DO NOT delete lines, this will cause the entries to\n")
-		fmt.Fprintf(buf, "# shift up and then the last entry will be in the database twice\n")
-		fmt.Fprintf(buf, "# To delete a record simply mark it 'Available' by changing the\n")
-		fmt.Fprintf(buf, "# first letter of the Flags to 'A'\n")
-		fmt.Fprintf(buf, "#\n")
-		fmt.Fprintf(buf, "# Flags Group Address    Data\n")
-		for _, link := range dbLinks {
-			output, _ := link.MarshalText()
-			fmt.Fprintf(buf, "  %s\n", string(output))
-		}
+func editLinks(linkable insteon.Linkable) error {
+	dbLinks, _ := linkable.Links()
+	if len(dbLinks) == 0 {
+		return fmt.Errorf("No links to edit")
+	}
+
+	tmpfile, err := ioutil.TempFile("", "insteon_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpfile.Name())
+
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "#\n")
+	fmt.Fprintf(buf, "# Lines beginning with a # are ignored\n")
+	fmt.Fprintf(buf, "# DO NOT delete lines, this will cause the entries to\n")
+	fmt.Fprintf(buf, "# shift up and then the last entry will be in the database twice\n")
+	fmt.Fprintf(buf, "# To delete a record simply mark it 'Available' by changing the\n")
+	fmt.Fprintf(buf, "# first letter of the Flags to 'A'\n")
+	fmt.Fprintf(buf, "#\n")
+	fmt.Fprintf(buf, "# Flags Group Address    Data\n")
+	for _, link := range dbLinks {
+		output, _ := link.MarshalText()
+		fmt.Fprintf(buf, "  %s\n", string(output))
+	}
 
-		tmpfile.Write(buf.Bytes())
-
-		if err = tmpfile.Close(); err == nil {
-			cmd := exec.Command(editor, tmpfile.Name())
-			cmd.Stdin = os.Stdin
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			cmd.Start()
-			err = cmd.Wait()
-			if err == nil {
-				dbLinks = nil
-				input, err := ioutil.ReadFile(tmpfile.Name())
-				if err == nil && !bytes.Equal(buf.Bytes(), input) {
-					for _, line := range bytes.Split(input, []byte("\n")) {
-						line = bytes.TrimSpace(line)
-						if len(line) == 0 || bytes.Index(line, []byte("#")) == 0 {
-							continue
-						}
-						link := &insteon.LinkRecord{}
-						err = link.UnmarshalText(line)
-						if err == nil {
-							dbLinks = append(dbLinks, link)
-						} else {
-							fmt.Printf("Skipping invalid line %q: %v\n", string(line), err)
-						}
+	tmpfile.Write(buf.Bytes())
+
+	if err = tmpfile.Close(); err == nil {
+		cmd := exec.Command(editor, tmpfile.Name())
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Start()
+		err = cmd.Wait()
+		if err == nil {
+			dbLinks = nil
+			input, err := ioutil.ReadFile(tmpfile.Name())
+			if err == nil && !bytes.Equal(buf.Bytes(), input) {
+				for _, line := range bytes.Split(input, []byte("\n")) {
+					line = bytes.TrimSpace(line)
+					if len(line) == 0 || bytes.Index(line, []byte("#")) == 0 {
+						continue
+					}
+					link := &insteon.LinkRecord{}
+					err = link.UnmarshalText(line)
+					if err == nil {
+						dbLinks = append(dbLinks, link)
+					} else {
+						fmt.Printf("Skipping invalid line %q: %v\n", string(line), err)
 					}
-					linkable.WriteLinks(dbLinks...)
 				}
+				linkable.WriteLinks(dbLinks...)
 			}
 		}
-		return err
-	})
+	}
+	return err
+}
+
+// printLinks prints the link database including the product names
+// of the linked devices found in the product database
+func printLinks(linkable insteon.Linkable) error {
+	if productDB == nil {
+		return util.PrintLinks(os.Stdout, linkable)
+	}
+	return util.PrintLinksWithDevices(os.Stdout, linkable, productDB)
 }
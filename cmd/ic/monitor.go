@@ -22,12 +22,23 @@ import (
 
 func init() {
 	app.SubCommand("monitor", cli.DescOption("Monitor the Insteon network"), cli.CallbackOption(monCmd))
+	app.SubCommand("events", cli.DescOption("Print events (button presses, status changes, etc) sent by devices"), cli.CallbackOption(eventsCmd))
 }
 
 func monCmd() error {
 	log.Printf("Starting monitor...")
-	/*for msg := range modem.Monitor() {
+	for msg := range modem.Monitor() {
 		log.Printf("%s", msg)
-	}*/
+	}
+	return nil
+}
+
+func eventsCmd() error {
+	log.Printf("Waiting for events...")
+	stream := modem.DeviceEvents()
+	defer stream.Close()
+	for event := range stream.Events() {
+		log.Printf("%s", event)
+	}
 	return nil
 }
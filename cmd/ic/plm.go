@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/abates/cli"
 	"github.com/abates/insteon"
@@ -47,9 +48,32 @@ func (al *addrList) String() string {
 	return strings.Join(list, ",")
 }
 
+// allLinkTimeout is how long the modem stays in linking mode
+// waiting for a set button press
+const allLinkTimeout = 4 * time.Minute
+
+type devCatValue insteon.DevCat
+
+func (dc *devCatValue) Set(str string) error {
+	var cat, subcat byte
+	n, err := fmt.Sscanf(str, "%02x.%02x", &cat, &subcat)
+	if n < 2 {
+		return fmt.Errorf("device category must be in the form xx.yy")
+	}
+	*dc = devCatValue{cat, subcat}
+	return err
+}
+
+func (dc *devCatValue) String() string { return insteon.DevCat(*dc).String() }
+
 type plmCmd struct {
 	*plm.PLM
 	addresses []insteon.Address
+	devCat    devCatValue
+	firmware  int
+	cmd1      int
+	cmd2      int
+	led       bool
 }
 
 func init() {
@@ -58,6 +82,7 @@ func init() {
 	pc := app.SubCommand("plm", cli.DescOption("Interact with the PLM"))
 	pc.SubCommand("info", cli.DescOption("display information (device id, link database, etc)"), cli.CallbackOption(p.infoCmd))
 	pc.SubCommand("reset", cli.DescOption("Factory reset the IM"), cli.CallbackOption(p.resetCmd))
+	pc.SubCommand("edit", cli.DescOption("edit the IM all-link database"), cli.CallbackOption(p.editCmd))
 
 	cmd := pc.SubCommand("link", cli.UsageOption("<device id>,..."), cli.DescOption("Link (as a controller) the PLM to one or more devices. Device IDs must be comma separated"), cli.CallbackOption(p.linkCmd))
 	cmd.Arguments.Var((*addrList)(&p.addresses), "<device id>,...")
@@ -68,6 +93,23 @@ func init() {
 	cmd = pc.SubCommand("crosslink", cli.UsageOption("<device id>,..."), cli.DescOption("Crosslink the PLM to one or more devices. Device IDs must be comma separated"), cli.CallbackOption(p.crossLinkCmd))
 	cmd.Arguments.Var((*addrList)(&p.addresses), "<device id>,...")
 
+	cmd = pc.SubCommand("setcat", cli.UsageOption("<devcat> <firmware>"), cli.DescOption("Set the device category (xx.yy) and firmware version the IM reports"), cli.CallbackOption(p.setCatCmd))
+	cmd.Arguments.Var(&p.devCat, "<devcat>")
+	cmd.Arguments.Int(&p.firmware, "<firmware>")
+
+	cmd = pc.SubCommand("setack", cli.UsageOption("<cmd2>"), cli.DescOption("Set the command 2 byte the IM uses to ACK direct messages"), cli.CallbackOption(p.setAckCmd))
+	cmd.Arguments.Int(&p.cmd2, "<cmd2>")
+
+	cmd = pc.SubCommand("setnak", cli.UsageOption("<cmd2>"), cli.DescOption("Set the command 2 byte the IM uses to NAK direct messages"), cli.CallbackOption(p.setNakCmd))
+	cmd.Arguments.Int(&p.cmd2, "<cmd2>")
+
+	cmd = pc.SubCommand("setnak2", cli.UsageOption("<cmd1> <cmd2>"), cli.DescOption("Set the command 1 and 2 bytes the IM uses to NAK direct messages"), cli.CallbackOption(p.setNak2Cmd))
+	cmd.Arguments.Int(&p.cmd1, "<cmd1>")
+	cmd.Arguments.Int(&p.cmd2, "<cmd2>")
+
+	cmd = pc.SubCommand("led", cli.UsageOption("<true|false>"), cli.DescOption("Turn the IM LED on or off (automatic LED operation must be disabled)"), cli.CallbackOption(p.ledCmd))
+	cmd.Arguments.Bool(&p.led, "<true|false>")
+
 	cmd = pc.SubCommand("alllink", cli.UsageOption("<device id>,..."), cli.DescOption("Put the PLM into linking mode for manual linking. Device IDs must be comma separated"), cli.CallbackOption(p.allLinkCmd))
 	cmd.Arguments.Var((*addrList)(&p.addresses), "<device id>,...")
 }
@@ -86,12 +128,30 @@ func (p *plmCmd) infoCmd() (err error) {
 	if err == nil {
 		fmt.Printf("   Address: %s\n", info.Address)
 		fmt.Printf("  Category: %02x Sub-Category: %02x\n", info.DevCat.Category(), info.DevCat.SubCategory())
+		fmt.Printf("   Product: %s\n", insteon.Products.Describe(info.DevCat))
 		fmt.Printf("  Firmware: %d\n", info.Firmware)
-		err = util.PrintLinks(os.Stdout, modem)
+		err = printLinks(modem)
 	}
 	return err
 }
 
+func (p *plmCmd) editCmd() error { return editLinks(modem) }
+
+func (p *plmCmd) setCatCmd() error {
+	return modem.SetDeviceCategory(insteon.DevCat(p.devCat), p.firmware)
+}
+
+func (p *plmCmd) setAckCmd() error  { return modem.SetAckByte(p.cmd2) }
+func (p *plmCmd) setNakCmd() error  { return modem.SetNakByte(p.cmd2) }
+func (p *plmCmd) setNak2Cmd() error { return modem.SetNakBytes(p.cmd1, p.cmd2) }
+
+func (p *plmCmd) ledCmd() error {
+	if p.led {
+		return modem.LEDOn()
+	}
+	return modem.LEDOff()
+}
+
 func (p *plmCmd) linkCmd() error      { return p.link(false) }
 func (p *plmCmd) crossLinkCmd() error { return p.link(true) }
 
@@ -127,7 +187,20 @@ func (p *plmCmd) link(crosslink bool) error {
 	return nil
 }
 
-func (p *plmCmd) allLinkCmd() error { return modem.EnterLinkingMode(insteon.Group(0x01)) }
+func (p *plmCmd) allLinkCmd() error {
+	fmt.Printf("Press the set button on the device to link...")
+	alc, err := modem.AllLink(insteon.Group(0x01), allLinkTimeout)
+	if err == nil {
+		if alc.LinkCode == plm.LinkCodeDeleted {
+			fmt.Printf("unlinked %s (%s)\n", alc.Address, insteon.Products.Describe(alc.DevCat))
+		} else {
+			fmt.Printf("linked %s (%s)\n", alc.Address, insteon.Products.Describe(alc.DevCat))
+		}
+	} else {
+		fmt.Printf("failed: %v\n", err)
+	}
+	return err
+}
 
 func (p *plmCmd) unlinkCmd() (err error) {
 	group := insteon.Group(0x01)
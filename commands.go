@@ -200,6 +200,39 @@ var (
 	CmdLightOffAtRampV67 = Command{0x00, 0x35, 0x00} // Light Off At Ramp
 )
 
+// Thermostat Extended Direct Messages
+var (
+	// CmdThermostatControl changes the thermostat mode and fan mode (the mode is the command 2 byte)
+	CmdThermostatControl = Command{0x01, 0x6b, 0x00} // Thermostat Control
+
+	// CmdSetCoolSetpoint sets the cooling setpoint to half the command 2 byte
+	CmdSetCoolSetpoint = Command{0x01, 0x6c, 0x00} // Set Cool Setpoint
+
+	// CmdSetHeatSetpoint sets the heating setpoint to half the command 2 byte
+	CmdSetHeatSetpoint = Command{0x01, 0x6d, 0x00} // Set Heat Setpoint
+
+	// CmdThermostatGetSet gets the thermostat status and sets the thermostat clock
+	CmdThermostatGetSet = Command{0x01, 0x2e, 0x02} // Thermostat Get/Set
+)
+
+// Thermostat Standard Direct Messages sent by thermostats when their status changes
+var (
+	// CmdTemperatureChange reports the ambient temperature
+	CmdTemperatureChange = Command{0x00, 0x6e, 0x00} // Temperature Change
+
+	// CmdHumidityChange reports the relative humidity
+	CmdHumidityChange = Command{0x00, 0x6f, 0x00} // Humidity Change
+
+	// CmdModeChange reports the system mode (low nibble) and fan mode (high nibble)
+	CmdModeChange = Command{0x00, 0x70, 0x00} // Mode Change
+
+	// CmdCoolSetpointChange reports the cooling setpoint
+	CmdCoolSetpointChange = Command{0x00, 0x71, 0x00} // Cool Setpoint Change
+
+	// CmdHeatSetpointChange reports the heating setpoint
+	CmdHeatSetpointChange = Command{0x00, 0x72, 0x00} // Heat Setpoint Change
+)
+
 var cmdStrings = map[Command]string{
 	CmdAssignToAllLinkGroup:       "Assign to All-Link Group",
 	CmdDeleteFromAllLinkGroup:     "Delete from All-Link Group",
@@ -258,4 +291,13 @@ var cmdStrings = map[Command]string{
 	CmdLightOnAtRampV67:           "Light On At Ramp",
 	CmdLightOffAtRamp:             "Light Off At Ramp",
 	CmdLightOffAtRampV67:          "Light Off At Ramp",
+	CmdThermostatControl:          "Thermostat Control",
+	CmdSetCoolSetpoint:            "Set Cool Setpoint",
+	CmdSetHeatSetpoint:            "Set Heat Setpoint",
+	CmdThermostatGetSet:           "Thermostat Get/Set",
+	CmdTemperatureChange:          "Temperature Change",
+	CmdHumidityChange:             "Humidity Change",
+	CmdModeChange:                 "Mode Change",
+	CmdCoolSetpointChange:         "Cool Setpoint Change",
+	CmdHeatSetpointChange:         "Heat Setpoint Change",
 }
@@ -15,6 +15,7 @@
 package insteon
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -31,10 +32,18 @@ type Connection interface {
 	// but may return with a read timeout or other communication error
 	Send(*Message) (ack *Message, err error)
 
+	// SendContext is the same as Send except that waiting for the
+	// Ack/Nak is abandoned as soon as the context is done
+	SendContext(context.Context, *Message) (ack *Message, err error)
+
 	// Receive waits for the next message from the device.  Receive
 	// always returns, but may return with an error (such as ErrReadTimeout)
 	Receive() (*Message, error)
 
+	// ReceiveContext is the same as Receive except that it also returns
+	// (with the context's error) when the context is done
+	ReceiveContext(context.Context) (*Message, error)
+
 	// IDRequest sends an IDRequest command to the device and waits for
 	// the corresponding Set Button Pressed Controller/Responder message.
 	// The response is parsed and the Firmware version and DevCat are
@@ -43,6 +52,10 @@ type Connection interface {
 	// doesn't receive it
 	IDRequest() (FirmwareVersion, DevCat, error)
 
+	// IDRequestContext is the same as IDRequest except that the request
+	// is cancelled when the context is done
+	IDRequestContext(context.Context) (FirmwareVersion, DevCat, error)
+
 	// EngineVersion will query the device for its Insteon Engine Version
 	// and returns the response.  If the device never responds, then ErrReadTimeout
 	// is the returned error.  If the device responds with a Nak and Command 2
@@ -51,6 +64,10 @@ type Connection interface {
 	// as well as ErrNotLinked
 	EngineVersion() (EngineVersion, error)
 
+	// EngineVersionContext is the same as EngineVersion except that the
+	// query is cancelled when the context is done
+	EngineVersionContext(context.Context) (EngineVersion, error)
+
 	// AddListener will return a channel that receives any messages matching
 	// the flags an the cmd1 flag of a Command.
 	AddListener(t MessageType, cmds ...Command) <-chan *Message
@@ -205,8 +222,11 @@ func (conn *connection) Address() Address {
 func (conn *connection) readLoop() {
 	for {
 		select {
-		case msg := <-conn.rxCh:
-			if msg.Src == conn.addr {
+		case msg, open := <-conn.rxCh:
+			if !open {
+				// stop receiving, but wait for Close
+				conn.rxCh = nil
+			} else if msg.Src == conn.addr {
 				if len(conn.match) > 0 {
 					for _, m := range conn.match {
 						if (msg.Command == m) || (msg.Command[1] == m[1] && m[2] == 0x00) {
@@ -230,15 +250,23 @@ func (conn *connection) readLoop() {
 }
 
 func (conn *connection) Send(msg *Message) (ack *Message, err error) {
+	return conn.SendContext(context.Background(), msg)
+}
+
+func (conn *connection) SendContext(ctx context.Context, msg *Message) (ack *Message, err error) {
 	msg.Dst = conn.addr
 	msg.Flags = Flag(MsgTypeDirect, len(msg.Payload) > 0, conn.ttl, conn.ttl)
 	Log.Tracef("Connection %v TX %v", conn.addr, msg)
-	conn.txCh <- msg
+	select {
+	case conn.txCh <- msg:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 
 	// wait for ack
 	timeout := time.Now().Add(conn.timeout)
 	for err == nil {
-		ack, err = conn.Receive()
+		ack, err = conn.ReceiveContext(ctx)
 		if err == nil && (ack.Ack() || ack.Nak()) {
 			break
 		} else if timeout.Before(time.Now()) {
@@ -250,7 +278,11 @@ func (conn *connection) Send(msg *Message) (ack *Message, err error) {
 }
 
 func (conn *connection) Receive() (msg *Message, err error) {
-	return readFromCh(conn.msgCh, conn.timeout)
+	return conn.ReceiveContext(context.Background())
+}
+
+func (conn *connection) ReceiveContext(ctx context.Context) (msg *Message, err error) {
+	return readFromCh(ctx, conn.msgCh, conn.timeout)
 }
 
 func (conn *connection) Close() error {
@@ -260,8 +292,15 @@ func (conn *connection) Close() error {
 }
 
 func (conn *connection) IDRequest() (version FirmwareVersion, devCat DevCat, err error) {
-	_, err = conn.Send(&Message{Command: CmdIDRequest, Flags: StandardDirectMessage})
-	err = Receive(conn, conn.timeout, func(msg *Message) error {
+	return conn.IDRequestContext(context.Background())
+}
+
+func (conn *connection) IDRequestContext(ctx context.Context) (version FirmwareVersion, devCat DevCat, err error) {
+	_, err = conn.SendContext(ctx, &Message{Command: CmdIDRequest, Flags: StandardDirectMessage})
+	if ctx.Err() != nil {
+		return version, devCat, ctx.Err()
+	}
+	err = ReceiveContext(ctx, conn, conn.timeout, func(msg *Message) error {
 		if msg.Broadcast() && (msg.Command[1] == 0x01 || msg.Command[1] == 0x02) {
 			version = FirmwareVersion(msg.Dst[2])
 			devCat = DevCat{msg.Dst[0], msg.Dst[1]}
@@ -273,7 +312,11 @@ func (conn *connection) IDRequest() (version FirmwareVersion, devCat DevCat, err
 }
 
 func (conn *connection) EngineVersion() (version EngineVersion, err error) {
-	ack, err := conn.Send(&Message{Command: CmdGetEngineVersion, Flags: StandardDirectMessage})
+	return conn.EngineVersionContext(context.Background())
+}
+
+func (conn *connection) EngineVersionContext(ctx context.Context) (version EngineVersion, err error) {
+	ack, err := conn.SendContext(ctx, &Message{Command: CmdGetEngineVersion, Flags: StandardDirectMessage})
 	if err == nil {
 		if ack.Nak() {
 			// This only happens if the device is an I2Cs device and
@@ -298,10 +341,16 @@ func (conn *connection) EngineVersion() (version EngineVersion, err error) {
 // for an additional read.  If the callback returns any other error then that error will
 // be returned
 func Receive(conn Connection, timeout time.Duration, cb func(*Message) error) (err error) {
+	return ReceiveContext(context.Background(), conn, timeout, cb)
+}
+
+// ReceiveContext is the same as Receive except that receiving stops, and the
+// context's error is returned, as soon as the context is done
+func ReceiveContext(ctx context.Context, conn Connection, timeout time.Duration, cb func(*Message) error) (err error) {
 	readTimeout := time.Now().Add(timeout)
 	for err == nil {
 		var msg *Message
-		msg, err = conn.Receive()
+		msg, err = conn.ReceiveContext(ctx)
 		if err == nil {
 			if readTimeout.Before(time.Now()) {
 				err = ErrReadTimeout
@@ -320,9 +369,20 @@ func Receive(conn Connection, timeout time.Duration, cb func(*Message) error) (e
 	return err
 }
 
-func readFromCh(ch <-chan *Message, timeout time.Duration) (msg *Message, err error) {
+func readFromCh(ctx context.Context, ch <-chan *Message, timeout time.Duration) (msg *Message, err error) {
+	// a message that is already waiting is always returned.  Otherwise a
+	// short timeout can expire before the select below looks at the
+	// channel, and select picks randomly between ready cases
+	select {
+	case msg = <-ch:
+		return msg, nil
+	default:
+	}
+
 	select {
 	case msg = <-ch:
+	case <-ctx.Done():
+		err = ctx.Err()
 	case <-time.After(timeout):
 		err = ErrReadTimeout
 	}
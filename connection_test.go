@@ -15,6 +15,7 @@
 package insteon
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"reflect"
@@ -44,15 +45,29 @@ func (tc *testConnection) Address() Address { return tc.addr }
 func (tc *testConnection) EngineVersion() (EngineVersion, error) {
 	return tc.engineVersion, tc.engineVersionErr
 }
+func (tc *testConnection) EngineVersionContext(context.Context) (EngineVersion, error) {
+	return tc.EngineVersion()
+}
 func (tc *testConnection) IDRequest() (FirmwareVersion, DevCat, error) {
 	return tc.firmwareVersion, tc.devCat, nil
 }
+func (tc *testConnection) IDRequestContext(context.Context) (FirmwareVersion, DevCat, error) {
+	return tc.IDRequest()
+}
 
 func (tc *testConnection) SendCommand(cmd Command, payload []byte) (Command, error) {
 	msg, err := tc.Send(&Message{Command: cmd, Payload: payload})
 	return msg.Command, err
 }
 
+func (tc *testConnection) SendCommandContext(ctx context.Context, cmd Command, payload []byte) (Command, error) {
+	return tc.SendCommand(cmd, payload)
+}
+
+func (tc *testConnection) SendContext(ctx context.Context, msg *Message) (*Message, error) {
+	return tc.Send(msg)
+}
+
 func (tc *testConnection) Send(msg *Message) (*Message, error) {
 	tc.sendCh <- msg
 	if tc.sendErr != nil {
@@ -68,6 +83,10 @@ func (tc *testConnection) Receive() (*Message, error) {
 	return nil, tc.recvErr
 }
 
+func (tc *testConnection) ReceiveContext(context.Context) (*Message, error) {
+	return tc.Receive()
+}
+
 func (tc *testConnection) AddListener(MessageType, ...Command) <-chan *Message { return tc.recvCh }
 func (tc *testConnection) RemoveListener(<-chan *Message)                      {}
 
@@ -175,6 +194,43 @@ func TestNewConnectionTTL(t *testing.T) {
 	}
 }
 
+func TestConnectionSendContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		txLen       int
+		cancel      bool
+		expectedErr error
+	}{
+		{"Deadline waiting for ack", 1, false, context.DeadlineExceeded},
+		{"Cancel waiting for ack", 1, true, context.Canceled},
+		{"Cancel waiting to send", 0, true, context.Canceled},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			txCh := make(chan *Message, test.txLen)
+			rxCh := make(chan *Message)
+			conn, _ := NewConnection(txCh, rxCh, Address{}, ConnectionTimeout(time.Hour))
+			defer conn.(io.Closer).Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			if test.cancel {
+				ctx, cancel = context.WithCancel(context.Background())
+				go func() {
+					time.Sleep(time.Millisecond)
+					cancel()
+				}()
+			}
+			defer cancel()
+
+			_, err := conn.SendContext(ctx, &Message{Command: CmdPing})
+			if err != test.expectedErr {
+				t.Errorf("want %v got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
 func TestConnectionReceive(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -313,3 +369,18 @@ func TestReceive(t *testing.T) {
 		t.Errorf("Expected ErrReadTimeout got %v", err)
 	}
 }
+
+func TestReadFromChWaiting(t *testing.T) {
+	ch := make(chan *Message, 1)
+	for i := 0; i < 100; i++ {
+		want := &Message{Command: CmdPing}
+		ch <- want
+		if got, err := readFromCh(context.Background(), ch, time.Nanosecond); err != nil || got != want {
+			t.Fatalf("want %v got %v (error %v)", want, got, err)
+		}
+	}
+
+	if _, err := readFromCh(context.Background(), ch, time.Nanosecond); err != ErrReadTimeout {
+		t.Errorf("want error %v got %v", ErrReadTimeout, err)
+	}
+}
@@ -15,6 +15,7 @@
 package insteon
 
 import (
+	"context"
 	"time"
 )
 
@@ -34,42 +35,137 @@ type EngineVersion int
 type DeviceConstructor func(info DeviceInfo, device Device, timeout time.Duration) (Device, error)
 
 // Devices is a global DeviceRegistry. This device registry should only be used
-// if you are adding a new device category to the system
+// if you are adding a new device category (or device type) to the system
 var Devices DeviceRegistry
 
+// RegisterOption narrows the devices that a registered constructor matches
+type RegisterOption func(*registration)
+
+// SubCategories restricts a registration to devices with a sub category
+// between min and max (inclusive)
+func SubCategories(min, max SubCategory) RegisterOption {
+	return func(r *registration) {
+		r.subCategories = true
+		r.min = min
+		r.max = max
+	}
+}
+
+// Firmware restricts a registration to devices whose firmware version
+// satisfies the predicate
+func Firmware(match func(FirmwareVersion) bool) RegisterOption {
+	return func(r *registration) {
+		r.firmware = match
+	}
+}
+
+type registration struct {
+	category      Category
+	subCategories bool
+	min           SubCategory
+	max           SubCategory
+	firmware      func(FirmwareVersion) bool
+	constructor   DeviceConstructor
+}
+
+func (r *registration) matches(info DeviceInfo) bool {
+	if r.category != info.DevCat.Category() {
+		return false
+	}
+
+	if r.subCategories && (info.DevCat.SubCategory() < r.min || r.max < info.DevCat.SubCategory()) {
+		return false
+	}
+	return r.firmware == nil || r.firmware(info.FirmwareVersion)
+}
+
+// moreSpecific returns true if r matches a narrower set of devices than
+// other.  A sub category range is more specific than the whole category
+// and narrower ranges are more specific than wider ones.  Between
+// otherwise equal registrations, a firmware predicate is more specific
+func (r *registration) moreSpecific(other *registration) bool {
+	if r.subCategories != other.subCategories {
+		return r.subCategories
+	}
+
+	if r.subCategories && r.max-r.min != other.max-other.min {
+		return r.max-r.min < other.max-other.min
+	}
+	return r.firmware != nil && other.firmware == nil
+}
+
 // DeviceRegistry is a mechanism to keep track of specific constructors for different
-// device categories
+// device categories.  Documentation simply calls the first byte of the DevCat the
+// category and the second byte the sub category.  Constructors can be registered for
+// an entire category or narrowed to a range of sub categories and firmware versions
 type DeviceRegistry struct {
-	// devices key is the first byte of the
-	// Category.  Documentation simply calls this
-	// the category and the second byte the sub
-	// category, but we've combined both bytes
-	// into a single type
-	devices map[Category]DeviceConstructor
-}
-
-// Register will assign the given constructor to the supplied category
-func (dr *DeviceRegistry) Register(category Category, constructor DeviceConstructor) {
-	if dr.devices == nil {
-		dr.devices = make(map[Category]DeviceConstructor)
+	registrations []*registration
+}
+
+// Register will assign the given constructor to the supplied category.  The options
+// restrict the constructor to specific sub categories and firmware versions.  When
+// a device matches more than one registration, the most specific registration is
+// used.  Registering a constructor for the same category and sub categories (without
+// a firmware predicate) replaces the existing constructor
+func (dr *DeviceRegistry) Register(category Category, constructor DeviceConstructor, options ...RegisterOption) {
+	r := &registration{category: category, constructor: constructor}
+	for _, option := range options {
+		option(r)
+	}
+
+	if r.firmware == nil {
+		for i, existing := range dr.registrations {
+			if existing.firmware == nil && existing.category == r.category && existing.subCategories == r.subCategories && existing.min == r.min && existing.max == r.max {
+				dr.registrations[i] = r
+				return
+			}
+		}
 	}
-	dr.devices[category] = constructor
+	dr.registrations = append(dr.registrations, r)
 }
 
-// Delete will remove a device constructor from the registry
+// Delete will remove all of the device constructors registered for the category
 func (dr *DeviceRegistry) Delete(category Category) {
-	delete(dr.devices, category)
+	registrations := dr.registrations[:0]
+	for _, r := range dr.registrations {
+		if r.category != category {
+			registrations = append(registrations, r)
+		}
+	}
+	dr.registrations = registrations
 }
 
-// Find looks for a constructor corresponding to the given category
+// Find looks for the constructor registered for the entire category (without
+// any sub category or firmware restrictions)
 func (dr *DeviceRegistry) Find(category Category) (DeviceConstructor, bool) {
-	constructor, found := dr.devices[category]
-	return constructor, found
+	for _, r := range dr.registrations {
+		if r.category == category && !r.subCategories && r.firmware == nil {
+			return r.constructor, true
+		}
+	}
+	return nil, false
 }
 
-// New will look in the registry for a device constructor matching the
-// given device category (supplied by the DeviceInfo argument).  If found,
-// the constructor is called and the specific device type is returned.  If
+// Lookup returns the constructor from the most specific registration matching
+// the device category and firmware version in the DeviceInfo.  If two matching
+// registrations are equally specific, the one registered last is used
+func (dr *DeviceRegistry) Lookup(info DeviceInfo) (DeviceConstructor, bool) {
+	var match *registration
+	for _, r := range dr.registrations {
+		if r.matches(info) && (match == nil || !match.moreSpecific(r)) {
+			match = r
+		}
+	}
+
+	if match == nil {
+		return nil, false
+	}
+	return match.constructor, true
+}
+
+// New will look in the registry for the most specific device constructor matching
+// the device category and firmware version (supplied by the DeviceInfo argument).  If
+// found, the constructor is called and the specific device type is returned.  If
 // not found, then a base device (I1Device, I2Device, I2CsDevice) is returned
 //
 // Errors are only returned if the device category is found in the registry and
@@ -77,7 +173,7 @@ func (dr *DeviceRegistry) Find(category Category) (DeviceConstructor, bool) {
 func (dr *DeviceRegistry) New(info DeviceInfo, conn Connection, timeout time.Duration) (Device, error) {
 	device, err := New(info.EngineVersion, conn, timeout)
 	if err == nil {
-		if constructor, found := dr.Find(info.DevCat.Category()); found {
+		if constructor, found := dr.Lookup(info); found {
 			device, err = constructor(info, device, timeout)
 		}
 	}
@@ -101,6 +197,10 @@ type Device interface {
 	// length message is used to deliver the commands. The command bytes from the
 	// response ack are returned as well as any error
 	SendCommand(cmd Command, payload []byte) (response Command, err error)
+
+	// SendCommandContext is the same as SendCommand except that the
+	// command is abandoned as soon as the context is done
+	SendCommandContext(ctx context.Context, cmd Command, payload []byte) (response Command, err error)
 }
 
 // PingableDevice is any device that implements the Ping method
@@ -194,6 +294,30 @@ type Linkable interface {
 	// then the appropriate error is returned (ErrReadTimeout, ErrAckTimeout,
 	// etc).
 	WriteLinks(...*LinkRecord) error
+
+	// EnterLinkingModeContext is EnterLinkingMode that stops waiting
+	// when the context is done
+	EnterLinkingModeContext(context.Context, Group) error
+
+	// EnterUnlinkingModeContext is EnterUnlinkingMode that stops waiting
+	// when the context is done
+	EnterUnlinkingModeContext(context.Context, Group) error
+
+	// ExitLinkingModeContext is ExitLinkingMode that stops waiting
+	// when the context is done
+	ExitLinkingModeContext(context.Context) error
+
+	// LinksContext is Links that stops retrieving the all-link
+	// database when the context is done
+	LinksContext(context.Context) ([]*LinkRecord, error)
+
+	// UpdateLinksContext is UpdateLinks that stops writing links
+	// when the context is done
+	UpdateLinksContext(context.Context, ...*LinkRecord) error
+
+	// WriteLinksContext is WriteLinks that stops writing links
+	// when the context is done
+	WriteLinksContext(context.Context, ...*LinkRecord) error
 }
 
 // DeviceInfo is a record of information about known
@@ -203,6 +327,31 @@ type DeviceInfo struct {
 	DevCat          DevCat
 	FirmwareVersion FirmwareVersion
 	EngineVersion   EngineVersion
+
+	// EngineVersionKnown indicates the engine version has been
+	// determined.  VerI1 is the zero value of EngineVersion, so
+	// the version alone can't tell an I1 device from one that has
+	// never been queried
+	EngineVersionKnown bool
+}
+
+// ProductDatabase is a registry of the devices that have been seen on the
+// local Insteon network.  The database includes the device category,
+// firmware and engine versions.  ProductDatabase implementations must be
+// thread safe as the methods can be called from multiple go routines
+type ProductDatabase interface {
+	UpdateDevCat(address Address, devCat DevCat)
+
+	// UpdateEngineVersion saves the engine version of the device
+	// and marks it as known
+	UpdateEngineVersion(address Address, engineVersion EngineVersion)
+
+	UpdateFirmwareVersion(address Address, firmwareVersion FirmwareVersion)
+
+	// Update replaces everything known about the device at once
+	Update(address Address, info DeviceInfo)
+
+	Find(address Address) (deviceInfo DeviceInfo, found bool)
 }
 
 // Open will create a new device that is ready to be used. Open tries to contact
@@ -217,13 +366,19 @@ type DeviceInfo struct {
 // is encountered, then the I2CsDevice is returned with an ErrNotLinked error.  This
 // allows the application to initiate linking, if desired
 func Open(conn Connection, timeout time.Duration) (device Device, err error) {
-	version, err := conn.EngineVersion()
+	return OpenContext(context.Background(), conn, timeout)
+}
+
+// OpenContext is the same as Open except that querying the device is
+// abandoned, and the context's error returned, when the context is done
+func OpenContext(ctx context.Context, conn Connection, timeout time.Duration) (device Device, err error) {
+	version, err := conn.EngineVersionContext(ctx)
 	if err == nil {
 		info := DeviceInfo{
 			Address:       conn.Address(),
 			EngineVersion: version,
 		}
-		info.FirmwareVersion, info.DevCat, err = conn.IDRequest()
+		info.FirmwareVersion, info.DevCat, err = conn.IDRequestContext(ctx)
 		if err == nil {
 			device, err = Devices.New(info, conn, timeout)
 		}
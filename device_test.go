@@ -49,6 +49,66 @@ func TestDeviceRegistry(t *testing.T) {
 	}
 }
 
+type namedDevice struct {
+	Device
+	name string
+}
+
+func TestDeviceRegistryLookup(t *testing.T) {
+	newerThan := func(version FirmwareVersion) func(FirmwareVersion) bool {
+		return func(fv FirmwareVersion) bool { return fv > version }
+	}
+
+	dr := &DeviceRegistry{}
+	register := func(name string, options ...RegisterOption) {
+		dr.Register(Category(1), func(DeviceInfo, Device, time.Duration) (Device, error) {
+			return &namedDevice{name: name}, nil
+		}, options...)
+	}
+	register("replaced")
+	register("category")
+	register("wide", SubCategories(0x00, 0x7f))
+	register("narrow", SubCategories(0x1c, 0x1c))
+	register("firmware", SubCategories(0x00, 0x7f), Firmware(newerThan(0x40)))
+	register("fanlinc", SubCategories(0x2e, 0x2e))
+
+	tests := []struct {
+		desc  string
+		input DeviceInfo
+		want  string
+	}{
+		{"category", DeviceInfo{DevCat: DevCat{0x01, 0x80}}, "category"},
+		{"sub category range", DeviceInfo{DevCat: DevCat{0x01, 0x20}, FirmwareVersion: 0x40}, "wide"},
+		{"firmware", DeviceInfo{DevCat: DevCat{0x01, 0x20}, FirmwareVersion: 0x41}, "firmware"},
+		{"narrow range beats firmware", DeviceInfo{DevCat: DevCat{0x01, 0x1c}, FirmwareVersion: 0x41}, "narrow"},
+		{"single sub category", DeviceInfo{DevCat: DevCat{0x01, 0x2e}}, "fanlinc"},
+		{"no match", DeviceInfo{DevCat: DevCat{0x02, 0x2e}}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := ""
+			if constructor, found := dr.Lookup(test.input); found {
+				device, _ := constructor(test.input, nil, 0)
+				got = device.(*namedDevice).name
+			}
+
+			if got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+
+	if _, found := dr.Find(Category(1)); !found {
+		t.Errorf("expected to find the constructor for the whole category")
+	}
+
+	dr.Delete(Category(1))
+	if _, found := dr.Lookup(DeviceInfo{DevCat: DevCat{0x01, 0x2e}}); found {
+		t.Errorf("expected all of the category's constructors to be deleted")
+	}
+}
+
 func mkPayload(buf ...byte) []byte {
 	return append(buf, make([]byte, 14-len(buf))...)
 }
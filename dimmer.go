@@ -15,6 +15,7 @@
 package insteon
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -115,6 +116,14 @@ type Dimmer interface {
 
 	// DimmerConfig queries the dimmer and returns the configuration
 	DimmerConfig() (DimmerConfig, error)
+
+	// OnLevelContext is OnLevel that stops waiting for the device when
+	// the context is done
+	OnLevelContext(ctx context.Context, level int) error
+
+	// DimmerConfigContext is DimmerConfig that stops waiting for the
+	// device when the context is done
+	DimmerConfigContext(ctx context.Context) (DimmerConfig, error)
 }
 
 // LinkableDimmer represents a Dimmer switch that supports remote
@@ -128,6 +137,7 @@ type dimmer struct {
 	Switch
 	timeout         time.Duration
 	firmwareVersion FirmwareVersion
+	name            string
 }
 
 type linkableDimmer struct {
@@ -139,10 +149,15 @@ type linkableDimmer struct {
 // appropriately for the given firmware version.  All dimmers are switches, so
 // the first argument is a Switch object used to compose the new dimmer
 func NewDimmer(sw Switch, timeout time.Duration, firmwareVersion FirmwareVersion) Dimmer {
+	return newDimmer(sw, timeout, firmwareVersion, "Dimmer")
+}
+
+func newDimmer(sw Switch, timeout time.Duration, firmwareVersion FirmwareVersion, name string) Dimmer {
 	dd := &dimmer{
 		Switch:          sw,
 		timeout:         timeout,
 		firmwareVersion: firmwareVersion,
+		name:            name,
 	}
 	if linkable, ok := sw.(LinkableSwitch); ok {
 		return &linkableDimmer{LinkableSwitch: linkable, dimmer: dd}
@@ -151,7 +166,11 @@ func NewDimmer(sw Switch, timeout time.Duration, firmwareVersion FirmwareVersion
 }
 
 func (dd *dimmer) OnLevel(level int) error {
-	_, err := dd.SendCommand(CmdLightOn.SubCommand(level), nil)
+	return dd.OnLevelContext(context.Background(), level)
+}
+
+func (dd *dimmer) OnLevelContext(ctx context.Context, level int) error {
+	_, err := dd.SendCommandContext(ctx, CmdLightOn.SubCommand(level), nil)
 	return err
 }
 
@@ -216,7 +235,7 @@ func (dd *dimmer) OffAtRamp(ramp int) (err error) {
 }
 
 func (dd *dimmer) String() string {
-	return fmt.Sprintf("Dimmer (%s)", dd.Address())
+	return fmt.Sprintf("%s (%s)", dd.name, dd.Address())
 }
 
 func (dd *dimmer) SetDefaultRamp(rate int) error {
@@ -232,14 +251,18 @@ func (dd *dimmer) SetDefaultOnLevel(level int) error {
 }
 
 func (dd *dimmer) DimmerConfig() (config DimmerConfig, err error) {
+	return dd.DimmerConfigContext(context.Background())
+}
+
+func (dd *dimmer) DimmerConfigContext(ctx context.Context) (config DimmerConfig, err error) {
 	// The documentation talks about D1 (payload[0]) being the button/group number, but my
 	// SwitchLinc dimmers all return the same information regardless of
 	// the value of D1.  I think D1 is maybe only relevant on KeyPadLinc dimmers.
 	//
 	// D2 is 0x00 for requests
-	_, err = dd.Switch.SendCommand(CmdExtendedGetSet, []byte{0x01, 0x00})
+	_, err = dd.Switch.SendCommandContext(ctx, CmdExtendedGetSet, []byte{0x01, 0x00})
 	if err == nil {
-		err = Receive(dd.Switch, dd.timeout, func(msg *Message) error {
+		err = ReceiveContext(ctx, dd.Switch, dd.timeout, func(msg *Message) error {
 			if msg.Command == CmdExtendedGetSet {
 				err = config.UnmarshalBinary(msg.Payload)
 				if err == nil {
@@ -0,0 +1,269 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+func init() {
+	for _, subCategory := range []SubCategory{0x02, 0x05, 0x06, 0x11, 0x14, 0x15} {
+		Devices.Register(0x10, doorSensorFactory, SubCategories(subCategory, subCategory))
+	}
+}
+
+func doorSensorFactory(info DeviceInfo, device Device, timeout time.Duration) (Device, error) {
+	return newDoorSensor(device, timeout, productName(info.DevCat, "Door Sensor")), nil
+}
+
+// Door sensor groups.  Low battery is reported on the LowBatteryGroup
+const (
+	// DoorOpenGroup is turned on when the door is opened.  In one
+	// group mode it is also turned off when the door is closed
+	DoorOpenGroup Group = 1
+
+	// DoorClosedGroup is turned on when the door is closed in two
+	// group mode
+	DoorClosedGroup Group = 2
+
+	// DoorHeartbeatGroup receives the periodic heartbeat
+	DoorHeartbeatGroup Group = 4
+)
+
+var doorSensorGroups = sensorGroups{
+	DoorOpenGroup:      {eventType: SensorOpen},
+	DoorClosedGroup:    {eventType: SensorOpen, inverted: true},
+	LowBatteryGroup:    {eventType: SensorLowBattery},
+	DoorHeartbeatGroup: {eventType: SensorHeartbeat},
+}
+
+// DoorSensorFlags are the operating flags of a door sensor
+type DoorSensorFlags byte
+
+// Door sensor operating flags
+const (
+	// DoorTwoGroups reports closing the door on the DoorClosedGroup
+	// rather than turning off the DoorOpenGroup
+	DoorTwoGroups DoorSensorFlags = 0x02
+
+	// DoorRepeatOpen causes the sensor to repeat the open command every
+	// five minutes while the door is open
+	DoorRepeatOpen DoorSensorFlags = 0x04
+
+	// DoorRepeatClosed causes the sensor to repeat the closed command
+	// every five minutes while the door is closed
+	DoorRepeatClosed DoorSensorFlags = 0x08
+)
+
+const (
+	// doorHeartbeatUnit is the resolution of the heartbeat interval
+	doorHeartbeatUnit = 5 * time.Minute
+
+	// doorHeartbeatDefault is the heartbeat interval used when the
+	// interval is set to zero
+	doorHeartbeatDefault = 24 * time.Hour
+)
+
+// DoorSensorConfig is the configuration retrieved and set with the
+// door sensor's extended get/set command
+type DoorSensorConfig struct {
+	// HeartbeatInterval is the time between heartbeats.  The interval
+	// is in 5 minute increments up to 255 increments, or 24 hours
+	HeartbeatInterval time.Duration
+
+	Flags DoorSensorFlags
+}
+
+// UnmarshalBinary will parse the byte buffer into the receiver
+func (dsc *DoorSensorConfig) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 14 {
+		return ErrBufferTooShort
+	}
+	dsc.Flags = DoorSensorFlags(buf[5])
+	dsc.HeartbeatInterval = time.Duration(buf[6]) * doorHeartbeatUnit
+	if buf[6] == 0 {
+		dsc.HeartbeatInterval = doorHeartbeatDefault
+	}
+	return nil
+}
+
+// heartbeat returns the heartbeat interval as it is sent to the sensor
+func (dsc *DoorSensorConfig) heartbeat() (byte, error) {
+	if dsc.HeartbeatInterval == doorHeartbeatDefault {
+		return 0x00, nil
+	}
+
+	interval := dsc.HeartbeatInterval / doorHeartbeatUnit
+	if dsc.HeartbeatInterval%doorHeartbeatUnit != 0 || interval < 1 || interval > 0xff {
+		return 0, ErrIllegalValue
+	}
+	return byte(interval), nil
+}
+
+// DoorSensorState is the last known state of a door sensor as
+// determined from the events passed to DecodeEvent
+type DoorSensorState struct {
+	// Open is true if the door was last reported open
+	Open bool
+
+	// Updated is the time the door was last reported open or closed.
+	// Updated is the zero time if the state is not yet known
+	Updated time.Time
+
+	// LowBattery is true once the sensor has reported a low battery
+	LowBattery bool
+
+	// Heartbeat is the time of the last heartbeat
+	Heartbeat time.Time
+}
+
+// DoorSensor is any device that satisfies the following interface.  Door
+// sensors are battery powered and sleep most of the time, so they will usually
+// only respond to commands shortly after sending a message (or after the set
+// button is pressed)
+type DoorSensor interface {
+	Device
+
+	// Config queries the sensor and returns the configuration
+	Config() (DoorSensorConfig, error)
+
+	// SetConfig writes the heartbeat interval and flags to the sensor
+	SetConfig(config DoorSensorConfig) error
+
+	// DecodeEvent interprets an Event sent by the sensor and updates
+	// the sensor's state.  The return value indicates whether the event
+	// was sent by the sensor to one of the door sensor groups
+	DecodeEvent(event *Event) (*SensorEvent, bool)
+
+	// State returns the last known state of the sensor
+	State() DoorSensorState
+
+	// ConfigContext is Config that stops waiting for the device when
+	// the context is done
+	ConfigContext(ctx context.Context) (DoorSensorConfig, error)
+
+	// SetConfigContext is SetConfig that stops waiting for the device
+	// when the context is done
+	SetConfigContext(ctx context.Context, config DoorSensorConfig) error
+}
+
+// LinkableDoorSensor represents a DoorSensor that supports remote
+// linking (Insteon Engine version 2 or higher)
+type LinkableDoorSensor interface {
+	DoorSensor
+	Linkable
+}
+
+type doorSensor struct {
+	Device
+	timeout time.Duration
+	name    string
+	now     func() time.Time
+
+	mu    sync.Mutex
+	state DoorSensorState
+}
+
+type linkableDoorSensor struct {
+	LinkableDevice
+	*doorSensor
+}
+
+// NewDoorSensor is a factory function that will return the correctly
+// configured door sensor based on the underlying device
+func NewDoorSensor(device Device, timeout time.Duration) DoorSensor {
+	return newDoorSensor(device, timeout, "Door Sensor")
+}
+
+func newDoorSensor(device Device, timeout time.Duration, name string) DoorSensor {
+	ds := &doorSensor{Device: device, timeout: timeout, name: name, now: time.Now}
+	if linkable, ok := device.(LinkableDevice); ok {
+		return &linkableDoorSensor{LinkableDevice: linkable, doorSensor: ds}
+	}
+	return ds
+}
+
+func (ds *doorSensor) Config() (DoorSensorConfig, error) {
+	return ds.ConfigContext(context.Background())
+}
+
+func (ds *doorSensor) ConfigContext(ctx context.Context) (config DoorSensorConfig, err error) {
+	// D1 is the group (0x00) and D2 is 0x00 for requests
+	_, err = ds.SendCommandContext(ctx, CmdExtendedGetSet, []byte{0x00, 0x00})
+	if err == nil {
+		err = ReceiveContext(ctx, ds, ds.timeout, func(msg *Message) error {
+			// D2 is 0x01 for responses
+			if msg.Command == CmdExtendedGetSet && len(msg.Payload) > 1 && msg.Payload[1] == 0x01 {
+				err := config.UnmarshalBinary(msg.Payload)
+				if err == nil {
+					err = ErrReceiveComplete
+				}
+				return err
+			}
+			return nil
+		})
+	}
+	return config, err
+}
+
+func (ds *doorSensor) SetConfig(config DoorSensorConfig) error {
+	return ds.SetConfigContext(context.Background(), config)
+}
+
+func (ds *doorSensor) SetConfigContext(ctx context.Context, config DoorSensorConfig) error {
+	heartbeat, err := config.heartbeat()
+
+	// D2 selects the value that D3 is written to
+	for _, set := range [][]byte{
+		{0x00, 0x05, byte(config.Flags)},
+		{0x00, 0x06, heartbeat},
+	} {
+		if err == nil {
+			_, err = ds.SendCommandContext(ctx, CmdExtendedGetSet, set)
+		}
+	}
+	return err
+}
+
+func (ds *doorSensor) DecodeEvent(event *Event) (*SensorEvent, bool) {
+	sensorEvent, found := doorSensorGroups.decode(ds.Address(), event)
+	if found {
+		ds.mu.Lock()
+		switch sensorEvent.Type {
+		case SensorOpen:
+			ds.state.Open = sensorEvent.Active
+			ds.state.Updated = ds.now()
+		case SensorLowBattery:
+			ds.state.LowBattery = sensorEvent.Active
+		case SensorHeartbeat:
+			ds.state.Heartbeat = ds.now()
+		}
+		ds.mu.Unlock()
+	}
+	return sensorEvent, found
+}
+
+func (ds *doorSensor) State() DoorSensorState {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.state
+}
+
+func (ds *doorSensor) String() string {
+	return fmt.Sprintf("%s (%s)", ds.name, ds.Address())
+}
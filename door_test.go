@@ -0,0 +1,214 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDoorSensorFactory(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input Device
+		want  reflect.Type
+	}{
+		{"Door Sensor", &i1Device{}, reflect.TypeOf(&doorSensor{})},
+		{"Linkable Door Sensor", &i2Device{}, reflect.TypeOf(&linkableDoorSensor{})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := reflect.TypeOf(NewDoorSensor(test.input, 0))
+			if test.want != got {
+				t.Errorf("want type %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDoorSensorRegistry(t *testing.T) {
+	tests := []struct {
+		input DevCat
+		want  string
+	}{
+		{DevCat{0x10, 0x02}, "2843-222 TriggerLinc Open/Close Sensor (915 MHz) (01.02.03)"},
+		{DevCat{0x10, 0x11}, "2845-222 Hidden Door Sensor (01.02.03)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			device, err := Devices.New(DeviceInfo{DevCat: test.input, EngineVersion: VerI2Cs}, &testConnection{addr: Address{1, 2, 3}}, 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if _, ok := device.(LinkableDoorSensor); !ok {
+				t.Errorf("want LinkableDoorSensor got %T", device)
+			}
+
+			if got := fmt.Sprintf("%v", device); test.want != got {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDoorSensorConfig(t *testing.T) {
+	tests := []struct {
+		desc    string
+		input   []byte
+		want    DoorSensorConfig
+		wantErr error
+	}{
+		{"default heartbeat", mkPayload(0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00), DoorSensorConfig{HeartbeatInterval: 24 * time.Hour, Flags: DoorTwoGroups}, nil},
+		{"hourly heartbeat", mkPayload(0x00, 0x01, 0x00, 0x00, 0x00, 0x0c, 0x0c), DoorSensorConfig{HeartbeatInterval: time.Hour, Flags: DoorRepeatOpen | DoorRepeatClosed}, nil},
+		{"short buffer", nil, DoorSensorConfig{}, ErrBufferTooShort},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := DoorSensorConfig{}
+			err := got.UnmarshalBinary(test.input)
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			} else if got != test.want {
+				t.Errorf("want %+v got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDoorSensorGetConfig(t *testing.T) {
+	conn := &testConnection{recvCh: make(chan *Message, 1), sendCh: make(chan *Message, 1), ackCh: make(chan *Message, 1)}
+	ds := NewDoorSensor(conn, time.Millisecond)
+	conn.recvCh <- &Message{Command: CmdExtendedGetSet, Payload: mkPayload(0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x18)}
+	conn.ackCh <- TestAck
+
+	want := DoorSensorConfig{HeartbeatInterval: 2 * time.Hour, Flags: DoorTwoGroups | DoorRepeatOpen}
+	got, err := ds.Config()
+	sent := <-conn.sendCh
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if got != want {
+		t.Errorf("want config %+v got %+v", want, got)
+	}
+
+	if sent.Command != CmdExtendedGetSet || !reflect.DeepEqual([]byte{0x00, 0x00}, sent.Payload) {
+		t.Errorf("want config request got %v % x", sent.Command, sent.Payload)
+	}
+}
+
+func TestDoorSensorSetConfig(t *testing.T) {
+	tests := []struct {
+		desc    string
+		input   DoorSensorConfig
+		want    [][]byte
+		wantErr error
+	}{
+		{"two groups", DoorSensorConfig{HeartbeatInterval: time.Hour, Flags: DoorTwoGroups}, [][]byte{{0x00, 0x05, 0x02}, {0x00, 0x06, 0x0c}}, nil},
+		{"default heartbeat", DoorSensorConfig{HeartbeatInterval: 24 * time.Hour}, [][]byte{{0x00, 0x05, 0x00}, {0x00, 0x06, 0x00}}, nil},
+		{"heartbeat too short", DoorSensorConfig{HeartbeatInterval: time.Minute}, nil, ErrIllegalValue},
+		{"heartbeat not a multiple", DoorSensorConfig{HeartbeatInterval: 7 * time.Minute}, nil, ErrIllegalValue},
+		{"heartbeat too long", DoorSensorConfig{HeartbeatInterval: 48 * time.Hour}, nil, ErrIllegalValue},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			conn := &testConnection{sendCh: make(chan *Message, 2), ackCh: make(chan *Message, 2)}
+			for range test.want {
+				conn.ackCh <- TestAck
+			}
+
+			err := NewDoorSensor(conn, time.Millisecond).SetConfig(test.input)
+			if err != test.wantErr {
+				t.Fatalf("want error %v got %v", test.wantErr, err)
+			}
+
+			for _, want := range test.want {
+				sent := <-conn.sendCh
+				if sent.Command != CmdExtendedGetSet || !reflect.DeepEqual(want, sent.Payload) {
+					t.Errorf("want %v % x got %v % x", CmdExtendedGetSet, want, sent.Command, sent.Payload)
+				}
+			}
+		})
+	}
+}
+
+func TestDoorSensorDecodeEvent(t *testing.T) {
+	src := Address{1, 2, 3}
+	tests := []struct {
+		desc      string
+		input     *Event
+		want      *SensorEvent
+		wantFound bool
+	}{
+		{"opened", &Event{Src: src, Group: DoorOpenGroup, Action: ActionOn}, &SensorEvent{Src: src, Type: SensorOpen, Active: true}, true},
+		{"closed one group", &Event{Src: src, Group: DoorOpenGroup, Action: ActionOff}, &SensorEvent{Src: src, Type: SensorOpen}, true},
+		{"closed two groups", &Event{Src: src, Group: DoorClosedGroup, Action: ActionOn}, &SensorEvent{Src: src, Type: SensorOpen}, true},
+		{"low battery", &Event{Src: src, Group: LowBatteryGroup, Action: ActionOn}, &SensorEvent{Src: src, Type: SensorLowBattery, Active: true}, true},
+		{"heartbeat on", &Event{Src: src, Group: DoorHeartbeatGroup, Action: ActionOn}, &SensorEvent{Src: src, Type: SensorHeartbeat, Active: true}, true},
+		{"heartbeat off", &Event{Src: src, Group: DoorHeartbeatGroup, Action: ActionOff}, &SensorEvent{Src: src, Type: SensorHeartbeat, Active: true}, true},
+		{"unknown group", &Event{Src: src, Group: 5, Action: ActionOn}, nil, false},
+		{"other device", &Event{Src: Address{4, 5, 6}, Group: DoorOpenGroup, Action: ActionOn}, nil, false},
+	}
+
+	ds := NewDoorSensor(&testConnection{addr: src}, 0)
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, found := ds.DecodeEvent(test.input)
+			if found != test.wantFound {
+				t.Errorf("want found %v got %v", test.wantFound, found)
+			} else if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDoorSensorState(t *testing.T) {
+	src := Address{1, 2, 3}
+	opened := time.Date(2019, 3, 14, 15, 9, 26, 0, time.UTC)
+	closed := opened.Add(time.Minute)
+	heartbeat := closed.Add(time.Hour)
+
+	ds := NewDoorSensor(&testConnection{addr: src}, 0).(*doorSensor)
+	if got := ds.State(); got != (DoorSensorState{}) {
+		t.Errorf("want unknown state got %+v", got)
+	}
+
+	tests := []struct {
+		now   time.Time
+		input *Event
+		want  DoorSensorState
+	}{
+		{opened, &Event{Src: src, Group: DoorOpenGroup, Action: ActionOn}, DoorSensorState{Open: true, Updated: opened}},
+		{closed, &Event{Src: src, Group: DoorClosedGroup, Action: ActionOn}, DoorSensorState{Updated: closed}},
+		{heartbeat, &Event{Src: src, Group: DoorHeartbeatGroup, Action: ActionOff}, DoorSensorState{Updated: closed, Heartbeat: heartbeat}},
+		{heartbeat, &Event{Src: src, Group: LowBatteryGroup, Action: ActionOn}, DoorSensorState{Updated: closed, Heartbeat: heartbeat, LowBattery: true}},
+		{heartbeat.Add(time.Hour), &Event{Src: Address{4, 5, 6}, Group: DoorOpenGroup, Action: ActionOn}, DoorSensorState{Updated: closed, Heartbeat: heartbeat, LowBattery: true}},
+	}
+
+	for i, test := range tests {
+		now := test.now
+		ds.now = func() time.Time { return now }
+		ds.DecodeEvent(test.input)
+		if got := ds.State(); got != test.want {
+			t.Errorf("tests[%d] want %+v got %+v", i, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventDedupWindow is the amount of time during which a copy of a message
+// is considered a duplicate.  Insteon devices retransmit broadcasts and follow
+// all-link broadcasts with cleanup messages, so a single button press usually
+// arrives as several messages
+var EventDedupWindow = 2 * time.Second
+
+// Action is what happened at the device that sent an Event
+type Action int
+
+// Actions that are decoded from broadcast and cleanup messages
+const (
+	ActionOn            Action = iota // paddle tapped on
+	ActionOff                         // paddle tapped off
+	ActionOnFast                      // paddle double tapped on
+	ActionOffFast                     // paddle double tapped off
+	ActionStartBrighten               // paddle held on
+	ActionStartDim                    // paddle held off
+	ActionStopChange                  // held paddle released
+	ActionStatusChange                // device status changed
+	ActionHeartbeat                   // periodic heartbeat
+	ActionSetButton                   // set button pressed
+
+	// thermostat actions are decoded by Thermostat.DecodeMessage
+	ActionTemperatureChange  // thermostat ambient temperature changed
+	ActionHumidityChange     // thermostat humidity changed
+	ActionModeChange         // thermostat system or fan mode changed
+	ActionCoolSetpointChange // thermostat cooling setpoint changed
+	ActionHeatSetpointChange // thermostat heating setpoint changed
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionOn:
+		return "On"
+	case ActionOff:
+		return "Off"
+	case ActionOnFast:
+		return "On Fast"
+	case ActionOffFast:
+		return "Off Fast"
+	case ActionStartBrighten:
+		return "Start Brighten"
+	case ActionStartDim:
+		return "Start Dim"
+	case ActionStopChange:
+		return "Stop Change"
+	case ActionStatusChange:
+		return "Status Change"
+	case ActionHeartbeat:
+		return "Heartbeat"
+	case ActionSetButton:
+		return "Set Button"
+	case ActionTemperatureChange:
+		return "Temperature Change"
+	case ActionHumidityChange:
+		return "Humidity Change"
+	case ActionModeChange:
+		return "Mode Change"
+	case ActionCoolSetpointChange:
+		return "Cool Setpoint Change"
+	case ActionHeatSetpointChange:
+		return "Heat Setpoint Change"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// Event is an unsolicited message from a device, such as the all-link
+// broadcast a switch sends when its paddle is pressed.  Level is the
+// resulting on level (0-255) for on and off actions, and the command 2
+// value for status changes, heartbeats and thermostat changes
+type Event struct {
+	Src    Address
+	Group  Group
+	Action Action
+	Level  int
+}
+
+func (e *Event) String() string {
+	return sprintf("%s Group(%d) %s Level(%d)", e.Src, e.Group, e.Action, e.Level)
+}
+
+// lightingActions maps the command 1 byte of lighting group commands
+// to their actions
+var lightingActions = map[byte]Action{
+	CmdLightOn[1]:         ActionOn,
+	CmdLightOff[1]:        ActionOff,
+	CmdLightOnFast[1]:     ActionOnFast,
+	CmdLightOffFast[1]:    ActionOffFast,
+	CmdLightStopManual[1]: ActionStopChange,
+}
+
+// DecodeEvent decodes broadcast, all-link broadcast and all-link cleanup
+// messages into an Event.  The return value indicates whether the message
+// was an event.  Direct messages are never events, the status changes that
+// thermostats send directly are decoded by Thermostat.DecodeMessage
+func DecodeEvent(msg *Message) (*Event, bool) {
+	event := &Event{Src: msg.Src}
+	switch msg.Flags.Type() {
+	case MsgTypeAllLinkBroadcast:
+		event.Group = Group(msg.Dst[2])
+		if msg.Command[1] == CmdLightStartManual[1] {
+			event.Action = ActionStartDim
+			if msg.Command[2] == 0x01 {
+				event.Action = ActionStartBrighten
+			}
+			return event, true
+		}
+	case MsgTypeAllLinkCleanup:
+		// cleanups carry the group in command 2, leaving no room for
+		// the direction of a manual change
+		event.Group = Group(msg.Command[2])
+	case MsgTypeBroadcast:
+		switch msg.Command[1] {
+		case CmdSetButtonPressedResponder[1], CmdSetButtonPressedController[1]:
+			event.Action = ActionSetButton
+			return event, true
+		}
+	default:
+		return nil, false
+	}
+
+	if action, found := lightingActions[msg.Command[1]]; found {
+		event.Action = action
+		if action == ActionOn || action == ActionOnFast {
+			event.Level = 0xff
+		}
+		return event, msg.Flags.Type() != MsgTypeBroadcast
+	}
+
+	switch msg.Command[1] {
+	case CmdBroadCastStatusChange[1]:
+		event.Action = ActionStatusChange
+	case CmdHeartbeat[1]:
+		event.Action = ActionHeartbeat
+	default:
+		return nil, false
+	}
+
+	if msg.Flags.Type() != MsgTypeAllLinkCleanup {
+		event.Level = int(msg.Command[2])
+	}
+	return event, true
+}
+
+// EventStream decodes every message published on a Bus into Events.
+// Duplicate events, such as retransmitted broadcasts and the cleanup
+// messages that follow an all-link broadcast, are delivered only once
+type EventStream struct {
+	bus  *Bus
+	msgs <-chan *Message
+	ch   chan *Event
+}
+
+// NewEventStream subscribes to the bus and starts decoding events.  Close
+// should be called when the stream is no longer needed
+func NewEventStream(bus *Bus) *EventStream {
+	es := &EventStream{
+		bus:  bus,
+		msgs: bus.SubscribeAll(),
+		ch:   make(chan *Event, BusBufLen),
+	}
+	go es.readLoop()
+	return es
+}
+
+func (es *EventStream) readLoop() {
+	dedup := newEventDedup()
+	for msg := range es.msgs {
+		event, ok := DecodeEvent(msg)
+		if !ok {
+			continue
+		}
+
+		if dedup.duplicate(msg, event, time.Now()) {
+			Log.Tracef("Dropping duplicate event %v", event)
+			continue
+		}
+
+		select {
+		case es.ch <- event:
+		default:
+			Log.Infof("Event stream is full, dropping %v", event)
+		}
+	}
+	close(es.ch)
+}
+
+type dedupKey struct {
+	src    Address
+	group  Group
+	action Action
+}
+
+type dedupEntry struct {
+	level    int
+	hopsLeft int
+	cleanup  bool
+	at       time.Time
+}
+
+// eventDedup recognizes copies of the same logical message.  A copy is
+// either a retransmission (which never has more hops left than the last
+// copy seen) or the cleanup that follows an all-link broadcast.  Once the
+// cleanup arrives the broadcast is complete, so the next broadcast is a
+// new event even if it is identical.  Messages that differ in level are
+// never duplicates
+type eventDedup struct {
+	seen map[dedupKey]*dedupEntry
+}
+
+func newEventDedup() *eventDedup {
+	return &eventDedup{seen: make(map[dedupKey]*dedupEntry)}
+}
+
+func (ed *eventDedup) duplicate(msg *Message, event *Event, now time.Time) bool {
+	for k, entry := range ed.seen {
+		if now.Sub(entry.at) > EventDedupWindow {
+			delete(ed.seen, k)
+		}
+	}
+
+	k := dedupKey{event.Src, event.Group, event.Action}
+	entry, found := ed.seen[k]
+	hopsLeft := msg.Flags.TTL()
+	if msg.Flags.Type() == MsgTypeAllLinkCleanup {
+		// cleanups do not carry the level of the broadcast
+		if found {
+			entry.cleanup = true
+			entry.at = now
+			return true
+		}
+		ed.seen[k] = &dedupEntry{level: event.Level, hopsLeft: hopsLeft, cleanup: true, at: now}
+		return false
+	}
+
+	if found && !entry.cleanup && entry.level == event.Level && hopsLeft <= entry.hopsLeft {
+		entry.hopsLeft = hopsLeft
+		entry.at = now
+		return true
+	}
+	ed.seen[k] = &dedupEntry{level: event.Level, hopsLeft: hopsLeft, at: now}
+	return false
+}
+
+// Events returns the channel that receives the decoded events.  The channel
+// is closed once the stream is closed
+func (es *EventStream) Events() <-chan *Event {
+	return es.ch
+}
+
+// Close unsubscribes the stream from the bus
+func (es *EventStream) Close() {
+	es.bus.Unsubscribe(es.msgs)
+}
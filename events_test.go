@@ -0,0 +1,223 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func eventMsg(t MessageType, src Address, dst Address, cmd1, cmd2 byte) *Message {
+	return &Message{Src: src, Dst: dst, Flags: Flag(t, false, 3, 3), Command: Command{0x00, cmd1, cmd2}}
+}
+
+func TestActionString(t *testing.T) {
+	tests := []struct {
+		input Action
+		want  string
+	}{
+		{ActionOn, "On"},
+		{ActionOffFast, "Off Fast"},
+		{ActionStartBrighten, "Start Brighten"},
+		{ActionSetButton, "Set Button"},
+		{ActionHeatSetpointChange, "Heat Setpoint Change"},
+		{Action(42), "Action(42)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.String(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	src := Address{1, 2, 3}
+	plm := Address{4, 5, 6}
+	tests := []struct {
+		desc  string
+		input *Message
+		want  *Event
+	}{
+		{"on broadcast", eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x11, 0x00), &Event{src, 1, ActionOn, 255}},
+		{"off broadcast", eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 2}, 0x13, 0x00), &Event{src, 2, ActionOff, 0}},
+		{"on fast broadcast", eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x12, 0x00), &Event{src, 1, ActionOnFast, 255}},
+		{"off fast cleanup", eventMsg(MsgTypeAllLinkCleanup, src, plm, 0x14, 0x03), &Event{src, 3, ActionOffFast, 0}},
+		{"start brighten", eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x17, 0x01), &Event{src, 1, ActionStartBrighten, 0}},
+		{"start dim", eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x17, 0x00), &Event{src, 1, ActionStartDim, 0}},
+		{"stop change", eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x18, 0x00), &Event{src, 1, ActionStopChange, 0}},
+		{"status change", eventMsg(MsgTypeBroadcast, src, Address{1, 32, 65}, 0x27, 0x80), &Event{src, 0, ActionStatusChange, 0x80}},
+		{"heartbeat", eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 4}, 0x04, 0x11), &Event{src, 4, ActionHeartbeat, 0x11}},
+		{"set button", eventMsg(MsgTypeBroadcast, src, Address{1, 32, 65}, 0x01, 0x00), &Event{src, 0, ActionSetButton, 0}},
+		{"direct", eventMsg(MsgTypeDirect, src, plm, 0x11, 0xff), nil},
+		{"thermostat report", eventMsg(MsgTypeDirect, src, plm, 0x6e, 0x8c), nil},
+		{"thermostat ack", eventMsg(MsgTypeDirectAck, src, plm, 0x6e, 0x8c), nil},
+		{"ack", eventMsg(MsgTypeDirectAck, src, plm, 0x11, 0xff), nil},
+		{"manual change cleanup", eventMsg(MsgTypeAllLinkCleanup, src, plm, 0x17, 0x01), nil},
+		{"unknown broadcast", eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x2e, 0x00), nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, found := DecodeEvent(test.input)
+			if found != (test.want != nil) {
+				t.Errorf("want found %v got %v", test.want != nil, found)
+			} else if found && !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	src := Address{1, 2, 3}
+	bus := NewBus()
+	defer bus.Close()
+	stream := NewEventStream(bus)
+
+	// a retransmitted broadcast and the cleanup that follows are
+	// the same button press
+	bus.Publish(eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x11, 0x00))
+	bus.Publish(eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x11, 0x00))
+	bus.Publish(eventMsg(MsgTypeDirect, src, Address{4, 5, 6}, 0x19, 0x00))
+	bus.Publish(eventMsg(MsgTypeAllLinkCleanup, src, Address{4, 5, 6}, 0x11, 0x01))
+	bus.Publish(eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x13, 0x00))
+
+	for _, want := range []*Event{{src, 1, ActionOn, 255}, {src, 1, ActionOff, 0}} {
+		select {
+		case got := <-stream.Events():
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("want %v got %v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+
+	stream.Close()
+	select {
+	case event, open := <-stream.Events():
+		if open {
+			t.Errorf("want closed stream got %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("timed out waiting for stream to close")
+	}
+}
+
+func TestEventDedup(t *testing.T) {
+	src := Address{1, 2, 3}
+	group := Address{0, 0, 1}
+	responder := Address{4, 5, 6}
+	hops := func(msg *Message, hopsLeft uint8) *Message {
+		msg.Flags = Flag(msg.Flags.Type(), false, hopsLeft, 3)
+		return msg
+	}
+
+	tests := []struct {
+		desc  string
+		input []*Message
+		want  []bool
+	}{
+		{"retransmitted broadcast", []*Message{
+			eventMsg(MsgTypeAllLinkBroadcast, src, group, 0x11, 0x00),
+			hops(eventMsg(MsgTypeAllLinkBroadcast, src, group, 0x11, 0x00), 2),
+			hops(eventMsg(MsgTypeAllLinkBroadcast, src, group, 0x11, 0x00), 1),
+		}, []bool{false, true, true}},
+		{"broadcast and cleanup", []*Message{
+			eventMsg(MsgTypeAllLinkBroadcast, src, group, 0x11, 0x00),
+			eventMsg(MsgTypeAllLinkCleanup, src, responder, 0x11, 0x01),
+			eventMsg(MsgTypeAllLinkCleanup, src, responder, 0x11, 0x01),
+		}, []bool{false, true, true}},
+		{"second press after cleanup", []*Message{
+			eventMsg(MsgTypeAllLinkBroadcast, src, group, 0x11, 0x00),
+			eventMsg(MsgTypeAllLinkCleanup, src, responder, 0x11, 0x01),
+			eventMsg(MsgTypeAllLinkBroadcast, src, group, 0x11, 0x00),
+			eventMsg(MsgTypeAllLinkCleanup, src, responder, 0x11, 0x01),
+		}, []bool{false, true, false, true}},
+		{"new transmission has more hops", []*Message{
+			hops(eventMsg(MsgTypeAllLinkBroadcast, src, group, 0x11, 0x00), 1),
+			eventMsg(MsgTypeAllLinkBroadcast, src, group, 0x11, 0x00),
+		}, []bool{false, false}},
+		{"cleanup without broadcast", []*Message{
+			eventMsg(MsgTypeAllLinkCleanup, src, responder, 0x11, 0x01),
+			eventMsg(MsgTypeAllLinkBroadcast, src, group, 0x11, 0x00),
+		}, []bool{false, false}},
+		{"status change levels", []*Message{
+			eventMsg(MsgTypeBroadcast, src, group, 0x27, 0x40),
+			eventMsg(MsgTypeBroadcast, src, group, 0x27, 0x80),
+			hops(eventMsg(MsgTypeBroadcast, src, group, 0x27, 0x80), 2),
+		}, []bool{false, false, true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			dedup := newEventDedup()
+			now := time.Now()
+			for i, msg := range test.input {
+				event, _ := DecodeEvent(msg)
+				if got := dedup.duplicate(msg, event, now); got != test.want[i] {
+					t.Errorf("message %d want duplicate %v got %v", i, test.want[i], got)
+				}
+			}
+		})
+	}
+
+	// copies outside of the window are new events
+	dedup := newEventDedup()
+	msg := eventMsg(MsgTypeAllLinkBroadcast, src, group, 0x11, 0x00)
+	event, _ := DecodeEvent(msg)
+	now := time.Now()
+	dedup.duplicate(msg, event, now)
+	if dedup.duplicate(msg, event, now.Add(EventDedupWindow+time.Millisecond)) {
+		t.Errorf("want copy outside of the window to be a new event")
+	}
+}
+
+func TestEventStreamRepeatedEvents(t *testing.T) {
+	src := Address{1, 2, 3}
+	bus := NewBus()
+	defer bus.Close()
+	stream := NewEventStream(bus)
+	defer stream.Close()
+
+	// two presses of the same paddle, each followed by its cleanup,
+	// and two status changes to different levels
+	bus.Publish(eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x11, 0x00))
+	bus.Publish(eventMsg(MsgTypeAllLinkCleanup, src, Address{4, 5, 6}, 0x11, 0x01))
+	bus.Publish(eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x11, 0x00))
+	bus.Publish(eventMsg(MsgTypeAllLinkCleanup, src, Address{4, 5, 6}, 0x11, 0x01))
+	bus.Publish(eventMsg(MsgTypeBroadcast, src, Address{0, 0, 1}, 0x27, 0x40))
+	bus.Publish(eventMsg(MsgTypeBroadcast, src, Address{0, 0, 1}, 0x27, 0x80))
+
+	for _, want := range []*Event{
+		{src, 1, ActionOn, 255},
+		{src, 1, ActionOn, 255},
+		{src, 0, ActionStatusChange, 0x40},
+		{src, 0, ActionStatusChange, 0x80},
+	} {
+		select {
+		case got := <-stream.Events():
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("want %v got %v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package insteon
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -45,6 +46,12 @@ func newI1Device(connection Connection, timeout time.Duration) *i1Device {
 // length message is used to deliver the commands. The command bytes from the
 // response ack are returned as well as any error
 func (i1 *i1Device) SendCommand(command Command, payload []byte) (response Command, err error) {
+	return i1.SendCommandContext(context.Background(), command, payload)
+}
+
+// SendCommandContext is the same as SendCommand except that waiting for
+// the device to respond is abandoned when the context is done
+func (i1 *i1Device) SendCommandContext(ctx context.Context, command Command, payload []byte) (response Command, err error) {
 	i1.cmdMutex.Lock()
 	defer i1.cmdMutex.Unlock()
 	flags := StandardDirectMessage
@@ -57,7 +64,7 @@ func (i1 *i1Device) SendCommand(command Command, payload []byte) (response Comma
 		}
 	}
 
-	ack, err := i1.Connection.Send(&Message{
+	ack, err := i1.Connection.SendContext(ctx, &Message{
 		Flags:   flags,
 		Command: command,
 		Payload: payload,
@@ -159,5 +166,11 @@ func (i1 *i1Device) String() string {
 // Receive waits for the next message from the device.  Receive
 // always returns, but may return with an error (such as ErrReadTimeout)
 func (i1 *i1Device) Receive() (*Message, error) {
-	return errLookup(i1.Connection.Receive())
+	return i1.ReceiveContext(context.Background())
+}
+
+// ReceiveContext is the same as Receive except that it returns as soon
+// as the context is done
+func (i1 *i1Device) ReceiveContext(ctx context.Context) (*Message, error) {
+	return errLookup(i1.Connection.ReceiveContext(ctx))
 }
@@ -15,6 +15,7 @@
 package insteon
 
 import (
+	"context"
 	"time"
 )
 
@@ -46,7 +47,13 @@ func (i2cs *i2CsDevice) RemoveListener(ch <-chan *Message) {
 // equivalent to holding down the set button until the device
 // beeps and the indicator light starts flashing
 func (i2cs *i2CsDevice) EnterLinkingMode(group Group) (err error) {
-	return i2cs.linkingMode(CmdEnterLinkingModeExt.SubCommand(int(group)), make([]byte, 14)...)
+	return i2cs.EnterLinkingModeContext(context.Background(), group)
+}
+
+// EnterLinkingModeContext is the same as EnterLinkingMode except that
+// waiting for the device is abandoned when the context is done
+func (i2cs *i2CsDevice) EnterLinkingModeContext(ctx context.Context, group Group) (err error) {
+	return i2cs.linkingMode(ctx, CmdEnterLinkingModeExt.SubCommand(int(group)), make([]byte, 14)...)
 	/*i2cs.Lock()
 	defer i2cs.Unlock()
 	setButton := i2cs.AddListener(MsgTypeBroadcast, CmdSetButtonPressedController, CmdSetButtonPressedResponder)
@@ -84,12 +91,18 @@ func checksum(cmd Command, buf []byte) byte {
 // sent, then the checksum of the message is computed and set as
 // the last byte of the payload
 func (i2cs *i2CsDevice) Send(msg *Message) (ack *Message, err error) {
+	return i2cs.SendContext(context.Background(), msg)
+}
+
+// SendContext is the same as Send except that waiting for the Ack/Nak
+// is abandoned when the context is done
+func (i2cs *i2CsDevice) SendContext(ctx context.Context, msg *Message) (ack *Message, err error) {
 	// set checksum
 	if msg.Flags.Extended() {
 		l := len(msg.Payload)
 		msg.Payload[l-1] = checksum(msg.Command, msg.Payload)
 	}
-	return i2cs.connection.Send(msg)
+	return i2cs.connection.SendContext(ctx, msg)
 }
 
 func i2csErrLookup(msg *Message, err error) (*Message, error) {
@@ -119,16 +132,28 @@ func i2csErrLookup(msg *Message, err error) (*Message, error) {
 // with the appropriate broadcast message, or if the local system
 // doesn't receive it
 func (i2cs *i2CsDevice) IDRequest() (FirmwareVersion, DevCat, error) {
+	return i2cs.IDRequestContext(context.Background())
+}
+
+// IDRequestContext is the same as IDRequest except that the request is
+// cancelled when the context is done
+func (i2cs *i2CsDevice) IDRequestContext(ctx context.Context) (FirmwareVersion, DevCat, error) {
 	i2cs.Lock()
 	defer i2cs.Unlock()
 
-	return i2cs.connection.IDRequest()
+	return i2cs.connection.IDRequestContext(ctx)
 }
 
 // Receive waits for the next message from the device.  Receive
 // always returns, but may return with an error (such as ErrReadTimeout)
 func (i2cs *i2CsDevice) Receive() (*Message, error) {
-	return i2csErrLookup(i2cs.connection.Receive())
+	return i2cs.ReceiveContext(context.Background())
+}
+
+// ReceiveContext is the same as Receive except that it returns as soon
+// as the context is done
+func (i2cs *i2CsDevice) ReceiveContext(ctx context.Context) (*Message, error) {
+	return i2csErrLookup(i2cs.connection.ReceiveContext(ctx))
 }
 
 // Lock the connection so that it not usable by other go routines.  This is
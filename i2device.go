@@ -15,6 +15,7 @@
 package insteon
 
 import (
+	"context"
 	"time"
 )
 
@@ -34,14 +35,14 @@ func newI2Device(connection Connection, timeout time.Duration) *i2Device {
 	return i2
 }
 
-func (i2 *i2Device) linkingMode(cmd Command, payload ...byte) error {
+func (i2 *i2Device) linkingMode(ctx context.Context, cmd Command, payload ...byte) error {
 	i2.Lock()
 	defer i2.Unlock()
 	setButton := i2.AddListener(MsgTypeBroadcast, CmdSetButtonPressedController, CmdSetButtonPressedResponder)
 	defer i2.RemoveListener(setButton)
-	_, err := i2.SendCommand(cmd, payload)
+	_, err := i2.SendCommandContext(ctx, cmd, payload)
 	if err == nil {
-		_, err = readFromCh(setButton, i2.timeout)
+		_, err = readFromCh(ctx, setButton, i2.timeout)
 	}
 	return err
 }
@@ -52,7 +53,13 @@ func (i2 *i2Device) linkingMode(cmd Command, payload ...byte) error {
 // device to enter linking mode is the responder.  LinkingMode
 // is usually indicated by a flashing GREEN LED on the device
 func (i2 *i2Device) EnterLinkingMode(group Group) error {
-	return i2.linkingMode(CmdEnterLinkingMode.SubCommand(int(group)))
+	return i2.EnterLinkingModeContext(context.Background(), group)
+}
+
+// EnterLinkingModeContext is the same as EnterLinkingMode except that
+// waiting for the device is abandoned when the context is done
+func (i2 *i2Device) EnterLinkingModeContext(ctx context.Context, group Group) error {
+	return i2.linkingMode(ctx, CmdEnterLinkingMode.SubCommand(int(group)))
 }
 
 // EnterUnlinkingMode puts a controller device into unlinking mode
@@ -63,12 +70,24 @@ func (i2 *i2Device) EnterLinkingMode(group Group) error {
 // pressing the set button again until the device beeps again. UnlinkingMode
 // is usually indicated by a flashing RED LED on the device
 func (i2 *i2Device) EnterUnlinkingMode(group Group) error {
-	return i2.linkingMode(CmdEnterUnlinkingMode.SubCommand(int(group)))
+	return i2.EnterUnlinkingModeContext(context.Background(), group)
+}
+
+// EnterUnlinkingModeContext is the same as EnterUnlinkingMode except that
+// waiting for the device is abandoned when the context is done
+func (i2 *i2Device) EnterUnlinkingModeContext(ctx context.Context, group Group) error {
+	return i2.linkingMode(ctx, CmdEnterUnlinkingMode.SubCommand(int(group)))
 }
 
 // ExitLinkingMode takes a controller out of linking/unlinking mode.
 func (i2 *i2Device) ExitLinkingMode() error {
-	return extractError(i2.SendCommand(CmdExitLinkingMode, nil))
+	return i2.ExitLinkingModeContext(context.Background())
+}
+
+// ExitLinkingModeContext is the same as ExitLinkingMode except that
+// waiting for the device is abandoned when the context is done
+func (i2 *i2Device) ExitLinkingModeContext(ctx context.Context) error {
+	return extractError(i2.SendCommandContext(ctx, CmdExitLinkingMode, nil))
 }
 
 // String returns the string "I2 Device (<address>)" where <address> is the destination
@@ -0,0 +1,413 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func init() {
+	for _, subCategory := range []SubCategory{0x09, 0x0c, 0x1b, 0x1c, 0x41, 0x42} {
+		Devices.Register(0x01, keypadDimmerFactory, SubCategories(subCategory, subCategory))
+	}
+
+	for _, subCategory := range []SubCategory{0x05, 0x0f, 0x1e, 0x2c} {
+		Devices.Register(0x02, keypadSwitchFactory, SubCategories(subCategory, subCategory))
+	}
+}
+
+func keypadDimmerFactory(info DeviceInfo, device Device, timeout time.Duration) (Device, error) {
+	name := productName(info.DevCat, "Keypad Dimmer")
+	return newKeypadDimmer(newDimmer(newSwitch(device, timeout, name), timeout, info.FirmwareVersion, name), timeout), nil
+}
+
+func keypadSwitchFactory(info DeviceInfo, device Device, timeout time.Duration) (Device, error) {
+	return newKeypadSwitch(newSwitch(device, timeout, productName(info.DevCat, "Keypad Switch")), timeout), nil
+}
+
+// KeypadButtons is the number of buttons on an 8 button keypad.  The
+// buttons of a 6 button keypad are numbered 1 and 3 through 6, with
+// button 1 being the large on button and 2 being the large off button
+const KeypadButtons = 8
+
+// KeypadLEDs is the state of a keypad's button LEDs.  Bit 0 is the LED
+// of button 1 and bit 7 is the LED of button 8
+type KeypadLEDs byte
+
+// On indicates whether the LED of the given button is lit
+func (kl KeypadLEDs) On(button int) bool {
+	return kl&(1<<uint(button-1)) != 0
+}
+
+// Set returns a copy of the receiver with the LED of the given button
+// changed
+func (kl KeypadLEDs) Set(button int, on bool) KeypadLEDs {
+	if on {
+		return kl | (1 << uint(button-1))
+	}
+	return kl &^ (1 << uint(button-1))
+}
+
+// ButtonMode determines which commands a keypad button sends when pressed
+type ButtonMode int
+
+// Keypad button modes
+const (
+	ButtonToggle  ButtonMode = iota // alternate between on and off
+	ButtonOnOnly                    // always send on
+	ButtonOffOnly                   // always send off
+)
+
+func (bm ButtonMode) String() string {
+	switch bm {
+	case ButtonToggle:
+		return "Toggle"
+	case ButtonOnOnly:
+		return "On Only"
+	case ButtonOffOnly:
+		return "Off Only"
+	}
+	return fmt.Sprintf("ButtonMode(%d)", int(bm))
+}
+
+// ButtonEvent is an Event sent by a keypad when one of its buttons
+// is pressed
+type ButtonEvent struct {
+	Src    Address
+	Button int
+	Action Action
+}
+
+func (be *ButtonEvent) String() string {
+	return sprintf("%s Button(%d) %s", be.Src, be.Button, be.Action)
+}
+
+// Keypad is any device with a KeypadLinc style set of buttons.  Each button
+// controls the all-link group with the same number.  Since keypads are also
+// dimmers or switches, Keypad is combined with those interfaces in
+// KeypadDimmer and KeypadSwitch
+type Keypad interface {
+	// LEDs queries the keypad and returns the state of the button LEDs
+	LEDs() (KeypadLEDs, error)
+
+	// SetLEDs sets the state of all of the button LEDs
+	SetLEDs(leds KeypadLEDs) error
+
+	// SetButtonLED changes the state of a single button's LED
+	SetButtonLED(button int, on bool) error
+
+	// SetButtonOnLevel sets the level (0-255) that the load is turned on to
+	// when the given button is pressed
+	SetButtonOnLevel(button int, level int) error
+
+	// SetButtonRamp sets the ramp rate used when the given button is pressed
+	SetButtonRamp(button int, ramp int) error
+
+	// SetButtonModes sets the mode of every button.  The first mode is the
+	// mode of button 1 and any buttons without a mode are set to toggle
+	SetButtonModes(modes ...ButtonMode) error
+
+	// SetRadioGroups configures groups of buttons where pressing one
+	// button turns off the rest of the buttons in the group.  Buttons
+	// that are not in any group are removed from their radio group
+	SetRadioGroups(groups ...[]int) error
+
+	// SetBacklight sets the brightness (0-127) of the button backlight
+	SetBacklight(level int) error
+
+	// DecodeEvent interprets an Event sent by the keypad.  The return
+	// value indicates whether the event was a button press
+	DecodeEvent(event *Event) (*ButtonEvent, bool)
+
+	// LEDsContext is LEDs that stops waiting for the device when
+	// the context is done
+	LEDsContext(ctx context.Context) (KeypadLEDs, error)
+
+	// SetLEDsContext is SetLEDs that stops waiting for the device
+	// when the context is done
+	SetLEDsContext(ctx context.Context, leds KeypadLEDs) error
+
+	// SetButtonLEDContext is SetButtonLED that stops waiting for the
+	// device when the context is done
+	SetButtonLEDContext(ctx context.Context, button int, on bool) error
+
+	// SetButtonOnLevelContext is SetButtonOnLevel that stops waiting for
+	// the device when the context is done
+	SetButtonOnLevelContext(ctx context.Context, button int, level int) error
+
+	// SetButtonRampContext is SetButtonRamp that stops waiting for the
+	// device when the context is done
+	SetButtonRampContext(ctx context.Context, button int, ramp int) error
+
+	// SetButtonModesContext is SetButtonModes that stops waiting for the
+	// device when the context is done
+	SetButtonModesContext(ctx context.Context, modes ...ButtonMode) error
+
+	// SetRadioGroupsContext is SetRadioGroups that stops waiting for the
+	// device when the context is done
+	SetRadioGroupsContext(ctx context.Context, groups ...[]int) error
+
+	// SetBacklightContext is SetBacklight that stops waiting for the
+	// device when the context is done
+	SetBacklightContext(ctx context.Context, level int) error
+}
+
+// KeypadDimmer is a KeypadLinc dimmer
+type KeypadDimmer interface {
+	Dimmer
+	Keypad
+}
+
+// LinkableKeypadDimmer represents a KeypadDimmer that supports remote
+// linking (Insteon Engine version 2 or higher)
+type LinkableKeypadDimmer interface {
+	KeypadDimmer
+	Linkable
+}
+
+// KeypadSwitch is a KeypadLinc on/off switch
+type KeypadSwitch interface {
+	Switch
+	Keypad
+}
+
+// LinkableKeypadSwitch represents a KeypadSwitch that supports remote
+// linking (Insteon Engine version 2 or higher)
+type LinkableKeypadSwitch interface {
+	KeypadSwitch
+	Linkable
+}
+
+type keypad struct {
+	device  Device
+	timeout time.Duration
+}
+
+type keypadDimmer struct {
+	Dimmer
+	*keypad
+}
+
+type linkableKeypadDimmer struct {
+	LinkableDimmer
+	*keypad
+}
+
+type keypadSwitch struct {
+	Switch
+	*keypad
+}
+
+type linkableKeypadSwitch struct {
+	LinkableSwitch
+	*keypad
+}
+
+// NewKeypadDimmer is a factory function that will return a keypad dimmer
+// composed of the given dimmer
+func NewKeypadDimmer(dimmer Dimmer, timeout time.Duration) KeypadDimmer {
+	return newKeypadDimmer(dimmer, timeout)
+}
+
+func newKeypadDimmer(dimmer Dimmer, timeout time.Duration) KeypadDimmer {
+	kp := &keypad{device: dimmer, timeout: timeout}
+	if linkable, ok := dimmer.(LinkableDimmer); ok {
+		return &linkableKeypadDimmer{LinkableDimmer: linkable, keypad: kp}
+	}
+	return &keypadDimmer{Dimmer: dimmer, keypad: kp}
+}
+
+// NewKeypadSwitch is a factory function that will return a keypad switch
+// composed of the given switch
+func NewKeypadSwitch(sw Switch, timeout time.Duration) KeypadSwitch {
+	return newKeypadSwitch(sw, timeout)
+}
+
+func newKeypadSwitch(sw Switch, timeout time.Duration) KeypadSwitch {
+	kp := &keypad{device: sw, timeout: timeout}
+	if linkable, ok := sw.(LinkableSwitch); ok {
+		return &linkableKeypadSwitch{LinkableSwitch: linkable, keypad: kp}
+	}
+	return &keypadSwitch{Switch: sw, keypad: kp}
+}
+
+func validButton(button int) bool {
+	return 1 <= button && button <= KeypadButtons
+}
+
+// set sends an extended set command.  D1 is the button (or 0x01 for settings
+// that apply to the whole keypad), D2 selects the setting and D3 is the value
+func (kp *keypad) set(ctx context.Context, button int, setting byte, value byte) error {
+	_, err := kp.device.SendCommandContext(ctx, CmdExtendedGetSet, []byte{byte(button), setting, value})
+	return err
+}
+
+func (kp *keypad) LEDs() (KeypadLEDs, error) {
+	return kp.LEDsContext(context.Background())
+}
+
+func (kp *keypad) LEDsContext(ctx context.Context) (leds KeypadLEDs, err error) {
+	// a status request with cmd2 set to 0x01 returns the LED bitmask
+	// instead of the load level
+	response, err := kp.device.SendCommandContext(ctx, CmdLightStatusRequest.SubCommand(0x01), nil)
+	if err == nil {
+		leds = KeypadLEDs(response[2])
+	}
+	return leds, err
+}
+
+func (kp *keypad) SetLEDs(leds KeypadLEDs) error {
+	return kp.SetLEDsContext(context.Background(), leds)
+}
+
+func (kp *keypad) SetLEDsContext(ctx context.Context, leds KeypadLEDs) error {
+	return kp.set(ctx, 0x01, 0x09, byte(leds))
+}
+
+func (kp *keypad) SetButtonLED(button int, on bool) error {
+	return kp.SetButtonLEDContext(context.Background(), button, on)
+}
+
+func (kp *keypad) SetButtonLEDContext(ctx context.Context, button int, on bool) error {
+	if !validButton(button) {
+		return ErrIllegalValue
+	}
+
+	leds, err := kp.LEDsContext(ctx)
+	if err == nil {
+		err = kp.SetLEDsContext(ctx, leds.Set(button, on))
+	}
+	return err
+}
+
+func (kp *keypad) SetButtonOnLevel(button int, level int) error {
+	return kp.SetButtonOnLevelContext(context.Background(), button, level)
+}
+
+func (kp *keypad) SetButtonOnLevelContext(ctx context.Context, button int, level int) error {
+	if !validButton(button) || level < 0 || level > 0xff {
+		return ErrIllegalValue
+	}
+	return kp.set(ctx, button, 0x06, byte(level))
+}
+
+func (kp *keypad) SetButtonRamp(button int, ramp int) error {
+	return kp.SetButtonRampContext(context.Background(), button, ramp)
+}
+
+func (kp *keypad) SetButtonRampContext(ctx context.Context, button int, ramp int) error {
+	if !validButton(button) || ramp < 0 || ramp > 0x1f {
+		return ErrIllegalValue
+	}
+	return kp.set(ctx, button, 0x05, byte(ramp))
+}
+
+func (kp *keypad) SetButtonModes(modes ...ButtonMode) error {
+	return kp.SetButtonModesContext(context.Background(), modes...)
+}
+
+func (kp *keypad) SetButtonModesContext(ctx context.Context, modes ...ButtonMode) error {
+	if len(modes) > KeypadButtons {
+		return ErrIllegalValue
+	}
+
+	// buttons in the non-toggle mask always send the same command, which
+	// is on if the button is also in the on mask and off otherwise
+	nonToggle := byte(0)
+	on := byte(0)
+	for i, mode := range modes {
+		switch mode {
+		case ButtonToggle:
+		case ButtonOnOnly:
+			nonToggle |= 1 << uint(i)
+			on |= 1 << uint(i)
+		case ButtonOffOnly:
+			nonToggle |= 1 << uint(i)
+		default:
+			return ErrIllegalValue
+		}
+	}
+
+	err := kp.set(ctx, 0x01, 0x08, nonToggle)
+	if err == nil {
+		err = kp.set(ctx, 0x01, 0x0b, on)
+	}
+	return err
+}
+
+func (kp *keypad) SetRadioGroups(groups ...[]int) error {
+	return kp.SetRadioGroupsContext(context.Background(), groups...)
+}
+
+func (kp *keypad) SetRadioGroupsContext(ctx context.Context, groups ...[]int) error {
+	// the off mask of each button lists the buttons that are turned
+	// off when it is pressed
+	masks := make([]byte, KeypadButtons)
+	grouped := byte(0)
+	for _, group := range groups {
+		mask := byte(0)
+		for _, button := range group {
+			if !validButton(button) {
+				return ErrIllegalValue
+			}
+			mask |= 1 << uint(button-1)
+		}
+
+		// buttons can only be in one radio group
+		if grouped&mask != 0 {
+			return ErrIllegalValue
+		}
+		grouped |= mask
+
+		for _, button := range group {
+			masks[button-1] = mask &^ (1 << uint(button-1))
+		}
+	}
+
+	var err error
+	for i := 0; i < len(masks) && err == nil; i++ {
+		err = kp.set(ctx, i+1, 0x03, masks[i])
+	}
+	return err
+}
+
+func (kp *keypad) SetBacklight(level int) error {
+	return kp.SetBacklightContext(context.Background(), level)
+}
+
+func (kp *keypad) SetBacklightContext(ctx context.Context, level int) error {
+	if level < 0 || level > 0x7f {
+		return ErrIllegalValue
+	}
+	return kp.set(ctx, 0x01, 0x07, byte(level))
+}
+
+func (kp *keypad) DecodeEvent(event *Event) (*ButtonEvent, bool) {
+	if event.Src != kp.device.Address() || !validButton(int(event.Group)) {
+		return nil, false
+	}
+
+	switch event.Action {
+	case ActionOn, ActionOff, ActionOnFast, ActionOffFast, ActionStartBrighten, ActionStartDim, ActionStopChange:
+		return &ButtonEvent{Src: event.Src, Button: int(event.Group), Action: event.Action}, true
+	}
+	return nil, false
+}
+
+func (kp *keypad) String() string {
+	return fmt.Sprintf("%v", kp.device)
+}
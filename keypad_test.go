@@ -0,0 +1,280 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeypadFactory(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input Device
+		want  reflect.Type
+	}{
+		{"Keypad Dimmer", NewDimmer(NewSwitch(&i1Device{}, 0), 0, 0), reflect.TypeOf(&keypadDimmer{})},
+		{"Linkable Keypad Dimmer", NewDimmer(NewSwitch(&i2Device{}, 0), 0, 0), reflect.TypeOf(&linkableKeypadDimmer{})},
+		{"Keypad Switch", NewSwitch(&i1Device{}, 0), reflect.TypeOf(&keypadSwitch{})},
+		{"Linkable Keypad Switch", NewSwitch(&i2Device{}, 0), reflect.TypeOf(&linkableKeypadSwitch{})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var got reflect.Type
+			if dimmer, ok := test.input.(Dimmer); ok {
+				got = reflect.TypeOf(NewKeypadDimmer(dimmer, 0))
+			} else {
+				got = reflect.TypeOf(NewKeypadSwitch(test.input.(Switch), 0))
+			}
+
+			if test.want != got {
+				t.Errorf("want type %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestKeypadRegistry(t *testing.T) {
+	tests := []struct {
+		input DevCat
+		want  string
+	}{
+		{DevCat{0x01, 0x1c}, "2486DWH8 KeypadLinc Dimmer (01.02.03)"},
+		{DevCat{0x01, 0x41}, "2334-222 Keypad Dimmer Dual-Band, 8 Button (01.02.03)"},
+		{DevCat{0x02, 0x0f}, "2486SWH6 KeypadLinc On/Off (01.02.03)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			device, err := Devices.New(DeviceInfo{DevCat: test.input, EngineVersion: VerI2Cs}, &testConnection{addr: Address{1, 2, 3}}, 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if _, ok := device.(Keypad); !ok {
+				t.Errorf("want Keypad got %T", device)
+			}
+
+			if _, ok := device.(Linkable); !ok {
+				t.Errorf("want Linkable got %T", device)
+			}
+
+			if test.input.Category() == 0x01 {
+				if _, ok := device.(Dimmer); !ok {
+					t.Errorf("want Dimmer got %T", device)
+				}
+			}
+
+			if got := fmt.Sprintf("%v", device); test.want != got {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+
+	// other dimmers are not keypads
+	device, _ := Devices.New(DeviceInfo{DevCat: DevCat{0x01, 0x20}, EngineVersion: VerI2Cs}, &testConnection{}, 0)
+	if _, ok := device.(Keypad); ok {
+		t.Errorf("want device not to be a Keypad got %T", device)
+	}
+}
+
+func TestKeypadLEDs(t *testing.T) {
+	leds := KeypadLEDs(0x81)
+	for button := 1; button <= KeypadButtons; button++ {
+		if want := button == 1 || button == 8; leds.On(button) != want {
+			t.Errorf("want button %d on %v got %v", button, want, leds.On(button))
+		}
+	}
+
+	if got := leds.Set(3, true).Set(8, false); got != KeypadLEDs(0x05) {
+		t.Errorf("want %02x got %02x", 0x05, byte(got))
+	}
+}
+
+func TestButtonModeString(t *testing.T) {
+	tests := []struct {
+		input ButtonMode
+		want  string
+	}{
+		{ButtonToggle, "Toggle"},
+		{ButtonOnOnly, "On Only"},
+		{ButtonOffOnly, "Off Only"},
+		{ButtonMode(42), "ButtonMode(42)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.String(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+
+	event := &ButtonEvent{Src: Address{1, 2, 3}, Button: 4, Action: ActionOn}
+	if want, got := "01.02.03 Button(4) On", event.String(); want != got {
+		t.Errorf("want %q got %q", want, got)
+	}
+}
+
+func TestKeypadCommands(t *testing.T) {
+	tests := []*commandTest{
+		{"SetLEDs", func(d Device) error { return d.(Keypad).SetLEDs(0x81) }, CmdExtendedGetSet, nil, []byte{0x01, 0x09, 0x81}},
+		{"SetButtonOnLevel", func(d Device) error { return d.(Keypad).SetButtonOnLevel(3, 0x7f) }, CmdExtendedGetSet, nil, []byte{0x03, 0x06, 0x7f}},
+		{"SetButtonOnLevel bad button", func(d Device) error { return d.(Keypad).SetButtonOnLevel(9, 0x7f) }, CmdExtendedGetSet, ErrIllegalValue, nil},
+		{"SetButtonOnLevel bad level", func(d Device) error { return d.(Keypad).SetButtonOnLevel(1, 256) }, CmdExtendedGetSet, ErrIllegalValue, nil},
+		{"SetButtonRamp", func(d Device) error { return d.(Keypad).SetButtonRamp(5, 0x1c) }, CmdExtendedGetSet, nil, []byte{0x05, 0x05, 0x1c}},
+		{"SetButtonRamp bad ramp", func(d Device) error { return d.(Keypad).SetButtonRamp(5, 0x20) }, CmdExtendedGetSet, ErrIllegalValue, nil},
+		{"SetBacklight", func(d Device) error { return d.(Keypad).SetBacklight(0x3f) }, CmdExtendedGetSet, nil, []byte{0x01, 0x07, 0x3f}},
+		{"SetBacklight bad level", func(d Device) error { return d.(Keypad).SetBacklight(0x80) }, CmdExtendedGetSet, ErrIllegalValue, nil},
+	}
+
+	testDeviceCommands(t, func(conn *testConnection) Device {
+		return NewKeypadDimmer(NewDimmer(NewSwitch(conn, time.Nanosecond), time.Nanosecond, 0), time.Nanosecond)
+	}, tests)
+}
+
+// testKeypadSets runs the callback and returns the payloads of the
+// commands sent to the keypad
+func testKeypadSets(t *testing.T, acks []*Message, cb func(Keypad) error) ([][]byte, error) {
+	t.Helper()
+	conn := &testConnection{sendCh: make(chan *Message, len(acks)), ackCh: make(chan *Message, len(acks))}
+	for _, ack := range acks {
+		conn.ackCh <- ack
+	}
+
+	err := cb(NewKeypadSwitch(NewSwitch(conn, time.Millisecond), time.Millisecond))
+	close(conn.sendCh)
+	var payloads [][]byte
+	for msg := range conn.sendCh {
+		payloads = append(payloads, msg.Payload)
+	}
+	return payloads, err
+}
+
+func TestKeypadButtonLEDs(t *testing.T) {
+	ledAck := &Message{Command: CmdLightStatusRequest.SubCommand(0x21), Flags: StandardDirectAck}
+	payloads, err := testKeypadSets(t, []*Message{ledAck}, func(kp Keypad) error {
+		leds, err := kp.LEDs()
+		if err == nil && leds != 0x21 {
+			t.Errorf("want LEDs %02x got %02x", 0x21, byte(leds))
+		}
+		return err
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual([][]byte{nil}, payloads) {
+		t.Errorf("want status request got % x", payloads)
+	}
+
+	payloads, err = testKeypadSets(t, []*Message{ledAck, TestAck}, func(kp Keypad) error { return kp.SetButtonLED(2, true) })
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if want := [][]byte{nil, {0x01, 0x09, 0x23}}; !reflect.DeepEqual(want, payloads) {
+		t.Errorf("want % x got % x", want, payloads)
+	}
+
+	if _, err = testKeypadSets(t, nil, func(kp Keypad) error { return kp.SetButtonLED(0, true) }); err != ErrIllegalValue {
+		t.Errorf("want error %v got %v", ErrIllegalValue, err)
+	}
+}
+
+func TestKeypadButtonModes(t *testing.T) {
+	tests := []struct {
+		desc    string
+		input   []ButtonMode
+		want    [][]byte
+		wantErr error
+	}{
+		{"all toggle", nil, [][]byte{{0x01, 0x08, 0x00}, {0x01, 0x0b, 0x00}}, nil},
+		{"mixed", []ButtonMode{ButtonToggle, ButtonOnOnly, ButtonOffOnly, ButtonOnOnly}, [][]byte{{0x01, 0x08, 0x0e}, {0x01, 0x0b, 0x0a}}, nil},
+		{"bad mode", []ButtonMode{ButtonMode(42)}, nil, ErrIllegalValue},
+		{"too many buttons", make([]ButtonMode, 9), nil, ErrIllegalValue},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			payloads, err := testKeypadSets(t, []*Message{TestAck, TestAck}, func(kp Keypad) error { return kp.SetButtonModes(test.input...) })
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			} else if !reflect.DeepEqual(test.want, payloads) {
+				t.Errorf("want % x got % x", test.want, payloads)
+			}
+		})
+	}
+}
+
+func TestKeypadRadioGroups(t *testing.T) {
+	acks := make([]*Message, KeypadButtons)
+	for i := range acks {
+		acks[i] = TestAck
+	}
+
+	tests := []struct {
+		desc    string
+		input   [][]int
+		want    [][]byte
+		wantErr error
+	}{
+		{"no groups", nil, [][]byte{{1, 0x03, 0}, {2, 0x03, 0}, {3, 0x03, 0}, {4, 0x03, 0}, {5, 0x03, 0}, {6, 0x03, 0}, {7, 0x03, 0}, {8, 0x03, 0}}, nil},
+		{"two groups", [][]int{{3, 4, 5}, {7, 8}}, [][]byte{{1, 0x03, 0}, {2, 0x03, 0}, {3, 0x03, 0x18}, {4, 0x03, 0x14}, {5, 0x03, 0x0c}, {6, 0x03, 0}, {7, 0x03, 0x80}, {8, 0x03, 0x40}}, nil},
+		{"bad button", [][]int{{3, 9}}, nil, ErrIllegalValue},
+		{"button in two groups", [][]int{{3, 4}, {4, 5}}, nil, ErrIllegalValue},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			payloads, err := testKeypadSets(t, acks, func(kp Keypad) error { return kp.SetRadioGroups(test.input...) })
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			} else if !reflect.DeepEqual(test.want, payloads) {
+				t.Errorf("want % x got % x", test.want, payloads)
+			}
+		})
+	}
+}
+
+func TestKeypadDecodeEvent(t *testing.T) {
+	src := Address{1, 2, 3}
+	tests := []struct {
+		desc      string
+		input     *Event
+		want      *ButtonEvent
+		wantFound bool
+	}{
+		{"button 1 on", &Event{Src: src, Group: 1, Action: ActionOn}, &ButtonEvent{Src: src, Button: 1, Action: ActionOn}, true},
+		{"button 8 off", &Event{Src: src, Group: 8, Action: ActionOff}, &ButtonEvent{Src: src, Button: 8, Action: ActionOff}, true},
+		{"button 3 held", &Event{Src: src, Group: 3, Action: ActionStartBrighten}, &ButtonEvent{Src: src, Button: 3, Action: ActionStartBrighten}, true},
+		{"button 3 released", &Event{Src: src, Group: 3, Action: ActionStopChange}, &ButtonEvent{Src: src, Button: 3, Action: ActionStopChange}, true},
+		{"heartbeat", &Event{Src: src, Group: 1, Action: ActionHeartbeat}, nil, false},
+		{"group 0", &Event{Src: src, Group: 0, Action: ActionOn}, nil, false},
+		{"group 9", &Event{Src: src, Group: 9, Action: ActionOn}, nil, false},
+		{"other device", &Event{Src: Address{4, 5, 6}, Group: 1, Action: ActionOn}, nil, false},
+	}
+
+	kp := NewKeypadSwitch(NewSwitch(&testConnection{addr: src}, 0), 0)
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, found := kp.DecodeEvent(test.input)
+			if found != test.wantFound {
+				t.Errorf("want found %v got %v", test.wantFound, found)
+			} else if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+func init() {
+	for _, subCategory := range []SubCategory{0x08, 0x0b, 0x0c} {
+		Devices.Register(0x10, leakSensorFactory, SubCategories(subCategory, subCategory))
+	}
+}
+
+func leakSensorFactory(info DeviceInfo, device Device, timeout time.Duration) (Device, error) {
+	return newLeakSensor(device, timeout, productName(info.DevCat, "Leak Sensor")), nil
+}
+
+// Leak sensor groups
+const (
+	LeakDryGroup       Group = 1 // on when the sensor becomes dry
+	LeakWetGroup       Group = 2 // on when the sensor becomes wet
+	LeakHeartbeatGroup Group = 4 // daily heartbeat, on when dry and off when wet
+)
+
+var leakSensorGroups = sensorGroups{
+	LeakDryGroup:       {eventType: SensorWet, inverted: true},
+	LeakWetGroup:       {eventType: SensorWet},
+	LeakHeartbeatGroup: {eventType: SensorHeartbeat},
+}
+
+// DefaultLeakHeartbeatWindow is the time allowed between leak sensor
+// heartbeats before CheckHeartbeat reports a missed heartbeat.  The sensor
+// sends a heartbeat once a day, so an extra hour is allowed
+const DefaultLeakHeartbeatWindow = 25 * time.Hour
+
+// LeakSensorState is the last known state of a leak sensor as
+// determined from the events passed to DecodeEvent
+type LeakSensorState struct {
+	// Wet is true if the sensor was last reported wet
+	Wet bool
+
+	// Updated is the time the sensor was last reported wet or dry
+	// (including by a heartbeat).  Updated is the zero time if the
+	// state is not yet known
+	Updated time.Time
+
+	// Heartbeat is the time of the last heartbeat
+	Heartbeat time.Time
+
+	// HeartbeatMissed is true once CheckHeartbeat has reported a missed
+	// heartbeat and until the next heartbeat arrives
+	HeartbeatMissed bool
+}
+
+// LeakSensor is any device that satisfies the following interface.  A
+// missed heartbeat is the absence of a message, so it never appears on an
+// EventStream.  Applications that want to be alerted must call CheckHeartbeat
+// periodically (hourly is plenty for the daily heartbeat)
+type LeakSensor interface {
+	Device
+
+	// DecodeEvent interprets an Event sent by the sensor and updates
+	// the sensor's state.  The return value indicates whether the event
+	// was sent by the sensor to one of the leak sensor groups
+	DecodeEvent(event *Event) (*SensorEvent, bool)
+
+	// State returns the last known state of the sensor
+	State() LeakSensorState
+
+	// SetHeartbeatWindow sets the time allowed between heartbeats
+	SetHeartbeatWindow(window time.Duration)
+
+	// SetClock replaces the function used to get the current time
+	// (time.Now by default) when recording events and checking the
+	// heartbeat
+	SetClock(now func() time.Time)
+
+	// CheckHeartbeat must be called periodically.  If no heartbeat has
+	// been received within the heartbeat window, then a SensorHeartbeatMissed
+	// event is returned.  The event is only returned once per missed
+	// heartbeat.  The window starts with the first call to CheckHeartbeat
+	// if no heartbeat has ever been received
+	CheckHeartbeat() (*SensorEvent, bool)
+}
+
+// LinkableLeakSensor represents a LeakSensor that supports remote
+// linking (Insteon Engine version 2 or higher)
+type LinkableLeakSensor interface {
+	LeakSensor
+	Linkable
+}
+
+type leakSensor struct {
+	Device
+	timeout time.Duration
+	name    string
+	now     func() time.Time
+
+	mu      sync.Mutex
+	window  time.Duration
+	started time.Time
+	state   LeakSensorState
+}
+
+type linkableLeakSensor struct {
+	LinkableDevice
+	*leakSensor
+}
+
+// NewLeakSensor is a factory function that will return the correctly
+// configured leak sensor based on the underlying device
+func NewLeakSensor(device Device, timeout time.Duration) LeakSensor {
+	return newLeakSensor(device, timeout, "Leak Sensor")
+}
+
+func newLeakSensor(device Device, timeout time.Duration, name string) LeakSensor {
+	ls := &leakSensor{Device: device, timeout: timeout, name: name, now: time.Now, window: DefaultLeakHeartbeatWindow}
+	if linkable, ok := device.(LinkableDevice); ok {
+		return &linkableLeakSensor{LinkableDevice: linkable, leakSensor: ls}
+	}
+	return ls
+}
+
+func (ls *leakSensor) DecodeEvent(event *Event) (*SensorEvent, bool) {
+	sensorEvent, found := leakSensorGroups.decode(ls.Address(), event)
+	if found {
+		ls.mu.Lock()
+		switch sensorEvent.Type {
+		case SensorWet:
+			ls.state.Wet = sensorEvent.Active
+			ls.state.Updated = ls.now()
+		case SensorHeartbeat:
+			ls.state.Heartbeat = ls.now()
+			ls.state.HeartbeatMissed = false
+
+			// only the heartbeat group reports wet or dry, other
+			// heartbeats (such as the heartbeat command) leave the
+			// state alone
+			if event.Group == LeakHeartbeatGroup {
+				switch event.Action {
+				case ActionOn, ActionOnFast:
+					ls.state.Wet = false
+					ls.state.Updated = ls.state.Heartbeat
+				case ActionOff, ActionOffFast:
+					ls.state.Wet = true
+					ls.state.Updated = ls.state.Heartbeat
+				}
+			}
+		}
+		ls.mu.Unlock()
+	}
+	return sensorEvent, found
+}
+
+func (ls *leakSensor) State() LeakSensorState {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.state
+}
+
+func (ls *leakSensor) SetHeartbeatWindow(window time.Duration) {
+	ls.mu.Lock()
+	ls.window = window
+	ls.mu.Unlock()
+}
+
+func (ls *leakSensor) SetClock(now func() time.Time) {
+	ls.mu.Lock()
+	ls.now = now
+	ls.mu.Unlock()
+}
+
+func (ls *leakSensor) CheckHeartbeat() (*SensorEvent, bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	now := ls.now()
+	last := ls.state.Heartbeat
+	if last.IsZero() {
+		if ls.started.IsZero() {
+			ls.started = now
+		}
+		last = ls.started
+	}
+
+	if ls.state.HeartbeatMissed || now.Sub(last) < ls.window {
+		return nil, false
+	}
+
+	ls.state.HeartbeatMissed = true
+	return &SensorEvent{Src: ls.Address(), Type: SensorHeartbeatMissed, Active: true}, true
+}
+
+func (ls *leakSensor) String() string {
+	return fmt.Sprintf("%s (%s)", ls.name, ls.Address())
+}
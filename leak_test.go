@@ -0,0 +1,160 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLeakSensorFactory(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input Device
+		want  reflect.Type
+	}{
+		{"Leak Sensor", &i1Device{}, reflect.TypeOf(&leakSensor{})},
+		{"Linkable Leak Sensor", &i2Device{}, reflect.TypeOf(&linkableLeakSensor{})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := reflect.TypeOf(NewLeakSensor(test.input, 0))
+			if test.want != got {
+				t.Errorf("want type %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLeakSensorRegistry(t *testing.T) {
+	device, err := Devices.New(DeviceInfo{DevCat: DevCat{0x10, 0x08}, EngineVersion: VerI2Cs}, &testConnection{addr: Address{1, 2, 3}}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := device.(LinkableLeakSensor); !ok {
+		t.Errorf("want LinkableLeakSensor got %T", device)
+	}
+
+	if want, got := "2852-222 Leak Sensor (01.02.03)", fmt.Sprintf("%v", device); want != got {
+		t.Errorf("want %q got %q", want, got)
+	}
+}
+
+func TestLeakSensorDecodeEvent(t *testing.T) {
+	src := Address{1, 2, 3}
+	tests := []struct {
+		desc      string
+		input     *Event
+		want      *SensorEvent
+		wantFound bool
+	}{
+		{"dry", &Event{Src: src, Group: LeakDryGroup, Action: ActionOn}, &SensorEvent{Src: src, Type: SensorWet}, true},
+		{"wet", &Event{Src: src, Group: LeakWetGroup, Action: ActionOn}, &SensorEvent{Src: src, Type: SensorWet, Active: true}, true},
+		{"heartbeat", &Event{Src: src, Group: LeakHeartbeatGroup, Action: ActionOn}, &SensorEvent{Src: src, Type: SensorHeartbeat, Active: true}, true},
+		{"unknown group", &Event{Src: src, Group: 3, Action: ActionOn}, nil, false},
+		{"other device", &Event{Src: Address{4, 5, 6}, Group: LeakWetGroup, Action: ActionOn}, nil, false},
+	}
+
+	ls := NewLeakSensor(&testConnection{addr: src}, 0)
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, found := ls.DecodeEvent(test.input)
+			if found != test.wantFound {
+				t.Errorf("want found %v got %v", test.wantFound, found)
+			} else if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLeakSensorHeartbeat(t *testing.T) {
+	src := Address{1, 2, 3}
+	start := time.Date(2019, 3, 14, 15, 9, 26, 0, time.UTC)
+	heartbeat := &Event{Src: src, Group: LeakHeartbeatGroup, Action: ActionOn}
+	missed := &SensorEvent{Src: src, Type: SensorHeartbeatMissed, Active: true}
+
+	tests := []struct {
+		desc  string
+		now   time.Duration
+		input *Event
+		want  *SensorEvent
+	}{
+		{"window starts", 0, nil, nil},
+		{"within window", 2 * time.Hour, nil, nil},
+		{"window expires", 3 * time.Hour, nil, missed},
+		{"only reported once", 4 * time.Hour, nil, nil},
+		{"heartbeat arrives", 5 * time.Hour, heartbeat, nil},
+		{"within window of heartbeat", 7 * time.Hour, nil, nil},
+		{"window of heartbeat expires", 8 * time.Hour, nil, missed},
+	}
+
+	ls := NewLeakSensor(&testConnection{addr: src}, 0)
+	ls.SetHeartbeatWindow(3 * time.Hour)
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			now := start.Add(test.now)
+			ls.SetClock(func() time.Time { return now })
+			if test.input != nil {
+				ls.DecodeEvent(test.input)
+				if got := ls.State(); got.Heartbeat != now || got.HeartbeatMissed {
+					t.Errorf("want heartbeat at %v got %+v", now, got)
+				}
+			}
+
+			got, found := ls.CheckHeartbeat()
+			if found != (test.want != nil) {
+				t.Errorf("want found %v got %v", test.want != nil, found)
+			} else if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLeakSensorState(t *testing.T) {
+	src := Address{1, 2, 3}
+	wet := time.Date(2019, 3, 14, 15, 9, 26, 0, time.UTC)
+	dry := wet.Add(time.Hour)
+	wetHeartbeat := dry.Add(time.Hour)
+	heartbeat := wetHeartbeat.Add(time.Hour)
+	dryHeartbeat := heartbeat.Add(time.Hour)
+
+	ls := NewLeakSensor(&testConnection{addr: src}, 0)
+	tests := []struct {
+		now   time.Time
+		input *Event
+		want  LeakSensorState
+	}{
+		{wet, &Event{Src: src, Group: LeakWetGroup, Action: ActionOn}, LeakSensorState{Wet: true, Updated: wet}},
+		{dry, &Event{Src: src, Group: LeakDryGroup, Action: ActionOn}, LeakSensorState{Updated: dry}},
+		{wetHeartbeat, &Event{Src: src, Group: LeakHeartbeatGroup, Action: ActionOff}, LeakSensorState{Wet: true, Updated: wetHeartbeat, Heartbeat: wetHeartbeat}},
+		{heartbeat, &Event{Src: src, Group: 1, Action: ActionHeartbeat}, LeakSensorState{Wet: true, Updated: wetHeartbeat, Heartbeat: heartbeat}},
+		{dryHeartbeat, &Event{Src: src, Group: LeakHeartbeatGroup, Action: ActionOn}, LeakSensorState{Updated: dryHeartbeat, Heartbeat: dryHeartbeat}},
+	}
+
+	for i, test := range tests {
+		now := test.now
+		ls.SetClock(func() time.Time { return now })
+		ls.DecodeEvent(test.input)
+		if got := ls.State(); got != test.want {
+			t.Errorf("tests[%d] want %+v got %+v", i, test.want, got)
+		}
+	}
+}
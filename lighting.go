@@ -29,10 +29,20 @@ func init() {
 	Devices.Register(0x02, switchedDeviceFactory)
 }
 
+// productName returns the product's model and name from the catalog or
+// the default name if the product is unknown
+func productName(devCat DevCat, defaultName string) string {
+	if product, found := Products.Find(devCat); found {
+		return product.String()
+	}
+	return defaultName
+}
+
 func switchedDeviceFactory(info DeviceInfo, device Device, timeout time.Duration) (Device, error) {
-	return NewSwitch(device, timeout), nil
+	return newSwitch(device, timeout, productName(info.DevCat, "Switch")), nil
 }
 
 func dimmableDeviceFactory(info DeviceInfo, device Device, timeout time.Duration) (Device, error) {
-	return NewDimmer(NewSwitch(device, timeout), timeout, info.FirmwareVersion), nil
+	name := productName(info.DevCat, "Dimmer")
+	return newDimmer(newSwitch(device, timeout, name), timeout, info.FirmwareVersion, name), nil
 }
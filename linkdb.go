@@ -15,6 +15,7 @@
 package insteon
 
 import (
+	"context"
 	"time"
 )
 
@@ -137,7 +138,7 @@ func (ldb *linkdb) old() bool {
 	return ldb.age.Add(ldb.timeout).Before(time.Now())
 }
 
-func (ldb *linkdb) refresh() error {
+func (ldb *linkdb) refresh(ctx context.Context) error {
 	if !ldb.old() {
 		return nil
 	}
@@ -147,10 +148,10 @@ func (ldb *linkdb) refresh() error {
 	Log.Debugf("Retrieving Device link database")
 	lastAddress := MemAddress(0)
 	buf, _ := (&linkRequest{Type: readLink, NumRecords: 0}).MarshalBinary()
-	_, err := ldb.device.SendCommand(CmdReadWriteALDB, buf)
+	_, err := ldb.device.SendCommandContext(ctx, CmdReadWriteALDB, buf)
 
 	if err == nil {
-		err = Receive(ldb.device, ldb.timeout, func(msg *Message) error {
+		err = ReceiveContext(ctx, ldb.device, ldb.timeout, func(msg *Message) error {
 			if msg.Flags.Extended() && msg.Command[1] == CmdReadWriteALDB[1] {
 				lr := &linkRequest{}
 				err = lr.UnmarshalBinary(msg.Payload)
@@ -184,19 +185,25 @@ func (ldb *linkdb) refresh() error {
 // Links will retrieve the link-database from the device and
 // return a list of LinkRecords
 func (ldb *linkdb) Links() ([]*LinkRecord, error) {
+	return ldb.LinksContext(context.Background())
+}
+
+// LinksContext is the same as Links except that retrieving the
+// link-database is abandoned when the context is done
+func (ldb *linkdb) LinksContext(ctx context.Context) ([]*LinkRecord, error) {
 	ldb.device.Lock()
 	defer ldb.device.Unlock()
-	err := ldb.refresh()
+	err := ldb.refresh(ctx)
 	return ldb.links, err
 }
 
-func (ldb *linkdb) writeLink(index int, link *LinkRecord) (err error) {
+func (ldb *linkdb) writeLink(ctx context.Context, index int, link *LinkRecord) (err error) {
 	if index > len(ldb.links) {
 		return ErrLinkIndexOutOfRange
 	}
 	memAddress := BaseLinkDBAddress - (MemAddress(index) * LinkRecordSize)
 	buf, _ := (&linkRequest{MemAddress: memAddress, Type: writeLink, Link: link}).MarshalBinary()
-	_, err = ldb.device.SendCommand(CmdReadWriteALDB, buf)
+	_, err = ldb.device.SendCommandContext(ctx, CmdReadWriteALDB, buf)
 	if err == nil {
 		if link.Flags.LastRecord() {
 			// if the last record comes before the end of the cached links then
@@ -218,21 +225,27 @@ func (ldb *linkdb) writeLink(index int, link *LinkRecord) (err error) {
 }
 
 func (ldb *linkdb) WriteLinks(links ...*LinkRecord) (err error) {
+	return ldb.WriteLinksContext(context.Background(), links...)
+}
+
+// WriteLinksContext is the same as WriteLinks except that no more links
+// are written once the context is done
+func (ldb *linkdb) WriteLinksContext(ctx context.Context, links ...*LinkRecord) (err error) {
 	ldb.device.Lock()
 	defer ldb.device.Unlock()
-	return ldb.writeLinks(links...)
+	return ldb.writeLinks(ctx, links...)
 }
 
-func (ldb *linkdb) writeLinks(links ...*LinkRecord) (err error) {
+func (ldb *linkdb) writeLinks(ctx context.Context, links ...*LinkRecord) (err error) {
 	for i := 0; i < len(links) && err == nil; i++ {
 		links[i].Flags.clearLastRecord()
-		err = ldb.writeLink(i, links[i])
+		err = ldb.writeLink(ctx, i, links[i])
 	}
 
 	if err == nil {
 		link := &LinkRecord{}
 		link.Flags.setLastRecord()
-		err = ldb.writeLink(len(ldb.links), link)
+		err = ldb.writeLink(ctx, len(ldb.links), link)
 		if err == nil {
 			ldb.age = time.Now()
 		}
@@ -241,15 +254,21 @@ func (ldb *linkdb) writeLinks(links ...*LinkRecord) (err error) {
 }
 
 func (ldb *linkdb) UpdateLinks(links ...*LinkRecord) (err error) {
+	return ldb.UpdateLinksContext(context.Background(), links...)
+}
+
+// UpdateLinksContext is the same as UpdateLinks except that no more links
+// are written once the context is done
+func (ldb *linkdb) UpdateLinksContext(ctx context.Context, links ...*LinkRecord) (err error) {
 	ldb.device.Lock()
 	defer ldb.device.Unlock()
-	err = ldb.refresh()
+	err = ldb.refresh(ctx)
 
 	if err == nil {
 		for i := 0; err == nil && i < len(links); i++ {
 			if j, found := ldb.index[links[i].id()]; found {
 				if ldb.links[j].Flags != links[i].Flags {
-					err = ldb.writeLink(i, links[i])
+					err = ldb.writeLink(ctx, i, links[i])
 				}
 				links = append(links[0:i], links[i+1:]...)
 				i--
@@ -259,7 +278,7 @@ func (ldb *linkdb) UpdateLinks(links ...*LinkRecord) (err error) {
 		for i := 0; err == nil && i < len(ldb.links); i++ {
 			if ldb.links[i].Flags.Available() && len(links) > 0 {
 				links[0].Flags.clearLastRecord()
-				err = ldb.writeLink(i, links[0])
+				err = ldb.writeLink(ctx, i, links[0])
 				if err == nil {
 					links = links[1:]
 				}
@@ -270,14 +289,14 @@ func (ldb *linkdb) UpdateLinks(links ...*LinkRecord) (err error) {
 			i := len(ldb.links)
 			for _, link := range links {
 				link.Flags.clearLastRecord()
-				err = ldb.writeLink(i, link)
+				err = ldb.writeLink(ctx, i, link)
 				i++
 			}
 
 			if err == nil {
 				link := &LinkRecord{}
 				link.Flags.setLastRecord()
-				err = ldb.writeLink(i, link)
+				err = ldb.writeLink(ctx, i, link)
 			}
 		}
 	}
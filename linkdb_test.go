@@ -16,6 +16,7 @@ package insteon
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
@@ -207,7 +208,7 @@ func TestLinkdbWriteLink(t *testing.T) {
 			conn := &testConnection{sendCh: make(chan *Message, 1), ackCh: make(chan *Message, 1)}
 			conn.ackCh <- TestAck
 			linkdb := linkdb{device: conn, links: test.links}
-			gotErr := linkdb.writeLink(test.inputIndex, test.inputRecord)
+			gotErr := linkdb.writeLink(context.Background(), test.inputIndex, test.inputRecord)
 			if test.wantErr != gotErr {
 				t.Errorf("Want err %v got %v", test.wantErr, gotErr)
 			} else if gotErr == nil {
@@ -0,0 +1,248 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func init() {
+	for _, subCategory := range []SubCategory{0x01, 0x03, 0x04, 0x16} {
+		Devices.Register(0x10, motionSensorFactory, SubCategories(subCategory, subCategory))
+	}
+}
+
+func motionSensorFactory(info DeviceInfo, device Device, timeout time.Duration) (Device, error) {
+	return newMotionSensor(device, timeout, productName(info.DevCat, "Motion Sensor")), nil
+}
+
+// Motion sensor groups
+const (
+	MotionGroup     Group = 1 // on when motion is detected, off once the timeout expires
+	DuskDawnGroup   Group = 2 // on at dusk, off at dawn
+	LowBatteryGroup Group = 3 // on when the battery is low
+)
+
+var motionSensorGroups = sensorGroups{
+	MotionGroup:     {eventType: SensorMotion},
+	DuskDawnGroup:   {eventType: SensorDark},
+	LowBatteryGroup: {eventType: SensorLowBattery},
+}
+
+// MotionSensorFlags are the operating flags of a motion sensor
+type MotionSensorFlags byte
+
+// Motion sensor operating flags
+const (
+	// MotionOnOnly causes the sensor to only send on commands (the motion
+	// group is never turned off)
+	MotionOnOnly MotionSensorFlags = 0x02
+
+	// MotionNightMode causes the sensor to only report motion when it is dark
+	MotionNightMode MotionSensorFlags = 0x04
+
+	// MotionLED enables the LED that flashes when motion is detected
+	MotionLED MotionSensorFlags = 0x08
+)
+
+// motionTimeoutUnit is the resolution of the motion sensor timeout
+const motionTimeoutUnit = 30 * time.Second
+
+// MotionSensorConfig is the configuration retrieved and set with the
+// motion sensor's extended get/set command
+type MotionSensorConfig struct {
+	// Timeout is how long after motion stops that the motion group is
+	// turned off.  The timeout is in 30 second increments
+	Timeout time.Duration
+
+	// LightSensitivity is the light level (0-255) at which dusk is reported
+	LightSensitivity int
+
+	Flags MotionSensorFlags
+}
+
+// UnmarshalBinary will parse the byte buffer into the receiver
+func (msc *MotionSensorConfig) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 14 {
+		return ErrBufferTooShort
+	}
+	msc.Timeout = time.Duration(int(buf[3])+1) * motionTimeoutUnit
+	msc.LightSensitivity = int(buf[4])
+	msc.Flags = MotionSensorFlags(buf[5])
+	return nil
+}
+
+// MotionSensorStatus is the light level and battery voltage reported
+// in the motion sensor's extended get/set response
+type MotionSensorStatus struct {
+	// LightLevel is the current light level (0-255)
+	LightLevel int
+
+	// BatteryVoltage is the battery voltage in volts
+	BatteryVoltage float64
+}
+
+// UnmarshalBinary will parse the byte buffer into the receiver
+func (mss *MotionSensorStatus) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 14 {
+		return ErrBufferTooShort
+	}
+	mss.LightLevel = int(buf[10])
+	mss.BatteryVoltage = float64(buf[11]) / 10
+	return nil
+}
+
+// MotionSensor is any device that satisfies the following interface.  Motion
+// sensors are battery powered and sleep most of the time, so they will usually
+// only respond to commands shortly after sending a message (or after the set
+// button is pressed)
+type MotionSensor interface {
+	Device
+
+	// Config queries the sensor and returns the configuration
+	Config() (MotionSensorConfig, error)
+
+	// SetConfig writes the timeout, light sensitivity and flags
+	// to the sensor
+	SetConfig(config MotionSensorConfig) error
+
+	// Status queries the sensor for the light level and battery voltage
+	Status() (MotionSensorStatus, error)
+
+	// DecodeEvent interprets an Event sent by the sensor.  The return
+	// value indicates whether the event was sent by the sensor to one
+	// of the motion sensor groups
+	DecodeEvent(event *Event) (*SensorEvent, bool)
+
+	// ConfigContext is Config that stops waiting for the device when
+	// the context is done
+	ConfigContext(ctx context.Context) (MotionSensorConfig, error)
+
+	// SetConfigContext is SetConfig that stops waiting for the device
+	// when the context is done
+	SetConfigContext(ctx context.Context, config MotionSensorConfig) error
+
+	// StatusContext is Status that stops waiting for the device when
+	// the context is done
+	StatusContext(ctx context.Context) (MotionSensorStatus, error)
+}
+
+// LinkableMotionSensor represents a MotionSensor that supports remote
+// linking (Insteon Engine version 2 or higher)
+type LinkableMotionSensor interface {
+	MotionSensor
+	Linkable
+}
+
+type motionSensor struct {
+	Device
+	timeout time.Duration
+	name    string
+}
+
+type linkableMotionSensor struct {
+	LinkableDevice
+	*motionSensor
+}
+
+// NewMotionSensor is a factory function that will return the correctly
+// configured motion sensor based on the underlying device
+func NewMotionSensor(device Device, timeout time.Duration) MotionSensor {
+	return newMotionSensor(device, timeout, "Motion Sensor")
+}
+
+func newMotionSensor(device Device, timeout time.Duration, name string) MotionSensor {
+	ms := &motionSensor{Device: device, timeout: timeout, name: name}
+	if linkable, ok := device.(LinkableDevice); ok {
+		return &linkableMotionSensor{LinkableDevice: linkable, motionSensor: ms}
+	}
+	return ms
+}
+
+// extendedGet requests the sensor's extended data and returns the payload
+// of the response
+func (ms *motionSensor) extendedGet(ctx context.Context) (payload []byte, err error) {
+	// D1 is the group (0x00) and D2 is 0x00 for requests
+	_, err = ms.SendCommandContext(ctx, CmdExtendedGetSet, []byte{0x00, 0x00})
+	if err == nil {
+		err = ReceiveContext(ctx, ms, ms.timeout, func(msg *Message) error {
+			// D2 is 0x01 for responses
+			if msg.Command == CmdExtendedGetSet && len(msg.Payload) > 1 && msg.Payload[1] == 0x01 {
+				payload = msg.Payload
+				return ErrReceiveComplete
+			}
+			return nil
+		})
+	}
+	return payload, err
+}
+
+func (ms *motionSensor) Config() (MotionSensorConfig, error) {
+	return ms.ConfigContext(context.Background())
+}
+
+func (ms *motionSensor) ConfigContext(ctx context.Context) (config MotionSensorConfig, err error) {
+	payload, err := ms.extendedGet(ctx)
+	if err == nil {
+		err = config.UnmarshalBinary(payload)
+	}
+	return config, err
+}
+
+func (ms *motionSensor) SetConfig(config MotionSensorConfig) error {
+	return ms.SetConfigContext(context.Background(), config)
+}
+
+func (ms *motionSensor) SetConfigContext(ctx context.Context, config MotionSensorConfig) error {
+	timeout := int(config.Timeout/motionTimeoutUnit) - 1
+	if timeout < 0 || timeout > 0xff || config.LightSensitivity < 0 || config.LightSensitivity > 0xff {
+		return ErrIllegalValue
+	}
+
+	// D2 selects the value that D3 is written to
+	var err error
+	for _, set := range [][]byte{
+		{0x00, 0x03, byte(timeout)},
+		{0x00, 0x04, byte(config.LightSensitivity)},
+		{0x00, 0x05, byte(config.Flags)},
+	} {
+		if err == nil {
+			_, err = ms.SendCommandContext(ctx, CmdExtendedGetSet, set)
+		}
+	}
+	return err
+}
+
+func (ms *motionSensor) Status() (MotionSensorStatus, error) {
+	return ms.StatusContext(context.Background())
+}
+
+func (ms *motionSensor) StatusContext(ctx context.Context) (status MotionSensorStatus, err error) {
+	payload, err := ms.extendedGet(ctx)
+	if err == nil {
+		err = status.UnmarshalBinary(payload)
+	}
+	return status, err
+}
+
+func (ms *motionSensor) DecodeEvent(event *Event) (*SensorEvent, bool) {
+	return motionSensorGroups.decode(ms.Address(), event)
+}
+
+func (ms *motionSensor) String() string {
+	return fmt.Sprintf("%s (%s)", ms.name, ms.Address())
+}
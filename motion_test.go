@@ -0,0 +1,198 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMotionSensorFactory(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input Device
+		want  reflect.Type
+	}{
+		{"Motion Sensor", &i1Device{}, reflect.TypeOf(&motionSensor{})},
+		{"Linkable Motion Sensor", &i2Device{}, reflect.TypeOf(&linkableMotionSensor{})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := reflect.TypeOf(NewMotionSensor(test.input, 0))
+			if test.want != got {
+				t.Errorf("want type %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestMotionSensorRegistry(t *testing.T) {
+	tests := []struct {
+		input DevCat
+		want  string
+	}{
+		{DevCat{0x10, 0x01}, "2842-222 Motion Sensor (915 MHz) (01.02.03)"},
+		{DevCat{0x10, 0x16}, "2844-222 Motion Sensor II (01.02.03)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			device, err := Devices.New(DeviceInfo{DevCat: test.input, EngineVersion: VerI2Cs}, &testConnection{addr: Address{1, 2, 3}}, 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if _, ok := device.(LinkableMotionSensor); !ok {
+				t.Errorf("want LinkableMotionSensor got %T", device)
+			}
+
+			if got := fmt.Sprintf("%v", device); test.want != got {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+
+	// other sensors in the category are not motion sensors
+	device, _ := Devices.New(DeviceInfo{DevCat: DevCat{0x10, 0x02}, EngineVersion: VerI2Cs}, &testConnection{}, 0)
+	if _, ok := device.(MotionSensor); ok {
+		t.Errorf("want device not to be a MotionSensor got %T", device)
+	}
+}
+
+func TestMotionSensorConfig(t *testing.T) {
+	tests := []struct {
+		desc       string
+		input      []byte
+		wantConfig MotionSensorConfig
+		wantStatus MotionSensorStatus
+		wantErr    error
+	}{
+		{"defaults", mkPayload(0x00, 0x01, 0x00, 0x00, 0x80, 0x0e, 0, 0, 0, 0, 0x42, 0x5a), MotionSensorConfig{Timeout: 30 * time.Second, LightSensitivity: 0x80, Flags: MotionNightMode | MotionLED | MotionOnOnly}, MotionSensorStatus{LightLevel: 0x42, BatteryVoltage: 9}, nil},
+		{"long timeout", mkPayload(0x00, 0x01, 0x00, 0x09, 0x10, 0x00, 0, 0, 0, 0, 0xff, 0x48), MotionSensorConfig{Timeout: 5 * time.Minute, LightSensitivity: 0x10}, MotionSensorStatus{LightLevel: 0xff, BatteryVoltage: 7.2}, nil},
+		{"short buffer", nil, MotionSensorConfig{}, MotionSensorStatus{}, ErrBufferTooShort},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := MotionSensorConfig{}
+			err := config.UnmarshalBinary(test.input)
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			} else if config != test.wantConfig {
+				t.Errorf("want config %+v got %+v", test.wantConfig, config)
+			}
+
+			status := MotionSensorStatus{}
+			err = status.UnmarshalBinary(test.input)
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			} else if status != test.wantStatus {
+				t.Errorf("want status %+v got %+v", test.wantStatus, status)
+			}
+		})
+	}
+}
+
+func TestMotionSensorGetConfig(t *testing.T) {
+	conn := &testConnection{recvCh: make(chan *Message, 2), sendCh: make(chan *Message, 1), ackCh: make(chan *Message, 1)}
+	ms := NewMotionSensor(conn, time.Millisecond)
+	// requests echoed by the device are ignored
+	conn.recvCh <- &Message{Command: CmdExtendedGetSet, Payload: mkPayload()}
+	conn.recvCh <- &Message{Command: CmdExtendedGetSet, Payload: mkPayload(0x00, 0x01, 0x00, 0x03, 0x20, 0x08, 0, 0, 0, 0, 0x10, 0x55)}
+	conn.ackCh <- TestAck
+
+	want := MotionSensorConfig{Timeout: 2 * time.Minute, LightSensitivity: 0x20, Flags: MotionLED}
+	got, err := ms.Config()
+	sent := <-conn.sendCh
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if got != want {
+		t.Errorf("want config %+v got %+v", want, got)
+	}
+
+	if sent.Command != CmdExtendedGetSet || !reflect.DeepEqual([]byte{0x00, 0x00}, sent.Payload) {
+		t.Errorf("want config request got %v % x", sent.Command, sent.Payload)
+	}
+}
+
+func TestMotionSensorSetConfig(t *testing.T) {
+	tests := []struct {
+		desc    string
+		input   MotionSensorConfig
+		want    [][]byte
+		wantErr error
+	}{
+		{"valid", MotionSensorConfig{Timeout: 90 * time.Second, LightSensitivity: 0x40, Flags: MotionNightMode}, [][]byte{{0x00, 0x03, 0x02}, {0x00, 0x04, 0x40}, {0x00, 0x05, 0x04}}, nil},
+		{"timeout too short", MotionSensorConfig{Timeout: time.Second}, nil, ErrIllegalValue},
+		{"timeout too long", MotionSensorConfig{Timeout: 257 * motionTimeoutUnit}, nil, ErrIllegalValue},
+		{"sensitivity out of range", MotionSensorConfig{Timeout: time.Minute, LightSensitivity: 256}, nil, ErrIllegalValue},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			conn := &testConnection{sendCh: make(chan *Message, 3), ackCh: make(chan *Message, 3)}
+			for range test.want {
+				conn.ackCh <- TestAck
+			}
+
+			err := NewMotionSensor(conn, time.Millisecond).SetConfig(test.input)
+			if err != test.wantErr {
+				t.Fatalf("want error %v got %v", test.wantErr, err)
+			}
+
+			for _, want := range test.want {
+				sent := <-conn.sendCh
+				if sent.Command != CmdExtendedGetSet || !reflect.DeepEqual(want, sent.Payload) {
+					t.Errorf("want %v % x got %v % x", CmdExtendedGetSet, want, sent.Command, sent.Payload)
+				}
+			}
+		})
+	}
+}
+
+func TestMotionSensorDecodeEvent(t *testing.T) {
+	src := Address{1, 2, 3}
+	tests := []struct {
+		desc      string
+		input     *Event
+		want      *SensorEvent
+		wantFound bool
+	}{
+		{"motion", &Event{Src: src, Group: MotionGroup, Action: ActionOn}, &SensorEvent{Src: src, Type: SensorMotion, Active: true}, true},
+		{"motion cleared", &Event{Src: src, Group: MotionGroup, Action: ActionOff}, &SensorEvent{Src: src, Type: SensorMotion}, true},
+		{"dusk", &Event{Src: src, Group: DuskDawnGroup, Action: ActionOn}, &SensorEvent{Src: src, Type: SensorDark, Active: true}, true},
+		{"dawn", &Event{Src: src, Group: DuskDawnGroup, Action: ActionOff}, &SensorEvent{Src: src, Type: SensorDark}, true},
+		{"low battery", &Event{Src: src, Group: LowBatteryGroup, Action: ActionOn}, &SensorEvent{Src: src, Type: SensorLowBattery, Active: true}, true},
+		{"heartbeat", &Event{Src: src, Group: 4, Action: ActionHeartbeat}, &SensorEvent{Src: src, Type: SensorHeartbeat, Active: true}, true},
+		{"unknown group", &Event{Src: src, Group: 5, Action: ActionOn}, nil, false},
+		{"unknown action", &Event{Src: src, Group: MotionGroup, Action: ActionSetButton}, nil, false},
+		{"other device", &Event{Src: Address{4, 5, 6}, Group: MotionGroup, Action: ActionOn}, nil, false},
+	}
+
+	ms := NewMotionSensor(&testConnection{addr: src}, 0)
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, found := ms.DecodeEvent(test.input)
+			if found != test.wantFound {
+				t.Errorf("want found %v got %v", test.wantFound, found)
+			} else if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
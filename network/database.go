@@ -20,17 +20,9 @@ import (
 	"github.com/abates/insteon"
 )
 
-// ProductDatabase is a registry of all the devices that have been
-// seend on the local Insteon network.  The database includes the
-// device category and firmware number. ProductDatabase implementations
-// must be thread safe as the methods can be called from multiple
-// go routines
-type ProductDatabase interface {
-	UpdateDevCat(address insteon.Address, devCat insteon.DevCat)
-	UpdateEngineVersion(address insteon.Address, engineVersion insteon.EngineVersion)
-	UpdateFirmwareVersion(address insteon.Address, firmwareVersion insteon.FirmwareVersion)
-	Find(address insteon.Address) (deviceInfo insteon.DeviceInfo, found bool)
-}
+// ProductDatabase is the insteon.ProductDatabase, kept here so existing
+// users of the network package continue to compile
+type ProductDatabase = insteon.ProductDatabase
 
 type productDatabase struct {
 	devices map[insteon.Address]*insteon.DeviceInfo
@@ -48,10 +40,10 @@ func NewProductDB() ProductDatabase {
 func (pdb *productDatabase) Find(address insteon.Address) (deviceInfo insteon.DeviceInfo, found bool) {
 	pdb.mutex.Lock()
 	di, found := pdb.devices[address]
-	pdb.mutex.Unlock()
 	if found {
 		deviceInfo = *di
 	}
+	pdb.mutex.Unlock()
 	return deviceInfo, found
 }
 
@@ -73,9 +65,17 @@ func (pdb *productDatabase) UpdateFirmwareVersion(address insteon.Address, firmw
 }
 
 func (pdb *productDatabase) UpdateEngineVersion(address insteon.Address, engineVersion insteon.EngineVersion) {
-	pdb.update(address, func(deviceInfo *insteon.DeviceInfo) { deviceInfo.EngineVersion = engineVersion })
+	pdb.update(address, func(deviceInfo *insteon.DeviceInfo) {
+		deviceInfo.EngineVersion = engineVersion
+		deviceInfo.EngineVersionKnown = true
+	})
 }
 
 func (pdb *productDatabase) UpdateDevCat(address insteon.Address, devCat insteon.DevCat) {
 	pdb.update(address, func(deviceInfo *insteon.DeviceInfo) { deviceInfo.DevCat = devCat })
 }
+
+func (pdb *productDatabase) Update(address insteon.Address, info insteon.DeviceInfo) {
+	info.Address = address
+	pdb.update(address, func(deviceInfo *insteon.DeviceInfo) { *deviceInfo = info })
+}
@@ -85,6 +85,12 @@ func TestProductDatabaseUpdateFind(t *testing.T) {
 		{"UpdateFirmwareVersion", func(pdb *productDatabase) { pdb.UpdateFirmwareVersion(address, insteon.FirmwareVersion(42)) }, func(di insteon.DeviceInfo) bool { return di.FirmwareVersion == insteon.FirmwareVersion(42) }},
 		{"UpdateEngineVersion", func(pdb *productDatabase) { pdb.UpdateEngineVersion(address, insteon.EngineVersion(42)) }, func(di insteon.DeviceInfo) bool { return di.EngineVersion == insteon.EngineVersion(42) }},
 		{"UpdateDevCat", func(pdb *productDatabase) { pdb.UpdateDevCat(address, insteon.DevCat{42, 42}) }, func(di insteon.DeviceInfo) bool { return di.DevCat == insteon.DevCat{42, 42} }},
+		{"UpdateEngineVersion I1", func(pdb *productDatabase) { pdb.UpdateEngineVersion(address, insteon.VerI1) }, func(di insteon.DeviceInfo) bool { return di.EngineVersionKnown }},
+		{"Update", func(pdb *productDatabase) {
+			pdb.Update(address, insteon.DeviceInfo{DevCat: insteon.DevCat{42, 42}, FirmwareVersion: 42, EngineVersionKnown: true})
+		}, func(di insteon.DeviceInfo) bool {
+			return di == insteon.DeviceInfo{Address: address, DevCat: insteon.DevCat{42, 42}, FirmwareVersion: 42, EngineVersionKnown: true}
+		}},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,293 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/abates/insteon"
+)
+
+// ErrLocked is returned when the lock file for a FileProductDB could not
+// be acquired before the lock timeout
+var ErrLocked = errors.New("product database is locked by another process")
+
+const (
+	// lockTimeout is how long to wait for another process to release
+	// the lock file
+	lockTimeout = 5 * time.Second
+
+	// lockRetryDelay is the delay between attempts to create the lock file
+	lockRetryDelay = 10 * time.Millisecond
+
+	// staleLockAge is the age at which a lock file is assumed to have been
+	// left behind by a process that exited without removing it
+	staleLockAge = 30 * time.Second
+)
+
+// FileProductDB is a ProductDatabase that persists the device information
+// to a JSON file so that it can be reused by subsequent processes.  The
+// file is replaced atomically (by writing a temporary file and renaming it)
+// so readers never see a partially written database.  Writers coordinate
+// with a lock file (the database path with a ".lock" suffix) and merge
+// their changes with whatever other processes have saved, so several
+// processes can safely share the same file
+type FileProductDB struct {
+	path    string
+	mutex   sync.Mutex
+	devices map[insteon.Address]insteon.DeviceInfo
+	modTime time.Time
+	size    int64
+}
+
+// NewFileProductDB returns a product database stored in the file at path.
+// The file is created when the first device is saved, so it need not exist
+func NewFileProductDB(path string) (*FileProductDB, error) {
+	pdb := &FileProductDB{
+		path:    path,
+		devices: make(map[insteon.Address]insteon.DeviceInfo),
+	}
+
+	pdb.mutex.Lock()
+	defer pdb.mutex.Unlock()
+	return pdb, pdb.load(true)
+}
+
+func decodeDevices(r io.Reader) (devices []insteon.DeviceInfo, err error) {
+	err = json.NewDecoder(r).Decode(&devices)
+	return devices, err
+}
+
+func encodeDevices(w io.Writer, devices map[insteon.Address]insteon.DeviceInfo) error {
+	list := make([]insteon.DeviceInfo, 0, len(devices))
+	for _, info := range devices {
+		list = append(list, info)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].Address, list[j].Address
+		return a[0] < b[0] || (a[0] == b[0] && (a[1] < b[1] || (a[1] == b[1] && a[2] < b[2])))
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(list)
+}
+
+// load re-reads the file if it has changed since it was last read (or
+// unconditionally when force is true).  The mutex must be held by the caller
+func (pdb *FileProductDB) load(force bool) error {
+	stat, err := os.Stat(pdb.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !force && stat.ModTime().Equal(pdb.modTime) && stat.Size() == pdb.size {
+		return nil
+	}
+
+	file, err := os.Open(pdb.path)
+	if err == nil {
+		var devices []insteon.DeviceInfo
+		devices, err = decodeDevices(file)
+		file.Close()
+		if err == nil {
+			pdb.devices = make(map[insteon.Address]insteon.DeviceInfo)
+			for _, info := range devices {
+				pdb.devices[info.Address] = info
+			}
+			pdb.modTime = stat.ModTime()
+			pdb.size = stat.Size()
+		}
+	}
+	return err
+}
+
+// save writes the devices to a temporary file in the same directory
+// as the database and then renames it over the database.  The mutex
+// and the lock file must be held by the caller
+func (pdb *FileProductDB) save() error {
+	file, err := ioutil.TempFile(filepath.Dir(pdb.path), filepath.Base(pdb.path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	err = encodeDevices(file, pdb.devices)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), pdb.path)
+	}
+
+	if err == nil {
+		var stat os.FileInfo
+		if stat, err = os.Stat(pdb.path); err == nil {
+			pdb.modTime = stat.ModTime()
+			pdb.size = stat.Size()
+		}
+	} else {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// lock creates the lock file, waiting for any other process holding
+// it to finish.  A lock file older than staleLockAge is removed
+func (pdb *FileProductDB) lock() (unlock func(), err error) {
+	lockfile := pdb.path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		var file *os.File
+		file, err = os.OpenFile(lockfile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockfile) }, nil
+		} else if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if stat, err := os.Stat(lockfile); err == nil && time.Since(stat.ModTime()) > staleLockAge {
+			insteon.Log.Infof("Removing stale lock file %s", lockfile)
+			os.Remove(lockfile)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(lockRetryDelay)
+	}
+}
+
+// transaction locks the database, merges any changes saved by other
+// processes, calls update and then saves the database if update
+// reports that anything changed
+func (pdb *FileProductDB) transaction(update func() bool) error {
+	pdb.mutex.Lock()
+	defer pdb.mutex.Unlock()
+
+	unlock, err := pdb.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// the modification time may not change if another process saved
+	// within the file system's timestamp resolution
+	err = pdb.load(true)
+	if err == nil && update() {
+		err = pdb.save()
+	}
+	return err
+}
+
+func (pdb *FileProductDB) update(address insteon.Address, callback func(*insteon.DeviceInfo)) {
+	err := pdb.transaction(func() bool {
+		deviceInfo, found := pdb.devices[address]
+		if !found {
+			deviceInfo.Address = address
+		}
+		previous := deviceInfo
+		callback(&deviceInfo)
+		pdb.devices[address] = deviceInfo
+		return !found || previous != deviceInfo
+	})
+
+	if err != nil {
+		insteon.Log.Infof("Failed to save product database %s: %v", pdb.path, err)
+	}
+}
+
+// Find returns the device information for the address.  The file is
+// re-read if another process has saved it
+func (pdb *FileProductDB) Find(address insteon.Address) (deviceInfo insteon.DeviceInfo, found bool) {
+	pdb.mutex.Lock()
+	defer pdb.mutex.Unlock()
+	if err := pdb.load(false); err != nil {
+		insteon.Log.Infof("Failed to read product database %s: %v", pdb.path, err)
+	}
+	deviceInfo, found = pdb.devices[address]
+	return deviceInfo, found
+}
+
+// UpdateFirmwareVersion saves the firmware version of the device
+func (pdb *FileProductDB) UpdateFirmwareVersion(address insteon.Address, firmwareVersion insteon.FirmwareVersion) {
+	pdb.update(address, func(deviceInfo *insteon.DeviceInfo) { deviceInfo.FirmwareVersion = firmwareVersion })
+}
+
+// UpdateEngineVersion saves the engine version of the device and marks
+// it as known
+func (pdb *FileProductDB) UpdateEngineVersion(address insteon.Address, engineVersion insteon.EngineVersion) {
+	pdb.update(address, func(deviceInfo *insteon.DeviceInfo) {
+		deviceInfo.EngineVersion = engineVersion
+		deviceInfo.EngineVersionKnown = true
+	})
+}
+
+// UpdateDevCat saves the device category of the device
+func (pdb *FileProductDB) UpdateDevCat(address insteon.Address, devCat insteon.DevCat) {
+	pdb.update(address, func(deviceInfo *insteon.DeviceInfo) { deviceInfo.DevCat = devCat })
+}
+
+// Update replaces the information saved for the device.  The file is
+// written once, no matter how many of the fields changed
+func (pdb *FileProductDB) Update(address insteon.Address, info insteon.DeviceInfo) {
+	info.Address = address
+	pdb.update(address, func(deviceInfo *insteon.DeviceInfo) { *deviceInfo = info })
+}
+
+// Export writes every device in the database to w as a JSON list
+func (pdb *FileProductDB) Export(w io.Writer) error {
+	pdb.mutex.Lock()
+	defer pdb.mutex.Unlock()
+
+	err := pdb.load(false)
+	if err == nil {
+		err = encodeDevices(w, pdb.devices)
+	}
+	return err
+}
+
+// Import reads a JSON list of devices (such as one written by Export)
+// from r and saves them to the database.  Imported devices replace
+// any existing information for the same address
+func (pdb *FileProductDB) Import(r io.Reader) error {
+	devices, err := decodeDevices(r)
+	if err == nil {
+		err = pdb.transaction(func() bool {
+			for _, info := range devices {
+				pdb.devices[info.Address] = info
+			}
+			return len(devices) > 0
+		})
+	}
+	return err
+}
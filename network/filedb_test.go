@@ -0,0 +1,180 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abates/insteon"
+)
+
+func newTestFileDB(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "insteon")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return filepath.Join(dir, "devices.json"), func() { os.RemoveAll(dir) }
+}
+
+func TestFileProductDB(t *testing.T) {
+	path, cleanup := newTestFileDB(t)
+	defer cleanup()
+
+	db, err := NewFileProductDB(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	addr := insteon.Address{1, 2, 3}
+	if _, found := db.Find(addr); found {
+		t.Errorf("expected %v to not be found", addr)
+	}
+
+	want := insteon.DeviceInfo{Address: addr, DevCat: insteon.DevCat{1, 32}, FirmwareVersion: 65, EngineVersion: insteon.VerI2Cs, EngineVersionKnown: true}
+	db.UpdateDevCat(addr, want.DevCat)
+	db.UpdateFirmwareVersion(addr, want.FirmwareVersion)
+	db.UpdateEngineVersion(addr, want.EngineVersion)
+
+	// a second database (such as one opened by another process) sees the
+	// saved information
+	other, err := NewFileProductDB(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, found := other.Find(addr); !found {
+		t.Errorf("expected %v to be found", addr)
+	} else if got != want {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+
+	if matches, _ := filepath.Glob(path + "*"); len(matches) != 1 {
+		t.Errorf("want only the database file to remain got %v", matches)
+	}
+}
+
+func TestFileProductDBMerge(t *testing.T) {
+	path, cleanup := newTestFileDB(t)
+	defer cleanup()
+
+	db1, _ := NewFileProductDB(path)
+	db2, _ := NewFileProductDB(path)
+
+	addr1 := insteon.Address{1, 2, 3}
+	addr2 := insteon.Address{4, 5, 6}
+	db1.UpdateDevCat(addr1, insteon.DevCat{1, 32})
+	db2.UpdateDevCat(addr2, insteon.DevCat{2, 42})
+	db1.UpdateEngineVersion(addr2, insteon.VerI2)
+
+	want := insteon.DeviceInfo{Address: addr2, DevCat: insteon.DevCat{2, 42}, EngineVersion: insteon.VerI2, EngineVersionKnown: true}
+	for i, db := range []*FileProductDB{db1, db2} {
+		if _, found := db.Find(addr1); !found {
+			t.Errorf("db%d: expected %v to be found", i+1, addr1)
+		}
+
+		if got, _ := db.Find(addr2); got != want {
+			t.Errorf("db%d: want %+v got %+v", i+1, want, got)
+		}
+	}
+}
+
+func TestFileProductDBUpdate(t *testing.T) {
+	path, cleanup := newTestFileDB(t)
+	defer cleanup()
+
+	db, _ := NewFileProductDB(path)
+	addr := insteon.Address{1, 2, 3}
+	db.UpdateFirmwareVersion(addr, 12)
+
+	want := insteon.DeviceInfo{Address: addr, DevCat: insteon.DevCat{1, 32}, FirmwareVersion: 65, EngineVersion: insteon.VerI1, EngineVersionKnown: true}
+	db.Update(addr, insteon.DeviceInfo{DevCat: want.DevCat, FirmwareVersion: want.FirmwareVersion, EngineVersionKnown: true})
+
+	other, _ := NewFileProductDB(path)
+	if got, _ := other.Find(addr); got != want {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+}
+
+func TestFileProductDBStaleLock(t *testing.T) {
+	path, cleanup := newTestFileDB(t)
+	defer cleanup()
+
+	lockfile := path + ".lock"
+	ioutil.WriteFile(lockfile, nil, 0644)
+	old := time.Now().Add(-2 * staleLockAge)
+	os.Chtimes(lockfile, old, old)
+
+	db, _ := NewFileProductDB(path)
+	addr := insteon.Address{1, 2, 3}
+	db.UpdateDevCat(addr, insteon.DevCat{1, 32})
+
+	if _, err := os.Stat(lockfile); !os.IsNotExist(err) {
+		t.Errorf("expected the lock file to be removed")
+	}
+
+	other, _ := NewFileProductDB(path)
+	if _, found := other.Find(addr); !found {
+		t.Errorf("expected %v to be saved", addr)
+	}
+}
+
+func TestFileProductDBImportExport(t *testing.T) {
+	path, cleanup := newTestFileDB(t)
+	defer cleanup()
+
+	input := `[
+  {"Address": "04.05.06", "DevCat": "02.2a", "FirmwareVersion": 65, "EngineVersion": 2, "EngineVersionKnown": true},
+  {"Address": "01.02.03", "DevCat": "01.20", "FirmwareVersion": 69, "EngineVersion": 1, "EngineVersionKnown": true},
+  {"Address": "07.08.09", "DevCat": "01.20", "FirmwareVersion": 69, "EngineVersion": 0},
+  {"Address": "0a.0b.0c", "DevCat": "01.20", "FirmwareVersion": 69, "EngineVersion": 0, "EngineVersionKnown": true}
+]`
+	db, _ := NewFileProductDB(path)
+	db.UpdateFirmwareVersion(insteon.Address{1, 2, 3}, 12)
+	if err := db.Import(bytes.NewBufferString(input)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := insteon.DeviceInfo{Address: insteon.Address{1, 2, 3}, DevCat: insteon.DevCat{1, 32}, FirmwareVersion: 69, EngineVersion: insteon.VerI2, EngineVersionKnown: true}
+	if got, _ := db.Find(want.Address); got != want {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := db.Export(buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// exported devices are sorted by address
+	got, _ := decodeDevices(buf)
+	wantDevices := []insteon.DeviceInfo{
+		want,
+		{Address: insteon.Address{4, 5, 6}, DevCat: insteon.DevCat{2, 42}, FirmwareVersion: 65, EngineVersion: insteon.VerI2Cs, EngineVersionKnown: true},
+		{Address: insteon.Address{7, 8, 9}, DevCat: insteon.DevCat{1, 32}, FirmwareVersion: 69, EngineVersion: insteon.VerI1},
+		{Address: insteon.Address{10, 11, 12}, DevCat: insteon.DevCat{1, 32}, FirmwareVersion: 69, EngineVersion: insteon.VerI1, EngineVersionKnown: true},
+	}
+	if !reflect.DeepEqual(wantDevices, got) {
+		t.Errorf("want %+v got %+v", wantDevices, got)
+	}
+
+	if err := db.Import(bytes.NewBufferString("not json")); err == nil {
+		t.Errorf("expected an error importing invalid json")
+	}
+}
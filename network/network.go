@@ -21,226 +21,168 @@ import (
 	"github.com/abates/insteon"
 )
 
-// PacketRequest is used to request that a packetized (marshaled) insteon
-// message be sent to the network. Once the upstream device (PLM usually)
-// has attempted to send the packet, the Err field will be assigned and
-// DoneCh will be written to and closed
-type PacketRequest struct {
-	Payload []byte
-	Err     error
-	DoneCh  chan<- *PacketRequest
-}
-
-// MessageRequest is used to request a message be sent to a specific device.
-// Once the connection has sent the message and either received an ack or
-// encountered an error, the Ack and Err fields will be filled and DoneCh
-// will be written to and closed
-type MessageRequest struct {
-	Message *insteon.Message
-	timeout time.Time
-	Ack     *insteon.Message
-	Err     error
-	DoneCh  chan<- *MessageRequest
+// Bridge is the upstream device (usually a PLM) that connects the
+// network to the Insteon devices.  *plm.PLM satisfies this interface
+// as well as io.Closer
+type Bridge interface {
+	// Connect returns a connection for communicating with the
+	// device at the given address
+	Connect(addr insteon.Address, options ...insteon.ConnectionOption) (insteon.Connection, error)
+
+	// Monitor returns a channel that receives every message
+	// received from the Insteon network
+	Monitor() <-chan *insteon.Message
+
+	// StopMonitor closes a channel returned by Monitor
+	StopMonitor(<-chan *insteon.Message)
 }
 
 // Network is the main means to communicate with
 // devices on the Insteon network
 type Network struct {
-	timeout     time.Duration
-	DB          ProductDatabase
-	connections []insteon.Connection
-
-	connection   insteon.Connection
-	connectCh    chan insteon.Connection
-	disconnectCh chan insteon.Connection
-	closeCh      chan chan error
+	timeout time.Duration
+	DB      ProductDatabase
+
+	bridge  Bridge
+	monitor <-chan *insteon.Message
+	done    chan struct{}
 }
 
-// New creates a new Insteon network instance for the send and receive channels.  The timeout
+// Option is a functional option passed to New
+type Option func(*Network)
+
+// ProductDB sets the product database used by the network.  By default
+// an in-memory database is used
+func ProductDB(db ProductDatabase) Option {
+	return func(network *Network) {
+		network.DB = db
+	}
+}
+
+// New creates a new Insteon network on top of the given bridge.  The timeout
 // indicates how long the network (and subsuquent devices) should wait when expecting incoming
-// messages/responses
-func New(connection insteon.Connection, timeout time.Duration) *Network {
+// messages/responses.  Messages received by the bridge are watched in order to keep
+// the product database up to date
+func New(bridge Bridge, timeout time.Duration, options ...Option) *Network {
 	network := &Network{
-		timeout:    timeout,
-		DB:         NewProductDB(),
-		connection: connection,
+		timeout: timeout,
+		DB:      NewProductDB(),
+		bridge:  bridge,
+		done:    make(chan struct{}),
+	}
 
-		connectCh:    make(chan insteon.Connection),
-		disconnectCh: make(chan insteon.Connection),
-		closeCh:      make(chan chan error),
+	for _, option := range options {
+		option(network)
 	}
 
+	network.monitor = bridge.Monitor()
+
 	go network.process()
 	return network
 }
 
 func (network *Network) process() {
-	/*defer network.close()
-	for {
-		select {
-		case buf := <-network.connection.Receive():
-			network.receive(buf)
-		case connection := <-network.connectCh:
-			network.connections = append(network.connections, connection)
-		case connection := <-network.disconnectCh:
-			network.disconnect(connection)
-		case ch := <-network.closeCh:
-			ch <- network.close()
-			return
-		}
-	}*/
+	for msg := range network.monitor {
+		network.receive(msg)
+	}
+	close(network.done)
 }
 
-func (network *Network) receive(buf []byte) {
-	msg := &insteon.Message{}
-	err := msg.UnmarshalBinary(buf)
-	if err == nil {
-		insteon.Log.Tracef("Received Insteon Message %v", msg)
-		if msg.Broadcast() {
-			// Set Button Pressed Controller/Responder
-			if msg.Command[1] == 0x01 || msg.Command[1] == 0x02 {
-				network.DB.UpdateFirmwareVersion(msg.Src, insteon.FirmwareVersion(msg.Dst[2]))
-				network.DB.UpdateDevCat(msg.Src, insteon.DevCat{msg.Dst[0], msg.Dst[1]})
-			}
-		} else if msg.Ack() && msg.Command[1] == 0x0d {
+func (network *Network) receive(msg *insteon.Message) {
+	insteon.Log.Tracef("Received Insteon Message %v", msg)
+	if msg.Flags.Type() == insteon.MsgTypeBroadcast {
+		// Set Button Pressed Controller/Responder
+		if msg.Command[1] == 0x01 || msg.Command[1] == 0x02 {
+			network.DB.UpdateFirmwareVersion(msg.Src, insteon.FirmwareVersion(msg.Dst[2]))
+			network.DB.UpdateDevCat(msg.Src, insteon.DevCat{msg.Dst[0], msg.Dst[1]})
+		}
+	} else if msg.Command[1] == insteon.CmdGetEngineVersion[1] {
+		if msg.Ack() {
 			// Engine Version Request ACK
 			network.DB.UpdateEngineVersion(msg.Src, insteon.EngineVersion(msg.Command[2]))
-		}
-
-		for range network.connections {
-			// TODO: FIX THIS
-			//connection.Push(msg)
-		}
-	} else {
-		insteon.Log.Infof("Failed to unmarshal buffer: %v", err)
-	}
-}
-
-func (network *Network) disconnect(connection insteon.Connection) {
-	for i, conn := range network.connections {
-		if conn == connection {
-			if closer, ok := conn.(io.Closer); ok {
-				closer.Close()
-			}
-			network.connections = append(network.connections[0:i], network.connections[i+1:]...)
-			break
+		} else if msg.Nak() && msg.Command[2] == 0xff {
+			// only I2Cs devices NAK the request when not linked
+			network.DB.UpdateEngineVersion(msg.Src, insteon.VerI2Cs)
 		}
 	}
 }
 
-/*func (network *Network) sendMessage(msg *insteon.Message) error {
-	buf, err := msg.MarshalBinary()
-
+// EngineVersion will query the dst device to determine its Insteon engine
+// version.  If the device is an I2Cs device that is not linked to the
+// bridge then VerI2Cs is returned along with ErrNotLinked
+func (network *Network) EngineVersion(dst insteon.Address) (engineVersion insteon.EngineVersion, err error) {
+	conn, err := network.bridge.Connect(dst)
 	if err == nil {
-		insteon.Log.Tracef("Sending %v to network", msg)
-		if info, found := network.DB.Find(msg.Dst); found {
-			if msg.Flags.Extended() && info.EngineVersion == insteon.VerI2Cs {
-				buf[len(buf)-1] = checksum(buf[7:22])
-			}
+		engineVersion, err = conn.EngineVersion()
+		if err == nil || err == insteon.ErrNotLinked {
+			network.DB.UpdateEngineVersion(dst, engineVersion)
 		}
-		insteon.Log.Tracef("Sending %v to network", msg)
-		err = network.bridge.Send(buf)
 	}
-	return err
-}*/
-
-// EngineVersion will query the dst device to determine its Insteon engine
-// version
-func (network *Network) EngineVersion(dst insteon.Address) (engineVersion insteon.EngineVersion, err error) {
-	/*conn := network.connect(dst, 1, CmdGetEngineVersion)
-	defer func() { close(conn.sendCh) }()
-
-	doneCh := make(chan *MessageRequest, 1)
-	request := &MessageRequest{Message: &Message{Command: CmdGetEngineVersion, Flags: StandardDirectMessage}, DoneCh: doneCh}
-	conn.sendCh <- request
-	<-doneCh
-
-	if request.Err == nil {
-		engineVersion = EngineVersion(request.Ack.Command[2])
-	}*/
-	return engineVersion, nil
+	return engineVersion, err
 }
 
 // IDRequest will send an ID Request message to the destination device and wait for
 // either a "Set-button Pressed Controller" or "Set-button Pressed Responder" broadcast
 // message. This message includes the device category and firmaware information which
-// is then returned in the DeviceInfo object.  It should be noted that the returned
-// DeviceInfo object will not have the engine version field populated as this information
-// is not included in the broadcast response.
+// is then returned in the DeviceInfo object.  The engine version in the returned
+// DeviceInfo is whatever is currently known in the product database, since this
+// information is not included in the broadcast response.
 func (network *Network) IDRequest(dst insteon.Address) (info insteon.DeviceInfo, err error) {
-	info = insteon.DeviceInfo{
-		Address: dst,
-	}
-	conn := network.connect(dst, 1, insteon.CmdSetButtonPressedResponder, insteon.CmdSetButtonPressedController)
-
-	_, err = conn.Send(&insteon.Message{Command: insteon.CmdIDRequest, Flags: insteon.StandardDirectMessage})
-	timeout := time.Now().Add(network.timeout)
-	for err == nil {
-		var msg *insteon.Message
-		msg, err = conn.Receive()
+	conn, err := network.bridge.Connect(dst)
+	if err == nil {
+		var firmware insteon.FirmwareVersion
+		var devCat insteon.DevCat
+		firmware, devCat, err = conn.IDRequest()
 		if err == nil {
-			if msg.Broadcast() {
-				info, _ = network.DB.Find(dst)
-				return
-			} else if timeout.Before(time.Now()) {
-				err = insteon.ErrReadTimeout
-			}
+			network.DB.UpdateFirmwareVersion(dst, firmware)
+			network.DB.UpdateDevCat(dst, devCat)
+			info, _ = network.DB.Find(dst)
 		}
 	}
-
-	return
-}
-
-func (network *Network) connect(dst insteon.Address, version insteon.EngineVersion, match ...insteon.Command) insteon.Connection {
-	/*connection := insteon.NewConnection(network.bridge, dst, version, network.timeout, match...)
-	network.connectCh <- connection
-	return connection
-	*/
-	return nil
+	return info, err
 }
 
-// Connect will Dial the destination device and then determine the device category
-// in order to return a category specific device (dimmer, switch, etc). If, for
-// some reason, the devcat cannot be determined, then the device returned
-// by Dial is returned
+// Connect will return a category specific device (dimmer, switch, etc) for the
+// destination address.  The device info cached in the product database is used
+// when possible.  The device is only queried for the information that is missing:
+// an ID Request is sent if the device category is unknown and the engine version
+// is requested if it is unknown.  If the device is an unlinked I2Cs device then an
+// I2CsDevice is returned along with ErrNotLinked
 func (network *Network) Connect(dst insteon.Address) (device insteon.Device, err error) {
-	/*
-		var info insteon.DeviceInfo
-		var found bool
-		if info, found = network.DB.Find(dst); !found {
-			info.EngineVersion, err = network.EngineVersion(dst)
-			if err == nil {
-				info, err = network.IDRequest(dst)
-			}
+	conn, err := network.bridge.Connect(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	info, _ := network.DB.Find(dst)
+	info.Address = dst
+	if !info.EngineVersionKnown {
+		info.EngineVersion, err = network.EngineVersion(dst)
+		if err == insteon.ErrNotLinked {
+			device, _ = insteon.New(insteon.VerI2Cs, conn, network.timeout)
+			return device, err
 		}
+	}
 
-		if err == nil {
-			if constructor, found := insteon.Devices.Find(info.DevCat.Category()); found {
-				bridge := network.connect(dst, info.EngineVersion)
-				device, err = constructor(info, dst, bridge, network.timeout)
-			} else {
-				device, err = network.Dial(dst)
-			}
-		}*/
-	return
-}
+	if err == nil && info.DevCat == (insteon.DevCat{}) {
+		var idInfo insteon.DeviceInfo
+		idInfo, err = network.IDRequest(dst)
+		info.DevCat, info.FirmwareVersion = idInfo.DevCat, idInfo.FirmwareVersion
+	}
 
-func (network *Network) close() error {
-	network.connections = nil
-	return nil
+	if err == nil {
+		device, err = insteon.Devices.New(info, conn, network.timeout)
+	}
+	return device, err
 }
 
-// Close will cleanup/close open connections and disconnect gracefully
-func (network *Network) Close() error {
-	ch := make(chan error)
-	network.closeCh <- ch
-	close(network.closeCh)
-	err := <-ch
-	if closer, ok := network.connection.(io.Closer); ok {
-		err1 := closer.Close()
-		if err == nil {
-			err = err1
-		}
+// Close stops watching the network for messages.  If the bridge implements
+// io.Closer then it is closed as well
+func (network *Network) Close() (err error) {
+	network.bridge.StopMonitor(network.monitor)
+	<-network.done
+	if closer, ok := network.bridge.(io.Closer); ok {
+		err = closer.Close()
 	}
 	return err
 }
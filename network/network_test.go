@@ -14,336 +14,192 @@
 
 package network
 
-/*
 import (
-	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/abates/insteon"
+	"github.com/abates/insteon/plm"
+	"github.com/abates/insteon/plm/plmtest"
 )
 
 var (
-	testSrcAddr = insteon.Address{1, 2, 3}
-	testDstAddr = insteon.Address{3, 4, 5}
-
-	TestMessageSetButtonPressedController = &insteon.Message{testDstAddr, testSrcAddr, insteon.StandardBroadcast, insteon.Command{0x00, 0x02, 0xff}, nil}
-	TestMessageEngineVersionAck           = &insteon.Message{testDstAddr, testSrcAddr, insteon.StandardDirectAck, insteon.Command{0x00, 0x0d, 0x01}, nil}
-
-	TestProductDataResponse = &insteon.Message{testDstAddr, testSrcAddr, insteon.ExtendedDirectMessage, insteon.CmdProductDataResp, []byte{0, 1, 2, 3, 4, 5, 0xff, 0xff, 0, 0, 0, 0, 0, 0}}
+	testModemAddr = insteon.Address{1, 2, 3}
+	testDstAddr   = insteon.Address{3, 4, 5}
 )
 
-type testBridge struct {
-	rx      chan []byte
-	send    chan []byte
-	sendErr error
-}
-
-func (tb *testBridge) Send(buf []byte) error {
-	tb.send <- buf
-	return tb.sendErr
-}
-
-func (tb *testBridge) Receive() <-chan []byte {
-	return tb.rx
+// testDevice answers the engine version and ID requests the
+// modem sends to it
+type testDevice struct {
+	sync.Mutex
+	emulator      *plmtest.PLM
+	engineVersion insteon.EngineVersion
+	devCat        insteon.DevCat
+	firmware      insteon.FirmwareVersion
+	unlinked      bool
+	requests      map[byte]int
 }
 
-func newTestNetwork() (*Network, *testBridge) {
-	bridge := &testBridge{
-		rx:   make(chan []byte),
-		send: make(chan []byte, 1),
-	}
-	return New(bridge, time.Millisecond), bridge
-}
-
-/*func TestNetworkProcess(t *testing.T) {
-	connection := make(chan *Message, 1)
-	network, _, recvCh := newTestNetwork(0)
-	network.connectCh <- connection
-
-	buf, _ := TestMessagePingAck.MarshalBinary()
-	recvCh <- buf
-	select {
-	case <-connection:
-	case <-time.After(time.Millisecond):
-		t.Error("Expected connection to receive a message")
+func (td *testDevice) onSend(msg *insteon.Message) {
+	if msg.Dst != testDstAddr {
+		return
 	}
 
-	network.disconnectCh <- connection
-	network.Close()
-
-	if len(network.connections) != 0 {
-		t.Errorf("Expected connnection queue to be empty, got %d", len(network.connections))
+	td.Lock()
+	td.requests[msg.Command[1]]++
+	td.Unlock()
+
+	ack := &insteon.Message{Src: testDstAddr, Dst: testModemAddr, Flags: insteon.StandardDirectAck, Command: msg.Command}
+	switch msg.Command[1] {
+	case insteon.CmdGetEngineVersion[1]:
+		ack.Command[2] = byte(td.engineVersion)
+		if td.unlinked {
+			ack.Flags = insteon.StandardDirectNak
+			ack.Command[2] = 0xff
+		}
+		td.emulator.Receive(ack)
+	case insteon.CmdIDRequest[1]:
+		td.emulator.Receive(ack)
+		td.emulator.Receive(&insteon.Message{Src: testDstAddr, Dst: insteon.Address{td.devCat[0], td.devCat[1], byte(td.firmware)}, Flags: insteon.StandardBroadcast, Command: insteon.CmdSetButtonPressedResponder})
 	}
 }
 
-type testConnection struct {
-	rxCh chan *insteon.Message
+func (td *testDevice) count(cmd insteon.Command) int {
+	td.Lock()
+	defer td.Unlock()
+	return td.requests[cmd[1]]
 }
 
-func (tc *testConnection) Send(msg *insteon.Message) (*insteon.Message, error) { return nil, nil }
-func (tc *testConnection) Push(msg *insteon.Message)                           { tc.rxCh <- msg }
-func (tc *testConnection) Receive() (*insteon.Message, error) {
-	return <-tc.rxCh, nil
+func newTestNetwork(t *testing.T) (*Network, *plm.PLM, *testDevice) {
+	emulator := plmtest.New(testModemAddr, insteon.DevCat{3, 21}, 42)
+	device := &testDevice{emulator: emulator, engineVersion: insteon.VerI2, devCat: insteon.DevCat{0x01, 0x20}, firmware: 0x45, requests: make(map[byte]int)}
+	emulator.OnSend = device.onSend
+
+	modem, err := plm.New(plm.NewPort(emulator, time.Millisecond), 100*time.Millisecond, plm.WriteDelay(time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error from plm.New(): %v", err)
+	}
+	return New(modem, 100*time.Millisecond), modem, device
 }
 
 func TestNetworkReceive(t *testing.T) {
-
 	tests := []struct {
-		desc            string
-		input           *insteon.Message
-		expectedUpdates []string
+		desc  string
+		input *insteon.Message
+		want  insteon.DeviceInfo
 	}{
-		{"SetButtonPressed", TestMessageSetButtonPressedController, []string{"FirmwareVersion", "DevCat"}},
-		{"EngineVersion", TestMessageEngineVersionAck, []string{"EngineVersion"}},
+		{"set button", &insteon.Message{Src: testDstAddr, Dst: insteon.Address{1, 32, 65}, Flags: insteon.StandardBroadcast, Command: insteon.CmdSetButtonPressedController}, insteon.DeviceInfo{Address: testDstAddr, DevCat: insteon.DevCat{1, 32}, FirmwareVersion: 65}},
+		{"engine version ack", &insteon.Message{Src: testDstAddr, Dst: testModemAddr, Flags: insteon.StandardDirectAck, Command: insteon.Command{0x00, 0x0d, 0x01}}, insteon.DeviceInfo{Address: testDstAddr, EngineVersion: insteon.VerI2, EngineVersionKnown: true}},
+		{"engine version nak", &insteon.Message{Src: testDstAddr, Dst: testModemAddr, Flags: insteon.StandardDirectNak, Command: insteon.Command{0x00, 0x0d, 0xff}}, insteon.DeviceInfo{Address: testDstAddr, EngineVersion: insteon.VerI2Cs, EngineVersionKnown: true}},
 	}
 
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
-			testDb := newTestProductDB()
-			conn := &testConnection{
-				rxCh: make(chan *insteon.Message, 1),
-			}
-
-			bridge := &testBridge{rx: make(chan []byte)}
-
-			network := &Network{
-				bridge:      bridge,
-				DB:          testDb,
-				connections: []insteon.Connection{conn},
-				closeCh:     make(chan chan error, 1),
-			}
-
-			go network.process()
-			buf, _ := test.input.MarshalBinary()
-			bridge.rx <- buf
-			closeCh := make(chan error)
-			network.closeCh <- closeCh
-			<-closeCh
-
-			for _, update := range test.expectedUpdates {
-				if !testDb.WasUpdated(update) {
-					t.Errorf("expected %v to be updated in the database", update)
-				}
-			}
+			emulator := plmtest.New(testModemAddr, insteon.DevCat{3, 21}, 42)
+			modem, _ := plm.New(plm.NewPort(emulator, time.Millisecond), 100*time.Millisecond)
+			defer modem.Close()
+			network := New(modem, time.Millisecond)
+
+			emulator.Receive(test.input)
+			// give the message time to reach the monitor, closing the
+			// network then waits for it to be processed
+			time.Sleep(10 * time.Millisecond)
+			network.Close()
 
-			if len(conn.rxCh) != 1 {
-				t.Error("expected connection to have received the message")
+			if got, found := network.DB.Find(testDstAddr); !found {
+				t.Errorf("expected %v to be found", testDstAddr)
+			} else if got != test.want {
+				t.Errorf("want %+v got %+v", test.want, got)
 			}
 		})
 	}
 }
 
-func TestNetworkEngineVersion(t *testing.T) {
+func TestNetworkConnect(t *testing.T) {
 	tests := []struct {
-		desc            string
-		returnedAck     *insteon.Message
-		returnedErr     error
-		expectedVersion insteon.EngineVersion
+		desc        string
+		cached      *insteon.DeviceInfo
+		unlinked    bool
+		wantErr     error
+		wantDimmer  bool
+		wantEngine  int
+		wantID      int
+		wantVersion insteon.EngineVersion
 	}{
-		{"v1", TestMessageEngineVersionAck, nil, 1},
-		{"v2", TestMessageEngineVersionAck, nil, 2},
-		{"v3", TestMessageEngineVersionAck, nil, 3},
-		{"err", TestMessageEngineVersionAck, insteon.ErrReadTimeout, 0},
+		{"unknown device", nil, false, nil, true, 1, 1, insteon.VerI2},
+		{"cached device", &insteon.DeviceInfo{DevCat: insteon.DevCat{0x01, 0x20}, FirmwareVersion: 0x45, EngineVersion: insteon.VerI2Cs, EngineVersionKnown: true}, false, nil, true, 0, 0, insteon.VerI2Cs},
+		{"cached I1 device", &insteon.DeviceInfo{DevCat: insteon.DevCat{0x01, 0x20}, FirmwareVersion: 0x45, EngineVersion: insteon.VerI1, EngineVersionKnown: true}, false, nil, true, 0, 0, insteon.VerI1},
+		{"cached devcat", &insteon.DeviceInfo{DevCat: insteon.DevCat{0x01, 0x20}, FirmwareVersion: 0x45}, false, nil, true, 1, 0, insteon.VerI2},
+		{"unlinked", nil, true, insteon.ErrNotLinked, false, 1, 0, insteon.VerI2Cs},
 	}
 
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
-			network, bridge := newTestNetwork()
-
-			go func() {
-				if test.returnedErr == nil {
-					<-bridge.send
-					ack := *test.returnedAck
-					ack.Src = testDstAddr
-					ack.Command = insteon.Command{0x00, ack.Command[1], byte(test.expectedVersion)}
-					buf, _ := ack.MarshalBinary()
-					bridge.rx <- buf
+			network, modem, device := newTestNetwork(t)
+			defer modem.Close()
+			defer network.Close()
+			device.unlinked = test.unlinked
+			if test.cached != nil {
+				network.DB.UpdateDevCat(testDstAddr, test.cached.DevCat)
+				network.DB.UpdateFirmwareVersion(testDstAddr, test.cached.FirmwareVersion)
+				if test.cached.EngineVersionKnown {
+					network.DB.UpdateEngineVersion(testDstAddr, test.cached.EngineVersion)
 				}
-			}()
-
-			version, err := network.EngineVersion(testDstAddr)
-			if err != test.returnedErr {
-				t.Errorf("got error %v, want %v", err, test.returnedErr)
 			}
 
-			if version != test.expectedVersion {
-				t.Errorf("got version %v, want %v", version, test.expectedVersion)
+			got, err := network.Connect(testDstAddr)
+			if err != test.wantErr {
+				t.Fatalf("want error %v got %v", test.wantErr, err)
 			}
-			network.Close()
-		})
-	}
-}
-
-func TestNetworkIDRequest(t *testing.T) {
-	tests := []struct {
-		desc             string
-		timeout          bool
-		returnedErr      error
-		expectedErr      error
-		expectedDevCat   insteon.DevCat
-		expectedFirmware insteon.FirmwareVersion
-	}{
-		{"1", false, insteon.ErrReadTimeout, insteon.ErrReadTimeout, insteon.DevCat{0, 0}, 0},
-		{"2", false, nil, nil, insteon.DevCat{1, 2}, 3},
-		{"3", false, nil, nil, insteon.DevCat{2, 3}, 4},
-		{"4", true, nil, insteon.ErrReadTimeout, insteon.DevCat{0, 0}, 0},
-	}
-
-	for _, test := range tests {
-		t.Run(test.desc, func(t *testing.T) {
-			network, bridge := newTestNetwork()
-
-			go func() {
-				buf := <-bridge.send
-				if test.returnedErr == nil {
-					// the test has to send an ACK, since the device would ack the set button pressed
-					// command before sending a broadcast response
-					ack := &insteon.Message{}
-					ack.UnmarshalBinary(buf)
-					src := ack.Dst
-					ack.Dst = ack.Src
-					ack.Src = src
-					ack.Flags = insteon.StandardDirectAck
-					buf, _ := ack.MarshalBinary()
-					bridge.rx <- buf
-
-					if !test.timeout {
-						// send the broadcast
-						msg := *TestMessageSetButtonPressedController
-						msg.Src = src
-						msg.Dst = insteon.Address{test.expectedDevCat[0], test.expectedDevCat[1], byte(test.expectedFirmware)}
-						buf, _ := msg.MarshalBinary()
-						bridge.rx <- buf
-					}
-				}
-			}()
-
-			info, err := network.IDRequest(testDstAddr)
 
-			if err != test.expectedErr {
-				t.Errorf("got error %v, want %v", err, test.expectedErr)
+			if _, isDimmer := got.(insteon.Dimmer); isDimmer != test.wantDimmer {
+				t.Errorf("want dimmer %v got %T", test.wantDimmer, got)
 			}
 
-			if info.FirmwareVersion != test.expectedFirmware {
-				t.Errorf("got FirmwareVersion %v, want %v", info.FirmwareVersion, test.expectedFirmware)
+			if engine, id := device.count(insteon.CmdGetEngineVersion), device.count(insteon.CmdIDRequest); engine != test.wantEngine || id != test.wantID {
+				t.Errorf("want %d engine version and %d id requests got %d and %d", test.wantEngine, test.wantID, engine, id)
 			}
 
-			if info.DevCat != test.expectedDevCat {
-				t.Errorf("got DevCat %v, want %v", info.DevCat, test.expectedDevCat)
+			if info, _ := network.DB.Find(testDstAddr); info.EngineVersion != test.wantVersion {
+				t.Errorf("want engine version %v got %v", test.wantVersion, info.EngineVersion)
 			}
-			network.Close()
 		})
 	}
 }
 
-func TestNetworkDial(t *testing.T) {
-	tests := []struct {
-		desc          string
-		deviceInfo    *insteon.DeviceInfo
-		engineVersion byte
-		sendError     error
-		expectedErr   error
-		expected      interface{}
-	}{
-		{"1", &insteon.DeviceInfo{EngineVersion: insteon.VerI1}, 0, nil, nil, &insteon.I1Device{}},
-		{"2", &insteon.DeviceInfo{EngineVersion: insteon.VerI2}, 0, nil, nil, &insteon.I2Device{}},
-		{"3", &insteon.DeviceInfo{EngineVersion: insteon.VerI2Cs}, 0, nil, nil, &insteon.I2CsDevice{}},
-		{"4", nil, 0, nil, nil, &insteon.I1Device{}},
-		{"5", nil, 1, nil, nil, &insteon.I2Device{}},
-		{"6", nil, 2, nil, nil, &insteon.I2CsDevice{}},
-		{"7", nil, 3, nil, insteon.ErrVersion, nil},
-		{"8", nil, 0, insteon.ErrNotLinked, insteon.ErrNotLinked, &insteon.I2CsDevice{}},
-	}
-
-	for _, test := range tests {
-		t.Run(test.desc, func(t *testing.T) {
-			testDb := newTestProductDB()
-			network, bridge := newTestNetwork()
-			network.DB = testDb
-
-			if test.deviceInfo == nil {
-				bridge.sendErr = test.sendError
-				go func() {
-					<-bridge.send
-					if test.sendError == nil {
-						msg := *TestMessageEngineVersionAck
-						msg.Src = insteon.Address{1, 2, 3}
-						msg.Command = insteon.Command{0x00, msg.Command[1], byte(test.engineVersion)}
-						buf, _ := msg.MarshalBinary()
-						bridge.rx <- buf
-					}
-				}()
-			} else {
-				testDb.deviceInfo = test.deviceInfo
-			}
-
-			device, err := network.Dial(insteon.Address{1, 2, 3})
-
-			if err != test.expectedErr {
-				t.Errorf("got error %v, want %v", err, test.expectedErr)
-			} else if reflect.TypeOf(device) != reflect.TypeOf(test.expected) {
-				t.Fatalf("got type %T, want type %T", device, test.expected)
-			}
-
-			network.Close()
-		})
+func TestNetworkIDRequest(t *testing.T) {
+	network, modem, _ := newTestNetwork(t)
+	defer modem.Close()
+	defer network.Close()
+
+	want := insteon.DeviceInfo{Address: testDstAddr, DevCat: insteon.DevCat{0x01, 0x20}, FirmwareVersion: 0x45}
+	got, err := network.IDRequest(testDstAddr)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if got != want {
+		t.Errorf("want %+v got %+v", want, got)
 	}
 }
 
 func TestNetworkClose(t *testing.T) {
-	network, _ := newTestNetwork()
-	network.Close()
+	network, modem, device := newTestNetwork(t)
+	defer modem.Close()
+
+	done := make(chan bool)
+	go func() {
+		network.Close()
+		done <- true
+	}()
 
 	select {
-	case _, open := <-network.closeCh:
-		if open {
-			t.Error("Expected closeCh to be closed")
-		}
-	default:
-		t.Error("Expected read from closeCh to indicate a closed channel")
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out closing the network")
 	}
-}
 
-func TestNetworkConnect(t *testing.T) {
-	tests := []struct {
-		deviceInfo    *DeviceInfo
-		engineVersion EngineVersion
-		dst           Address
-		expected      Device
-	}{
-		{&DeviceInfo{DevCat: DevCat{42, 2}}, VerI1, Address{}, &I2Device{}},
-		{nil, VerI1, Address{42, 2, 3}, &I2Device{}},
+	// the PLM is an io.Closer, so closing the network closes the modem
+	if _, err := device.emulator.Write([]byte{0x02, 0x60}); err != plmtest.ErrClosed {
+		t.Errorf("want error %v got %v", plmtest.ErrClosed, err)
 	}
-
-	for _, test := range tests {
-		var category Category
-		testDb := newTestProductDB()
-		bridge := &testBridge{}
-
-		if test.deviceInfo == nil {
-			msg := *TestMessageEngineVersionAck
-			msg.Command[1] = byte(test.engineVersion)
-
-			msg = *TestMessageSetButtonPressedController
-			msg.Dst = test.dst
-			category = Category(test.dst[0])
-		} else {
-			testDb.deviceInfo = test.deviceInfo
-			category = test.deviceInfo.DevCat.Category()
-		}
-		Devices.Register(category, func(Device, DeviceInfo) Device { return test.expected })
-
-		network := &NetworkImpl{
-			db:     testDb,
-			bridge: bridge,
-		}
-
-		device, _ := network.Connect(Address{1, 2, 3})
-
-		if device != test.expected {
-			t.Fatalf("expected %v got %v", test.expected, device)
-		}
-		Devices.Delete(category)
-	}
-}*/
+}
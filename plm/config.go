@@ -32,12 +32,23 @@ func (config *Config) clearAutomaticLinking() { config.clearBit(7) }
 func (config *Config) MonitorMode() bool      { return (*config)&0x40 == 0x40 }
 func (config *Config) SetMonitorMode()        { config.setBit(6) }
 func (config *Config) clearMonitorMode()      { config.clearBit(6) }
-func (config *Config) AutomaticLED() bool     { return (*config)&0x20 == 0x20 }
-func (config *Config) setAutomaticLED()       { config.setBit(5) }
-func (config *Config) clearAutomaticLED()     { config.clearBit(5) }
-func (config *Config) DeadmanMode() bool      { return (*config)&0x10 == 0x10 }
-func (config *Config) setDeadmanMode()        { config.setBit(4) }
-func (config *Config) clearDeadmanMode()      { config.clearBit(4) }
+
+// AutomaticLED reports whether configuration bit 5 is set.  Despite its
+// name, setting the bit disables automatic LED operation, so a true value
+// means the host controls the LED.  HostLED reports the same bit under a
+// name that matches its meaning
+func (config *Config) AutomaticLED() bool { return (*config)&0x20 == 0x20 }
+
+// HostLED reports whether automatic LED operation is disabled (configuration
+// bit 5 is set).  When it is, the modem only changes its LED when the host
+// sends LED On or LED Off
+func (config *Config) HostLED() bool { return (*config)&0x20 == 0x20 }
+
+func (config *Config) setAutomaticLED()   { config.setBit(5) }
+func (config *Config) clearAutomaticLED() { config.clearBit(5) }
+func (config *Config) DeadmanMode() bool  { return (*config)&0x10 == 0x10 }
+func (config *Config) setDeadmanMode()    { config.setBit(4) }
+func (config *Config) clearDeadmanMode()  { config.clearBit(4) }
 
 func (config Config) String() string {
 	str := ""
@@ -32,6 +32,7 @@ func TestSettingConfigFlags(t *testing.T) {
 		{"AutomaticLinking", config.AutomaticLinking, config.setAutomaticLinking, config.clearAutomaticLinking, 0x80},
 		{"MonitorMode", config.MonitorMode, config.SetMonitorMode, config.clearMonitorMode, 0x40},
 		{"AutomaticLED", config.AutomaticLED, config.setAutomaticLED, config.clearAutomaticLED, 0x20},
+		{"HostLED", config.HostLED, config.setAutomaticLED, config.clearAutomaticLED, 0x20},
 		{"DeadmanMode", config.DeadmanMode, config.setDeadmanMode, config.clearDeadmanMode, 0x10},
 	}
 
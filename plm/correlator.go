@@ -0,0 +1,124 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/abates/insteon"
+)
+
+// requestBufLen is the number of packets that can be waiting
+// for a request's caller
+const requestBufLen = 4
+
+// request is an outstanding IM command.  The request receives the echo
+// of the command as well as any of the reply packets the command is
+// expected to produce (such as All-Link Record Responses)
+type request struct {
+	echo    Command
+	replies []Command
+	ch      chan *Packet
+}
+
+func (req *request) matches(packet *Packet) bool {
+	if packet.Command == req.echo {
+		return true
+	}
+
+	for _, reply := range req.replies {
+		if packet.Command == reply {
+			return true
+		}
+	}
+	return false
+}
+
+// wait returns the next packet delivered to the request.  ErrAckTimeout
+// is returned if no packet is received before the timeout and the
+// context's error is returned if the context is done first
+func (req *request) wait(ctx context.Context, timeout time.Duration) (*Packet, error) {
+	select {
+	case packet := <-req.ch:
+		return packet, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(timeout):
+		return nil, ErrAckTimeout
+	}
+}
+
+// correlator matches packets received from the modem with the
+// outstanding requests that are expecting them.  Packets that no
+// request is expecting are passed to the unexpected handler.  Delivery
+// never blocks, so the read loop can always make progress
+type correlator struct {
+	mu         sync.Mutex
+	pending    []*request
+	unexpected func(*Packet)
+}
+
+// register creates a request that will receive the echo of the given
+// command and any of the replies
+func (c *correlator) register(echo Command, replies ...Command) *request {
+	req := &request{echo: echo, replies: replies, ch: make(chan *Packet, requestBufLen)}
+	c.mu.Lock()
+	c.pending = append(c.pending, req)
+	c.mu.Unlock()
+	return req
+}
+
+// unregister removes the request.  Packets arriving for the request after
+// this point are considered unexpected
+func (c *correlator) unregister(req *request) {
+	c.mu.Lock()
+	for i, r := range c.pending {
+		if r == req {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			break
+		}
+	}
+	c.mu.Unlock()
+}
+
+// dispatch delivers the packet to the oldest request that matches.  A
+// lone NAK (sent when the modem is too busy to accept a command) is
+// delivered to the oldest request
+func (c *correlator) dispatch(packet *Packet) {
+	c.mu.Lock()
+	var req *request
+	for _, r := range c.pending {
+		if packet.Command == CmdNak || r.matches(packet) {
+			req = r
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	if req == nil {
+		if c.unexpected != nil {
+			c.unexpected(packet)
+		}
+		return
+	}
+
+	select {
+	case req.ch <- packet:
+	default:
+		insteon.Log.Infof("Request for %v is full, dropping %v", req.echo, packet)
+	}
+}
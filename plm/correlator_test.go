@@ -0,0 +1,128 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/abates/insteon"
+)
+
+func TestCorrelatorDispatch(t *testing.T) {
+	tests := []struct {
+		desc     string
+		packet   *Packet
+		wantReq  int
+		wantMiss bool
+	}{
+		{"echo", &Packet{Command: CmdGetNextAllLink, Ack: 0x06}, 1, false},
+		{"reply", &Packet{Command: CmdAllLinkRecordResp}, 1, false},
+		{"oldest", &Packet{Command: CmdGetInfo, Ack: 0x06}, 0, false},
+		{"lone nak", &Packet{Command: CmdNak, Payload: []byte{0x15}}, 0, false},
+		{"unexpected", &Packet{Command: CmdAllLinkComplete}, -1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var missed []*Packet
+			c := &correlator{unexpected: func(packet *Packet) { missed = append(missed, packet) }}
+			reqs := []*request{
+				c.register(CmdGetInfo),
+				c.register(CmdGetNextAllLink, CmdAllLinkRecordResp),
+				c.register(CmdGetInfo),
+			}
+
+			c.dispatch(test.packet)
+			for i, req := range reqs {
+				got, err := req.wait(context.Background(), time.Millisecond)
+				if i == test.wantReq {
+					if err != nil || got != test.packet {
+						t.Errorf("request %d: want %v got %v (%v)", i, test.packet, got, err)
+					}
+				} else if err != ErrAckTimeout {
+					t.Errorf("request %d: want error %v got %v", i, ErrAckTimeout, err)
+				}
+			}
+
+			if test.wantMiss != (len(missed) == 1) {
+				t.Errorf("want unexpected %v got %v", test.wantMiss, missed)
+			}
+		})
+	}
+}
+
+func TestCorrelatorUnregister(t *testing.T) {
+	var missed []*Packet
+	c := &correlator{unexpected: func(packet *Packet) { missed = append(missed, packet) }}
+	req := c.register(CmdGetInfo)
+	c.unregister(req)
+
+	c.dispatch(&Packet{Command: CmdGetInfo, Ack: 0x06})
+	if len(missed) != 1 {
+		t.Errorf("want packet for unregistered request to be unexpected got %v", missed)
+	}
+}
+
+func TestCorrelatorFull(t *testing.T) {
+	c := &correlator{}
+	req := c.register(CmdGetInfo)
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < requestBufLen*2; i++ {
+			c.dispatch(&Packet{Command: CmdGetInfo, Ack: 0x06})
+		}
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("dispatch blocked on a full request")
+	}
+
+	if len(req.ch) != requestBufLen {
+		t.Errorf("want %d buffered packets got %d", requestBufLen, len(req.ch))
+	}
+}
+
+func TestPLMUnexpectedPacket(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+	emulator.AddLinks(insteon.ControllerLink(1, insteon.Address{4, 5, 6}))
+
+	ch := plm.Events()
+	defer plm.Unsubscribe(ch)
+
+	// a stray link record response must not block the read loop
+	// or be mistaken for a reply to a later command
+	emulator.Inject([]byte{0x02, 0x57, 0xe2, 0x01, 0x07, 0x08, 0x09, 0x00, 0x00, 0x00})
+	select {
+	case got := <-ch:
+		if packet, ok := got.(*Packet); !ok || packet.Command != CmdAllLinkRecordResp {
+			t.Errorf("want %v got %v", CmdAllLinkRecordResp, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for unexpected packet")
+	}
+
+	if links, err := plm.Links(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(links) != 1 || links[0].Address != (insteon.Address{4, 5, 6}) {
+		t.Errorf("want link to 04.05.06 got %v", links)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/abates/insteon"
+)
+
+// eventBufLen is the number of events buffered for each subscriber
+// before new events are dropped
+const eventBufLen = 10
+
+// Event is an unsolicited message sent by the modem.  The concrete
+// type is one of *AllLinkComplete, ButtonEvent or UserReset.  Packets
+// that were not expected by any outstanding command, as well as the
+// All-Link Cleanup Failure Report and Status packets, are delivered
+// as *Packet
+type Event interface {
+	fmt.Stringer
+}
+
+// LinkCode indicates the type of link that was created (or deleted)
+// during all-linking
+type LinkCode byte
+
+// Link codes reported in the All-Link Complete message
+const (
+	LinkCodeResponder  LinkCode = 0x00 // the modem is a responder
+	LinkCodeController LinkCode = 0x01 // the modem is a controller
+	LinkCodeDeleted    LinkCode = 0xff // the link was deleted
+)
+
+func (lc LinkCode) String() string {
+	switch lc {
+	case LinkCodeResponder:
+		return "responder"
+	case LinkCodeController:
+		return "controller"
+	case LinkCodeDeleted:
+		return "deleted"
+	}
+	return fmt.Sprintf("LinkCode(0x%02x)", byte(lc))
+}
+
+// AllLinkComplete is sent by the modem when a link has been made (or
+// deleted) either by pressing the set button or after linking mode
+// was started by the host
+type AllLinkComplete struct {
+	LinkCode LinkCode
+	Group    insteon.Group
+	Address  insteon.Address
+	DevCat   insteon.DevCat
+	Firmware Version
+}
+
+func (alc *AllLinkComplete) String() string {
+	return fmt.Sprintf("All-Link Complete %s group %d %s category %s version %s", alc.LinkCode, alc.Group, alc.Address, alc.DevCat, alc.Firmware)
+}
+
+// UnmarshalBinary will take the payload of an All-Link Complete
+// packet and fill in the fields
+func (alc *AllLinkComplete) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 8 {
+		return fmt.Errorf("Needed 8 bytes to unmarshal all link complete.  Got %d", len(buf))
+	}
+	alc.LinkCode = LinkCode(buf[0])
+	alc.Group = insteon.Group(buf[1])
+	copy(alc.Address[:], buf[2:5])
+	copy(alc.DevCat[:], buf[5:7])
+	alc.Firmware = Version(buf[7])
+	return nil
+}
+
+// ButtonEvent is sent by the modem when one of its buttons is
+// tapped, held or released
+type ButtonEvent byte
+
+// Button events reported in the Button Event Report message
+const (
+	SetButtonTapped   ButtonEvent = 0x02
+	SetButtonHeld     ButtonEvent = 0x03
+	SetButtonReleased ButtonEvent = 0x04
+	Button2Tapped     ButtonEvent = 0x12
+	Button2Held       ButtonEvent = 0x13
+	Button2Released   ButtonEvent = 0x14
+	Button3Tapped     ButtonEvent = 0x22
+	Button3Held       ButtonEvent = 0x23
+	Button3Released   ButtonEvent = 0x24
+)
+
+func (be ButtonEvent) String() string {
+	button := "Set"
+	switch be & 0xf0 {
+	case 0x10:
+		button = "Button 2"
+	case 0x20:
+		button = "Button 3"
+	}
+
+	switch be & 0x0f {
+	case 0x02:
+		return fmt.Sprintf("%s Tapped", button)
+	case 0x03:
+		return fmt.Sprintf("%s Held", button)
+	case 0x04:
+		return fmt.Sprintf("%s Released", button)
+	}
+	return fmt.Sprintf("ButtonEvent(0x%02x)", byte(be))
+}
+
+// UserReset is sent by the modem after the set button has been held
+// during power up, which erases the all-link database and configuration
+type UserReset struct{}
+
+func (UserReset) String() string { return "User Reset Detected" }
+
+type eventListeners struct {
+	mu        sync.Mutex
+	listeners map[<-chan Event]chan Event
+}
+
+// decodeEvent converts an unsolicited packet into its Event
+func decodeEvent(packet *Packet) (event Event, err error) {
+	switch packet.Command {
+	case CmdAllLinkComplete:
+		alc := &AllLinkComplete{}
+		err = alc.UnmarshalBinary(packet.Payload)
+		event = alc
+	case CmdButtonEventReport:
+		if len(packet.Payload) < 1 {
+			err = fmt.Errorf("Needed 1 byte to unmarshal button event.  Got 0")
+		} else {
+			event = ButtonEvent(packet.Payload[0])
+		}
+	case CmdUserResetDetected:
+		event = UserReset{}
+	default:
+		err = fmt.Errorf("%v is not an event", packet.Command)
+	}
+	return event, err
+}
+
+// publish delivers the event to every subscriber.  publish never blocks,
+// if a subscriber's channel is full then the event is dropped for that
+// subscriber
+func (el *eventListeners) publish(event Event) {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	for _, ch := range el.listeners {
+		select {
+		case ch <- event:
+		default:
+			insteon.Log.Infof("Event listener is full, dropping %v", event)
+		}
+	}
+}
+
+// Events returns a channel that receives every unsolicited event sent
+// by the modem.  Slow subscribers will miss events once the channel's
+// buffer is full
+func (el *eventListeners) Events() <-chan Event {
+	ch := make(chan Event, eventBufLen)
+	el.mu.Lock()
+	if el.listeners == nil {
+		el.listeners = make(map[<-chan Event]chan Event)
+	}
+	el.listeners[ch] = ch
+	el.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe closes a channel returned by Events and stops delivery
+// of events to it
+func (el *eventListeners) Unsubscribe(ch <-chan Event) {
+	el.mu.Lock()
+	if listener, found := el.listeners[ch]; found {
+		close(listener)
+		delete(el.listeners, ch)
+	}
+	el.mu.Unlock()
+}
+
+// receiveEvent decodes and publishes an unsolicited packet.  Events that
+// change the modem's all-link database cause the cached links to be
+// invalidated
+func (plm *PLM) receiveEvent(packet *Packet) {
+	event, err := decodeEvent(packet)
+	if err != nil {
+		insteon.Log.Infof("Failed to decode event: %v", err)
+		return
+	}
+
+	insteon.Log.Debugf("Received %v", event)
+	switch event.(type) {
+	case *AllLinkComplete, UserReset:
+		plm.linkdb.invalidate()
+	}
+	plm.eventListeners.publish(event)
+}
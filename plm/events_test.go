@@ -0,0 +1,126 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abates/insteon"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	tests := []struct {
+		desc    string
+		input   *Packet
+		want    Event
+		wantErr bool
+	}{
+		{"all link complete", &Packet{Command: CmdAllLinkComplete, Payload: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x01, 0x20, 0x41}}, &AllLinkComplete{LinkCodeController, 2, insteon.Address{3, 4, 5}, insteon.DevCat{1, 0x20}, 0x41}, false},
+		{"short all link complete", &Packet{Command: CmdAllLinkComplete, Payload: []byte{0x01}}, nil, true},
+		{"button event", &Packet{Command: CmdButtonEventReport, Payload: []byte{0x03}}, SetButtonHeld, false},
+		{"short button event", &Packet{Command: CmdButtonEventReport}, nil, true},
+		{"user reset", &Packet{Command: CmdUserResetDetected}, UserReset{}, false},
+		{"not an event", &Packet{Command: CmdGetInfo}, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := decodeEvent(test.input)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("want error %v got %v", test.wantErr, err)
+			} else if err == nil && !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestEventStrings(t *testing.T) {
+	tests := []struct {
+		input Event
+		want  string
+	}{
+		{SetButtonTapped, "Set Tapped"},
+		{Button2Held, "Button 2 Held"},
+		{Button3Released, "Button 3 Released"},
+		{ButtonEvent(0x09), "ButtonEvent(0x09)"},
+		{UserReset{}, "User Reset Detected"},
+		{LinkCodeDeleted, "deleted"},
+		{LinkCode(0x02), "LinkCode(0x02)"},
+		{&AllLinkComplete{LinkCodeResponder, 1, insteon.Address{1, 2, 3}, insteon.DevCat{1, 0x20}, 0x41}, "All-Link Complete responder group 1 01.02.03 category 01.20 version 65"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.String(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPLMEvents(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+	emulator.AddLinks(insteon.ControllerLink(1, insteon.Address{4, 5, 6}))
+	if links, _ := plm.Links(); len(links) != 1 {
+		t.Fatalf("want 1 link got %d", len(links))
+	}
+
+	ch := plm.Events()
+	emulator.Inject([]byte{0x02, 0x54, 0x02})
+	emulator.UserReset()
+
+	for _, want := range []Event{SetButtonTapped, UserReset{}} {
+		select {
+		case got := <-ch:
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("want %v got %v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+
+	if links, _ := plm.Links(); len(links) != 0 {
+		t.Errorf("want link database to be invalidated, got %d links", len(links))
+	}
+
+	plm.Unsubscribe(ch)
+	if _, open := <-ch; open {
+		t.Errorf("expected channel to be closed")
+	}
+}
+
+func TestPLMDeviceEvents(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+
+	stream := plm.DeviceEvents()
+	defer stream.Close()
+
+	emulator.Receive(&insteon.Message{Src: insteon.Address{4, 5, 6}, Dst: insteon.Address{0, 0, 1}, Flags: insteon.Flag(insteon.MsgTypeAllLinkBroadcast, false, 3, 3), Command: insteon.CmdLightOff})
+	want := &insteon.Event{Src: insteon.Address{4, 5, 6}, Group: 1, Action: insteon.ActionOff}
+	select {
+	case got := <-stream.Events():
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("want %v got %v", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %v", want)
+	}
+}
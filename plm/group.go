@@ -0,0 +1,190 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abates/insteon"
+)
+
+// ErrCleanupAborted is returned by SendGroupCommand when the modem
+// stops sending all-link cleanup messages before every responder
+// has been reached.  This usually happens when other traffic is
+// received during the cleanup
+var ErrCleanupAborted = errors.New("PLM aborted the all-link cleanup")
+
+// GroupResult is the outcome of the all-link cleanup messages the modem
+// sends to each responder following a group command
+type GroupResult struct {
+	// Group is the group number the command was sent to
+	Group insteon.Group
+
+	// Acked is the list of responders that acknowledged the cleanup
+	Acked []insteon.Address
+
+	// Failed is the list of responders that either the modem reported
+	// as failed or that did not acknowledge the cleanup
+	Failed []insteon.Address
+}
+
+// Complete indicates that every responder acknowledged the group command
+func (gr *GroupResult) Complete() bool {
+	return len(gr.Failed) == 0
+}
+
+func (gr *GroupResult) String() string {
+	return fmt.Sprintf("group %d acked %v failed %v", gr.Group, gr.Acked, gr.Failed)
+}
+
+type cleanupTracker struct {
+	mu     sync.Mutex
+	active bool
+	group  insteon.Group
+	acked  map[insteon.Address]bool
+	failed map[insteon.Address]bool
+	status chan byte
+}
+
+func (ct *cleanupTracker) start(group insteon.Group) <-chan byte {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.active = true
+	ct.group = group
+	ct.acked = make(map[insteon.Address]bool)
+	ct.failed = make(map[insteon.Address]bool)
+	ct.status = make(chan byte, 1)
+	return ct.status
+}
+
+// stop ends tracking and builds the result for the given responders.  Any
+// responder that did not ack the cleanup is considered failed
+func (ct *cleanupTracker) stop(responders []insteon.Address) *GroupResult {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.active = false
+	result := &GroupResult{Group: ct.group}
+	for _, address := range responders {
+		if ct.acked[address] && !ct.failed[address] {
+			result.Acked = append(result.Acked, address)
+		} else {
+			result.Failed = append(result.Failed, address)
+		}
+	}
+	return result
+}
+
+// receiveAck records cleanup acknowledgements from responders
+func (ct *cleanupTracker) receiveAck(msg *insteon.Message) {
+	if msg.Flags.Type() != insteon.MsgTypeAllLinkCleanupAck {
+		return
+	}
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if ct.active && insteon.Group(msg.Command[2]) == ct.group {
+		insteon.Log.Debugf("%v acknowledged cleanup for group %d", msg.Src, ct.group)
+		ct.acked[msg.Src] = true
+	}
+}
+
+// receive handles the Cleanup Failure Report and Cleanup Status packets
+func (ct *cleanupTracker) receive(packet *Packet) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if !ct.active {
+		insteon.Log.Debugf("Ignoring %v, no group command in progress", packet)
+		return
+	}
+
+	switch packet.Command {
+	case CmdAllLinkCleanupFailure:
+		if len(packet.Payload) == 5 && insteon.Group(packet.Payload[1]) == ct.group {
+			address := insteon.Address{packet.Payload[2], packet.Payload[3], packet.Payload[4]}
+			insteon.Log.Debugf("%v failed cleanup for group %d", address, ct.group)
+			ct.failed[address] = true
+		}
+	case CmdAllLinkCleanupStatus:
+		if len(packet.Payload) == 1 {
+			select {
+			case ct.status <- packet.Payload[0]:
+			default:
+			}
+		}
+	}
+}
+
+// responders returns the address of every device the modem controls
+// for the given group.  Caller must hold the PLM lock
+func (plm *PLM) responders(ctx context.Context, group insteon.Group) (responders []insteon.Address, err error) {
+	err = plm.linkdb.refresh(ctx)
+	if err == nil {
+		seen := make(map[insteon.Address]bool)
+		for _, link := range plm.linkdb.links {
+			if link.Group == group && link.Flags.InUse() && link.Flags.Controller() && !seen[link.Address] {
+				seen[link.Address] = true
+				responders = append(responders, link.Address)
+			}
+		}
+	}
+	return responders, err
+}
+
+// SendGroupCommand sends the command to every device linked as a responder
+// of the given group.  The modem first broadcasts the command and then sends
+// an all-link cleanup to each responder in its link database.  SendGroupCommand
+// waits for the cleanup to finish and returns which responders acknowledged
+// the command and which did not.  When the cleanup does not finish (either it
+// was aborted or timed out) the partial result is returned along with the error.
+// The cleanup status and failure reports are also published to Events
+func (plm *PLM) SendGroupCommand(group insteon.Group, cmd insteon.Command) (*GroupResult, error) {
+	return plm.SendGroupCommandContext(context.Background(), group, cmd)
+}
+
+// SendGroupCommandContext is the same as SendGroupCommand except that waiting
+// for the cleanup is abandoned when the context is done.  The partial result is
+// returned along with the context's error
+func (plm *PLM) SendGroupCommandContext(ctx context.Context, group insteon.Group, cmd insteon.Command) (*GroupResult, error) {
+	plm.Lock()
+	defer plm.Unlock()
+
+	responders, err := plm.responders(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	status := plm.cleanup.start(group)
+	_, err = plm.tx(ctx, &Packet{Command: CmdSendAllLink, Payload: []byte{byte(group), cmd[1], cmd[2]}}, plm.writeDelay)
+	if err != nil {
+		plm.cleanup.stop(nil)
+		return nil, err
+	}
+
+	select {
+	case s := <-status:
+		if s != 0x06 {
+			err = ErrCleanupAborted
+		}
+	case <-time.After(plm.timeout * time.Duration(len(responders)+1)):
+		err = ErrReadTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return plm.cleanup.stop(responders), err
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abates/insteon"
+	"github.com/abates/insteon/plm/plmtest"
+)
+
+func TestSendGroupCommand(t *testing.T) {
+	addr1 := insteon.Address{4, 5, 6}
+	addr2 := insteon.Address{7, 8, 9}
+	links := []*insteon.LinkRecord{
+		insteon.ControllerLink(1, addr1),
+		insteon.ResponderLink(1, addr1),
+		insteon.ControllerLink(1, addr2),
+		insteon.ControllerLink(2, addr2),
+	}
+
+	tests := []struct {
+		desc       string
+		group      insteon.Group
+		offline    []insteon.Address
+		faults     []plmtest.Fault
+		wantAcked  []insteon.Address
+		wantFailed []insteon.Address
+		wantErr    error
+	}{
+		{"all acked", 1, nil, nil, []insteon.Address{addr1, addr2}, nil, nil},
+		{"partial", 1, []insteon.Address{addr2}, nil, []insteon.Address{addr1}, []insteon.Address{addr2}, nil},
+		{"single responder", 2, nil, nil, []insteon.Address{addr2}, nil, nil},
+		{"no responders", 3, nil, nil, nil, nil, nil},
+		{"nak", 1, nil, []plmtest.Fault{plmtest.FaultNak}, nil, nil, ErrNak},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+			emulator.AddLinks(links...)
+			emulator.Offline(test.offline...)
+			emulator.Fault(byte(CmdSendAllLink), test.faults...)
+
+			result, err := plm.SendGroupCommand(test.group, insteon.CmdLightOn)
+			if err != test.wantErr {
+				t.Fatalf("want error %v got %v", test.wantErr, err)
+			} else if err != nil {
+				return
+			}
+
+			if result.Group != test.group {
+				t.Errorf("want group %d got %d", test.group, result.Group)
+			}
+
+			if !reflect.DeepEqual(test.wantAcked, result.Acked) {
+				t.Errorf("want acked %v got %v", test.wantAcked, result.Acked)
+			}
+
+			if !reflect.DeepEqual(test.wantFailed, result.Failed) {
+				t.Errorf("want failed %v got %v", test.wantFailed, result.Failed)
+			}
+
+			if result.Complete() != (len(test.wantFailed) == 0) {
+				t.Errorf("want complete %v got %v", len(test.wantFailed) == 0, result.Complete())
+			}
+		})
+	}
+}
+
+func TestSendGroupCommandPublishes(t *testing.T) {
+	addr1 := insteon.Address{4, 5, 6}
+	addr2 := insteon.Address{7, 8, 9}
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+	emulator.AddLinks(insteon.ControllerLink(1, addr1), insteon.ControllerLink(1, addr2))
+	emulator.Offline(addr2)
+
+	events := plm.Events()
+	msgs := plm.bus.Subscribe(addr1)
+	if _, err := plm.SendGroupCommand(1, insteon.CmdLightOn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case msg := <-msgs:
+		if msg.Flags.Type() != insteon.MsgTypeAllLinkCleanupAck {
+			t.Errorf("want cleanup ack got %v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("timed out waiting for the cleanup ack")
+	}
+
+	for _, want := range []Command{CmdAllLinkCleanupFailure, CmdAllLinkCleanupStatus} {
+		select {
+		case event := <-events:
+			if packet, ok := event.(*Packet); !ok || packet.Command != want {
+				t.Errorf("want %v got %v", want, event)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for %v", want)
+		}
+	}
+}
+
+func TestSendGroupCommandContext(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+	emulator.AddLinks(insteon.ControllerLink(1, insteon.Address{4, 5, 6}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := plm.SendGroupCommandContext(ctx, 1, insteon.CmdLightOn); err != context.Canceled {
+		t.Errorf("want error %v got %v", context.Canceled, err)
+	}
+
+	// the lock must have been released
+	if _, err := plm.SendGroupCommand(1, insteon.CmdLightOn); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
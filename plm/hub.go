@@ -0,0 +1,259 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abates/insteon"
+)
+
+// ErrHubBuffer is returned when the hub's buffer status can not be parsed
+var ErrHubBuffer = errors.New("Invalid hub buffer status")
+
+// HubTransport is an io.ReadWriteCloser for the modem inside of an
+// Insteon Hub (2242) that is only reachable over HTTP.  Commands are
+// written by requesting /3?<hex>=I=3 and the modem's output is read
+// by polling /buffstatus.xml.  The buffer status is a ring buffer of
+// hex characters.  The last two characters of the buffer are the
+// offset (in hex characters) where the hub will write its next byte.
+// HubTransport tracks the offset and returns only the new bytes
+// since the last poll
+type HubTransport struct {
+	base     *url.URL
+	username string
+	password string
+	interval time.Duration
+	client   *http.Client
+
+	mu      sync.Mutex
+	offset  int
+	pending []byte
+	closed  bool
+	closeCh chan struct{}
+}
+
+// HubOption configures a HubTransport
+type HubOption func(h *HubTransport) error
+
+// HubAuth sets the basic authentication credentials used for
+// every request to the hub
+func HubAuth(username, password string) HubOption {
+	return func(h *HubTransport) error {
+		h.username = username
+		h.password = password
+		return nil
+	}
+}
+
+// PollInterval sets how often the hub's buffer is polled for new data
+func PollInterval(d time.Duration) HubOption {
+	return func(h *HubTransport) error {
+		if d <= 0 {
+			return errors.New("poll interval must be positive")
+		}
+		h.interval = d
+		return nil
+	}
+}
+
+// HTTPClient sets the client used to make requests to the hub
+func HTTPClient(client *http.Client) HubOption {
+	return func(h *HubTransport) error {
+		h.client = client
+		return nil
+	}
+}
+
+// NewHub returns a transport connected to the hub at the given base URL
+// (such as http://192.168.1.2:25105).  Credentials in the URL are used for
+// basic authentication unless overridden by HubAuth.  The hub's buffer is
+// read once so that any data already in the buffer is skipped
+func NewHub(baseURL string, options ...HubOption) (*HubTransport, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &HubTransport{
+		base:     base,
+		interval: 500 * time.Millisecond,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		closeCh:  make(chan struct{}),
+	}
+
+	if base.User != nil {
+		h.username = base.User.Username()
+		h.password, _ = base.User.Password()
+		base.User = nil
+	}
+
+	for _, o := range options {
+		err := o(h)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, h.offset, err = h.status()
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *HubTransport) get(path string) (body []byte, err error) {
+	req, err := http.NewRequest(http.MethodGet, h.base.String()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.username != "" || h.password != "" {
+		req.SetBasicAuth(h.username, h.password)
+	}
+
+	resp, err := h.client.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("hub responded to %s with %s", path, resp.Status)
+		}
+		body, err = ioutil.ReadAll(resp.Body)
+	}
+	return body, err
+}
+
+// status retrieves the hub's ring buffer and the current write offset
+func (h *HubTransport) status() (buffer string, offset int, err error) {
+	body, err := h.get("/buffstatus.xml")
+	if err != nil {
+		return "", 0, err
+	}
+
+	response := struct {
+		BS string `xml:"BS"`
+	}{}
+	err = xml.Unmarshal(body, &response)
+	if err != nil {
+		return "", 0, err
+	}
+
+	bs := strings.TrimSpace(response.BS)
+	if len(bs) < 4 || len(bs)%2 != 0 {
+		return "", 0, ErrHubBuffer
+	}
+
+	buffer = bs[:len(bs)-2]
+	o, err := strconv.ParseUint(bs[len(bs)-2:], 16, 8)
+	if err != nil || int(o) > len(buffer) || o%2 != 0 {
+		return "", 0, ErrHubBuffer
+	}
+	return buffer, int(o), nil
+}
+
+// poll reads the buffer status and returns any bytes written since the
+// last poll, taking into account the buffer wrapping around
+func (h *HubTransport) poll() ([]byte, error) {
+	buffer, offset, err := h.status()
+	if err != nil || offset == h.offset {
+		return nil, err
+	}
+
+	var data string
+	if offset > h.offset {
+		data = buffer[h.offset:offset]
+	} else {
+		data = buffer[h.offset:] + buffer[:offset]
+	}
+	h.offset = offset
+	return hex.DecodeString(data)
+}
+
+// Read returns bytes sent by the modem.  Read blocks, polling the hub,
+// until data is available or the transport is closed (in which case
+// io.EOF is returned).  Errors polling the hub are logged and polling
+// continues
+func (h *HubTransport) Read(buf []byte) (int, error) {
+	for {
+		h.mu.Lock()
+		closed := h.closed
+		if len(h.pending) > 0 {
+			n := copy(buf, h.pending)
+			h.pending = h.pending[n:]
+			h.mu.Unlock()
+			return n, nil
+		}
+		h.mu.Unlock()
+
+		if closed {
+			return 0, io.EOF
+		}
+
+		data, err := h.poll()
+		if err != nil {
+			insteon.Log.Infof("Failed to read hub buffer: %v", err)
+		}
+
+		if len(data) > 0 {
+			h.mu.Lock()
+			h.pending = append(h.pending, data...)
+			h.mu.Unlock()
+			continue
+		}
+
+		select {
+		case <-time.After(h.interval):
+		case <-h.closeCh:
+		}
+	}
+}
+
+// Write sends the bytes to the modem
+func (h *HubTransport) Write(buf []byte) (int, error) {
+	h.mu.Lock()
+	closed := h.closed
+	h.mu.Unlock()
+	if closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	_, err := h.get(fmt.Sprintf("/3?%s=I=3", strings.ToUpper(hex.EncodeToString(buf))))
+	if err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+// Close stops polling the hub
+func (h *HubTransport) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		close(h.closeCh)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abates/insteon"
+	"github.com/abates/insteon/plm/plmtest"
+)
+
+// fakeHub emulates the HTTP interface of a 2242 hub
+type fakeHub struct {
+	mu       sync.Mutex
+	ring     []byte
+	offset   int
+	writes   []string
+	username string
+	password string
+	status   string
+	onWrite  func([]byte)
+}
+
+func newFakeHub(size int) *fakeHub {
+	return &fakeHub{ring: bytes.Repeat([]byte{'0'}, size*2), username: "user", password: "pass"}
+}
+
+func (fh *fakeHub) append(data ...byte) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	for _, b := range data {
+		copy(fh.ring[fh.offset:], fmt.Sprintf("%02X", b))
+		fh.offset = (fh.offset + 2) % len(fh.ring)
+	}
+}
+
+func (fh *fakeHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if username, password, ok := r.BasicAuth(); !ok || username != fh.username || password != fh.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/buffstatus.xml":
+		fh.mu.Lock()
+		status := fh.status
+		if status == "" {
+			status = fmt.Sprintf("%s%02X", fh.ring, fh.offset)
+		}
+		fh.mu.Unlock()
+		fmt.Fprintf(w, "<response><BS>%s</BS></response>", status)
+	case "/3":
+		cmd := strings.TrimSuffix(r.URL.RawQuery, "=I=3")
+		fh.mu.Lock()
+		fh.writes = append(fh.writes, cmd)
+		fh.mu.Unlock()
+		if buf, err := hex.DecodeString(cmd); err == nil && fh.onWrite != nil {
+			fh.onWrite(buf)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestHubTransportRead(t *testing.T) {
+	hub := newFakeHub(8)
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	// data in the buffer before connecting is skipped
+	hub.append(0xde, 0xad)
+
+	transport, err := NewHub(server.URL, HubAuth("user", "pass"), PollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer transport.Close()
+
+	for _, want := range [][]byte{{0x02, 0x60, 0x01}, {0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}} {
+		// the second append wraps around the end of the buffer
+		hub.append(want...)
+		got := make([]byte, len(want))
+		_, err := io.ReadFull(transport, got)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		} else if !bytes.Equal(want, got) {
+			t.Errorf("want % x got % x", want, got)
+		}
+	}
+
+	transport.Close()
+	if _, err := transport.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("want error %v got %v", io.EOF, err)
+	}
+}
+
+func TestHubTransportWrite(t *testing.T) {
+	hub := newFakeHub(8)
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	// credentials can be given in the URL
+	transport, err := NewHub(strings.Replace(server.URL, "http://", "http://user:pass@", 1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	n, err := transport.Write([]byte{0x02, 0x62, 0xab, 0xcd, 0xef, 0x0f, 0x11, 0xff})
+	if err != nil || n != 8 {
+		t.Errorf("want 8 bytes written got %d (%v)", n, err)
+	}
+
+	want := []string{"0262ABCDEF0F11FF"}
+	if !reflect.DeepEqual(want, hub.writes) {
+		t.Errorf("want writes %v got %v", want, hub.writes)
+	}
+
+	transport.Close()
+	if _, err := transport.Write([]byte{0x02}); err != io.ErrClosedPipe {
+		t.Errorf("want error %v got %v", io.ErrClosedPipe, err)
+	}
+}
+
+func TestHubTransportStatus(t *testing.T) {
+	tests := []struct {
+		desc    string
+		status  string
+		wantErr bool
+	}{
+		{"valid", "0260000000", false},
+		{"odd length", "026000000", true},
+		{"short", "00", true},
+		{"bad offset", "02600000ZZ", true},
+		{"offset out of range", "0260000010", true},
+		{"odd offset", "0260000001", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			hub := newFakeHub(4)
+			hub.status = test.status
+			server := httptest.NewServer(hub)
+			defer server.Close()
+
+			_, err := NewHub(server.URL, HubAuth("user", "pass"))
+			if (err != nil) != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHubTransportAuth(t *testing.T) {
+	server := httptest.NewServer(newFakeHub(4))
+	defer server.Close()
+
+	if _, err := NewHub(server.URL, HubAuth("user", "wrong")); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestHubTransportPLM(t *testing.T) {
+	emulator := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+	hub := newFakeHub(100)
+	hub.onWrite = func(buf []byte) { emulator.Write(buf) }
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	go func() {
+		buf := make([]byte, 32)
+		for {
+			n, err := emulator.Read(buf)
+			if err != nil {
+				return
+			}
+			hub.append(buf[:n]...)
+		}
+	}()
+	defer emulator.Close()
+
+	transport, err := NewHub(server.URL, HubAuth("user", "pass"), PollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plm, err := New(NewPort(transport, time.Millisecond), time.Second, WriteDelay(time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer plm.Close()
+
+	want := &Info{insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42}
+	got, err := plm.Info()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v got %v", want, got)
+	}
+}
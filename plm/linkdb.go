@@ -15,7 +15,9 @@
 package plm
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/abates/insteon"
@@ -80,6 +82,9 @@ func (alr *allLinkReq) String() string {
 }
 
 type linkdb struct {
+	// ageMu protects age since the database can be invalidated
+	// by the read loop without holding the PLM lock
+	ageMu   sync.Mutex
 	age     time.Time
 	links   []*insteon.LinkRecord
 	plm     *PLM
@@ -87,83 +92,296 @@ type linkdb struct {
 }
 
 func (ldb *linkdb) old() bool {
+	ldb.ageMu.Lock()
+	defer ldb.ageMu.Unlock()
 	return ldb.age.Add(ldb.timeout).Before(time.Now())
 }
 
-func (ldb *linkdb) refresh() error {
+func (ldb *linkdb) refresh(ctx context.Context) error {
 	if !ldb.old() {
 		return nil
 	}
 	links := make([]*insteon.LinkRecord, 0)
 	insteon.Log.Debugf("Retrieving PLM link database")
-	_, err := ldb.plm.tx(&Packet{Command: CmdGetFirstAllLink}, 0)
-	// TODO: does this loop need a timeout as well?  If, for
-	// some reason, we are receiving packets on the plmCh, but
-	// none of them are AllLinkRecordResponses, then this will
-	// loop forever until a read timeout occurs...
+	link, err := ldb.nextRecord(ctx, CmdGetFirstAllLink)
 	for err == nil {
-		select {
-		case pkt := <-ldb.plm.plmCh:
-			if pkt.Command == CmdAllLinkRecordResp {
-				link := &insteon.LinkRecord{}
-				err = link.UnmarshalBinary(pkt.Payload)
-				if err == nil {
-					insteon.Log.Debugf("Received PLM record response %v", link)
-					links = append(links, link)
-					_, err = ldb.plm.tx(&Packet{Command: CmdGetNextAllLink}, 0)
-				} else {
-					insteon.Log.Infof("Failed to unmarshal link record: %v", err)
-					break
-				}
-			}
-		case <-time.After(ldb.plm.timeout):
+		insteon.Log.Debugf("Received PLM record response %v", link)
+		links = append(links, link)
+		link, err = ldb.nextRecord(ctx, CmdGetNextAllLink)
+	}
+
+	if err == ErrNak {
+		err = nil
+		ldb.links = links
+		ldb.ageMu.Lock()
+		ldb.age = time.Now()
+		ldb.ageMu.Unlock()
+	}
+	return err
+}
+
+// nextRecord requests the first or next record from the modem and waits
+// for the All-Link Record Response.  The modem will NAK the request when
+// there are no more records
+func (ldb *linkdb) nextRecord(ctx context.Context, cmd Command) (link *insteon.LinkRecord, err error) {
+	req := ldb.plm.correlator.register(cmd, CmdAllLinkRecordResp)
+	defer ldb.plm.correlator.unregister(req)
+
+	_, err = ldb.plm.txRequest(ctx, req, &Packet{Command: cmd}, 0, ldb.plm.timeout)
+	for err == nil {
+		var pkt *Packet
+		pkt, err = req.wait(ctx, ldb.plm.timeout)
+		if err == ErrAckTimeout {
 			err = ErrReadTimeout
+		} else if err == nil && pkt.Command == CmdAllLinkRecordResp {
+			link = &insteon.LinkRecord{}
+			err = link.UnmarshalBinary(pkt.Payload)
+			if err != nil {
+				insteon.Log.Infof("Failed to unmarshal link record: %v", err)
+			}
+			return link, err
+		}
+	}
+	return nil, err
+}
+
+// invalidate marks the cached links as stale so that the next
+// call to refresh will download the database from the modem
+func (ldb *linkdb) invalidate() {
+	ldb.ageMu.Lock()
+	ldb.age = time.Time{}
+	ldb.ageMu.Unlock()
+}
+
+// manage sends a Manage All-Link Record request to the modem.  The
+// modem will NAK a request when no matching record is found (for
+// find and delete commands) or when the database is full
+func (ldb *linkdb) manage(ctx context.Context, command recordRequestCommand, link *insteon.LinkRecord) (ack *Packet, err error) {
+	payload, err := (&manageRecordRequest{command: command, link: link}).MarshalBinary()
+	if err == nil {
+		insteon.Log.Debugf("Managing PLM link record %02x %v", command, link)
+		ack, err = ldb.plm.tx(ctx, &Packet{Command: CmdManageAllLinkRecord, Payload: payload}, 0)
+	}
+	return ack, err
+}
+
+// deleteAll removes every record matching the group and address
+// of the given link.  The modem deletes the first matching record
+// for each request and NAKs the request once no records are left.
+// A lone NAK means the modem was too busy to accept the request, so
+// the request is sent again
+func (ldb *linkdb) deleteAll(ctx context.Context, link *insteon.LinkRecord) (err error) {
+	busy := 0
+	for err == nil {
+		var ack *Packet
+		ack, err = ldb.manage(ctx, LinkCmdDeleteFirst, link)
+		if err == ErrNak && ack.Command == CmdNak {
+			err = nil
+			if busy++; busy == 3 {
+				err = ErrRetryCountExceeded
+			}
+		} else if err == nil {
+			busy = 0
 		}
 	}
 
 	if err == ErrNak {
 		err = nil
+		links := []*insteon.LinkRecord{}
+		for _, l := range ldb.links {
+			if l.Group != link.Group || l.Address != link.Address {
+				links = append(links, l)
+			}
+		}
 		ldb.links = links
 	}
 	return err
 }
 
+// addLink will modify the first existing controller or responder
+// record matching the link's group and address.  If no existing record
+// is found then a new record is added
+func (ldb *linkdb) addLink(ctx context.Context, link *insteon.LinkRecord) (err error) {
+	if link.Flags.Controller() {
+		_, err = ldb.manage(ctx, LinkCmdModFirstCtrl, link)
+	} else {
+		_, err = ldb.manage(ctx, LinkCmdModFirstResp, link)
+	}
+
+	if err == nil {
+		// copy the link so it can't be modified outside of the database
+		link = &insteon.LinkRecord{Flags: link.Flags, Group: link.Group, Address: link.Address, Data: link.Data}
+		for i, l := range ldb.links {
+			if l.Equal(link) {
+				ldb.links[i] = link
+				return nil
+			}
+		}
+		ldb.links = append(ldb.links, link)
+	}
+	return err
+}
+
+// removeLink deletes the record matching the given link.  Since the
+// modem can only delete the first record for a given group and address,
+// records that share the group and address, but are not the same type
+// (controller/responder), are deleted and then re-added
+func (ldb *linkdb) removeLink(ctx context.Context, link *insteon.LinkRecord) (err error) {
+	keep := []*insteon.LinkRecord{}
+	for _, l := range ldb.links {
+		if l.Group == link.Group && l.Address == link.Address && l.Flags.Controller() != link.Flags.Controller() {
+			keep = append(keep, l)
+		}
+	}
+
+	err = ldb.deleteAll(ctx, link)
+	for i := 0; i < len(keep) && err == nil; i++ {
+		err = ldb.addLink(ctx, keep[i])
+	}
+	return err
+}
+
 func (ldb *linkdb) Links() ([]*insteon.LinkRecord, error) {
+	return ldb.LinksContext(context.Background())
+}
+
+// LinksContext is the same as Links except that retrieving the link
+// database is abandoned when the context is done
+func (ldb *linkdb) LinksContext(ctx context.Context) ([]*insteon.LinkRecord, error) {
 	ldb.plm.Lock()
 	defer ldb.plm.Unlock()
-	err := ldb.refresh()
+	err := ldb.refresh(ctx)
 	return ldb.links, err
 }
 
-func (ldb *linkdb) WriteLinks(...*insteon.LinkRecord) error {
+// WriteLinks will overwrite the modem's all-link database with the list
+// of links provided.  Every existing record is deleted and then each link
+// that is marked in use is added.  Links marked available are skipped since
+// the modem does not keep available records
+func (ldb *linkdb) WriteLinks(links ...*insteon.LinkRecord) error {
+	return ldb.WriteLinksContext(context.Background(), links...)
+}
+
+// WriteLinksContext is the same as WriteLinks except that no more records
+// are changed once the context is done
+func (ldb *linkdb) WriteLinksContext(ctx context.Context, links ...*insteon.LinkRecord) error {
 	ldb.plm.Lock()
 	defer ldb.plm.Unlock()
-	return insteon.ErrNotImplemented
+	defer ldb.invalidate()
+
+	err := ldb.refresh(ctx)
+	for err == nil && len(ldb.links) > 0 {
+		err = ldb.deleteAll(ctx, ldb.links[0])
+	}
+
+	for i := 0; i < len(links) && err == nil; i++ {
+		if links[i].Flags.InUse() {
+			err = ldb.addLink(ctx, links[i])
+		}
+	}
+	return err
 }
 
-func (ldb *linkdb) UpdateLinks(...*insteon.LinkRecord) error {
+// UpdateLinks will add or update the given links in the modem's all-link
+// database.  Links that are marked available are removed from the database.
+// All other links are added, or their matching record is updated
+func (ldb *linkdb) UpdateLinks(links ...*insteon.LinkRecord) error {
+	return ldb.UpdateLinksContext(context.Background(), links...)
+}
+
+// UpdateLinksContext is the same as UpdateLinks except that no more records
+// are changed once the context is done
+func (ldb *linkdb) UpdateLinksContext(ctx context.Context, links ...*insteon.LinkRecord) error {
 	ldb.plm.Lock()
 	defer ldb.plm.Unlock()
-	return insteon.ErrNotImplemented
+	defer ldb.invalidate()
+
+	err := ldb.refresh(ctx)
+	for i := 0; i < len(links) && err == nil; i++ {
+		if links[i].Flags.Available() {
+			err = ldb.removeLink(ctx, links[i])
+		} else {
+			err = ldb.addLink(ctx, links[i])
+		}
+	}
+	return err
 }
 
 func (ldb *linkdb) EnterLinkingMode(group insteon.Group) error {
-	ldb.plm.Lock()
-	defer ldb.plm.Unlock()
+	return ldb.EnterLinkingModeContext(context.Background(), group)
+}
+
+// EnterLinkingModeContext is the same as EnterLinkingMode except that
+// waiting for the modem is abandoned when the context is done
+func (ldb *linkdb) EnterLinkingModeContext(ctx context.Context, group insteon.Group) error {
 	lr := &allLinkReq{Mode: linkingMode(0x03), Group: group}
 	payload, _ := lr.MarshalBinary()
-	_, err := ldb.plm.retry(&Packet{Command: CmdStartAllLink, Payload: payload}, 3)
+	_, err := ldb.plm.retry(ctx, &Packet{Command: CmdStartAllLink, Payload: payload}, 3)
 	return err
 }
 
+// AllLink puts the modem into linking mode and waits for a device to
+// complete the link (usually by pressing its set button).  The All-Link
+// Complete message identifying the device is returned.  If no device links
+// before the timeout then linking mode is cancelled and ErrLinkTimeout is
+// returned
+func (ldb *linkdb) AllLink(group insteon.Group, timeout time.Duration) (*AllLinkComplete, error) {
+	return ldb.AllLinkContext(context.Background(), group, timeout)
+}
+
+// AllLinkContext is the same as AllLink except that waiting for a device
+// is abandoned, and linking mode cancelled, when the context is done
+func (ldb *linkdb) AllLinkContext(ctx context.Context, group insteon.Group, timeout time.Duration) (*AllLinkComplete, error) {
+	events := ldb.plm.Events()
+	defer ldb.plm.Unsubscribe(events)
+
+	err := ldb.EnterLinkingModeContext(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.After(timeout)
+	for err == nil {
+		select {
+		case event := <-events:
+			if alc, ok := event.(*AllLinkComplete); ok {
+				return alc, nil
+			}
+		case <-deadline:
+			err = ErrLinkTimeout
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	// the context may be done, but the modem must still leave
+	// linking mode
+	if e := ldb.ExitLinkingMode(); e != nil {
+		err = e
+	}
+	return nil, err
+}
+
 func (ldb *linkdb) ExitLinkingMode() error {
-	_, err := ldb.plm.retry(&Packet{Command: CmdCancelAllLink}, 3)
+	return ldb.ExitLinkingModeContext(context.Background())
+}
+
+// ExitLinkingModeContext is the same as ExitLinkingMode except that
+// waiting for the modem is abandoned when the context is done
+func (ldb *linkdb) ExitLinkingModeContext(ctx context.Context) error {
+	_, err := ldb.plm.retry(ctx, &Packet{Command: CmdCancelAllLink}, 3)
 	return err
 }
 
 func (ldb *linkdb) EnterUnlinkingMode(group insteon.Group) error {
+	return ldb.EnterUnlinkingModeContext(context.Background(), group)
+}
+
+// EnterUnlinkingModeContext is the same as EnterUnlinkingMode except that
+// waiting for the modem is abandoned when the context is done
+func (ldb *linkdb) EnterUnlinkingModeContext(ctx context.Context, group insteon.Group) error {
 	lr := &allLinkReq{Mode: linkingMode(0xff), Group: group}
 	payload, _ := lr.MarshalBinary()
-	_, err := ldb.plm.retry(&Packet{Command: CmdStartAllLink, Payload: payload}, 3)
+	_, err := ldb.plm.retry(ctx, &Packet{Command: CmdStartAllLink, Payload: payload}, 3)
 	return err
 }
@@ -0,0 +1,205 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abates/insteon"
+	"github.com/abates/insteon/plm/plmtest"
+)
+
+func available(link *insteon.LinkRecord) *insteon.LinkRecord {
+	l := *link
+	l.Flags.SetAvailable()
+	return &l
+}
+
+func TestLinkdbWriteLinks(t *testing.T) {
+	ctrl1 := insteon.ControllerLink(1, insteon.Address{4, 5, 6})
+	resp1 := insteon.ResponderLink(1, insteon.Address{4, 5, 6})
+	ctrl2 := insteon.ControllerLink(2, insteon.Address{7, 8, 9})
+
+	tests := []struct {
+		desc     string
+		existing []*insteon.LinkRecord
+		input    []*insteon.LinkRecord
+		want     []*insteon.LinkRecord
+	}{
+		{"empty database", nil, []*insteon.LinkRecord{ctrl1, resp1}, []*insteon.LinkRecord{ctrl1, resp1}},
+		{"replace database", []*insteon.LinkRecord{ctrl1, resp1}, []*insteon.LinkRecord{ctrl2}, []*insteon.LinkRecord{ctrl2}},
+		{"skip available", []*insteon.LinkRecord{ctrl1}, []*insteon.LinkRecord{available(ctrl1), ctrl2}, []*insteon.LinkRecord{ctrl2}},
+		{"clear database", []*insteon.LinkRecord{ctrl1, resp1, ctrl2}, nil, []*insteon.LinkRecord{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+			emulator.AddLinks(test.existing...)
+
+			err := plm.WriteLinks(test.input...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.want, emulator.Links()) {
+				t.Errorf("want emulator links %v got %v", test.want, emulator.Links())
+			}
+
+			got, err := plm.Links()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if len(got) != len(test.want) {
+				t.Errorf("want %d cached links got %d", len(test.want), len(got))
+			}
+		})
+	}
+}
+
+func TestLinkdbUpdateLinks(t *testing.T) {
+	ctrl1 := insteon.ControllerLink(1, insteon.Address{4, 5, 6})
+	resp1 := insteon.ResponderLink(1, insteon.Address{4, 5, 6})
+	resp1Data := insteon.ResponderLink(1, insteon.Address{4, 5, 6})
+	resp1Data.Data = [3]byte{0xff, 0x1f, 0x01}
+	ctrl2 := insteon.ControllerLink(2, insteon.Address{7, 8, 9})
+
+	tests := []struct {
+		desc     string
+		existing []*insteon.LinkRecord
+		input    []*insteon.LinkRecord
+		want     []*insteon.LinkRecord
+	}{
+		{"add link", []*insteon.LinkRecord{ctrl1}, []*insteon.LinkRecord{ctrl2}, []*insteon.LinkRecord{ctrl1, ctrl2}},
+		{"update link", []*insteon.LinkRecord{ctrl1, resp1}, []*insteon.LinkRecord{resp1Data}, []*insteon.LinkRecord{ctrl1, resp1Data}},
+		{"remove link", []*insteon.LinkRecord{ctrl1, ctrl2}, []*insteon.LinkRecord{available(ctrl2)}, []*insteon.LinkRecord{ctrl1}},
+		{"remove shared link", []*insteon.LinkRecord{ctrl1, resp1, ctrl2}, []*insteon.LinkRecord{available(ctrl1)}, []*insteon.LinkRecord{ctrl2, resp1}},
+		{"remove missing link", []*insteon.LinkRecord{ctrl2}, []*insteon.LinkRecord{available(ctrl1)}, []*insteon.LinkRecord{ctrl2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+			emulator.AddLinks(test.existing...)
+
+			err := plm.UpdateLinks(test.input...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.want, emulator.Links()) {
+				t.Errorf("want emulator links %v got %v", test.want, emulator.Links())
+			}
+		})
+	}
+}
+
+func TestLinkdbDeleteBusy(t *testing.T) {
+	ctrl1 := insteon.ControllerLink(1, insteon.Address{4, 5, 6})
+	ctrl2 := insteon.ControllerLink(2, insteon.Address{7, 8, 9})
+
+	tests := []struct {
+		desc    string
+		faults  []plmtest.Fault
+		want    []*insteon.LinkRecord
+		wantErr error
+	}{
+		{"busy", []plmtest.Fault{plmtest.FaultBusy}, []*insteon.LinkRecord{ctrl1}, nil},
+		{"busy while deleting", []plmtest.Fault{plmtest.FaultNone, plmtest.FaultBusy, plmtest.FaultBusy}, []*insteon.LinkRecord{ctrl1}, nil},
+		{"retries exceeded", []plmtest.Fault{plmtest.FaultBusy, plmtest.FaultBusy, plmtest.FaultBusy}, []*insteon.LinkRecord{ctrl1, ctrl2}, ErrRetryCountExceeded},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+			emulator.AddLinks(ctrl1, ctrl2)
+			emulator.Fault(byte(CmdManageAllLinkRecord), test.faults...)
+
+			err := plm.UpdateLinks(available(ctrl2))
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(test.want, emulator.Links()) {
+				t.Errorf("want emulator links %v got %v", test.want, emulator.Links())
+			}
+		})
+	}
+}
+
+func TestLinkdbAllLink(t *testing.T) {
+	addr := insteon.Address{4, 5, 6}
+	devCat := insteon.DevCat{0x01, 0x20}
+
+	tests := []struct {
+		desc      string
+		linkCode  LinkCode
+		queue     bool
+		want      *AllLinkComplete
+		wantErr   error
+		wantLinks int
+	}{
+		{"controller", LinkCodeController, true, &AllLinkComplete{LinkCodeController, 1, addr, devCat, 0x41}, nil, 1},
+		{"responder", LinkCodeResponder, true, &AllLinkComplete{LinkCodeResponder, 1, addr, devCat, 0x41}, nil, 1},
+		{"timeout", LinkCodeController, false, nil, ErrLinkTimeout, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+			if test.queue {
+				emulator.QueueLink(byte(test.linkCode), addr, devCat, 0x41)
+			}
+
+			got, err := plm.AllLink(1, 10*time.Millisecond)
+			if err != test.wantErr {
+				t.Fatalf("want error %v got %v", test.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+
+			links, err := plm.Links()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if len(links) != test.wantLinks {
+				t.Errorf("want %d links got %d", test.wantLinks, len(links))
+			}
+		})
+	}
+}
+
+func TestLinkdbAllLinkContext(t *testing.T) {
+	plm, _ := newTestPLM(t)
+	defer plm.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	got, err := plm.AllLinkContext(ctx, 1, time.Hour)
+	if err != context.DeadlineExceeded {
+		t.Errorf("want error %v got %v", context.DeadlineExceeded, err)
+	}
+
+	if got != nil {
+		t.Errorf("want no link got %v", got)
+	}
+}
@@ -15,6 +15,7 @@
 package plm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -32,10 +33,17 @@ var (
 	ErrAckTimeout         = errors.New("Timeout waiting for Ack from the PLM")
 	ErrRetryCountExceeded = errors.New("Retry count exceeded sending command")
 	ErrNak                = errors.New("PLM responded with a NAK.  Resend command")
+	ErrLinkTimeout        = errors.New("Timeout waiting for a device to complete all-linking")
+	ErrLEDAutomatic       = errors.New("PLM LED is under automatic control")
+	ErrInvalidDevCat      = errors.New("Device category must be set")
 
 	MaxRetries = 3
 )
 
+// resetTimeout is how long to wait for the modem to acknowledge a reset,
+// which erases the modem's link database
+const resetTimeout = 20 * time.Second
+
 func hexDump(format string, buf []byte, sep string) string {
 	str := make([]string, len(buf))
 	for i, b := range buf {
@@ -47,15 +55,31 @@ func hexDump(format string, buf []byte, sep string) string {
 type PLM struct {
 	sync.Mutex
 	linkdb
+	x10Listeners
+	eventListeners
+	cleanup     cleanupTracker
+	correlator  correlator
 	timeout     time.Duration
 	writeDelay  time.Duration
 	nextWrite   time.Time
-	port        *Port
 	connections map[insteon.Address]insteon.Connection
-
-	insteonRxCh chan *insteon.Message
+	reconnect   reconnectPolicy
+	productDB   insteon.ProductDatabase
+
+	// stateMu protects the port, the last known configuration and
+	// the connection state which change when the PLM reconnects
+	stateMu  sync.Mutex
+	port     *Port
+	config   *Config
+	state    ConnectionState
+	lastRead time.Time
+	closeCh  chan struct{}
+
+	bus         *insteon.Bus
 	insteonTxCh chan *insteon.Message
-	plmCh       chan *Packet
+
+	trackerMu sync.Mutex
+	trackers  []*insteon.StateTracker
 }
 
 // The Option mechanism is based on the method described at https://dave.cheney.net/2014/10/17/functional-options-for-friendly-apis
@@ -68,13 +92,18 @@ func New(port *Port, timeout time.Duration, options ...Option) (*PLM, error) {
 		writeDelay:  500 * time.Millisecond,
 		port:        port,
 		connections: make(map[insteon.Address]insteon.Connection),
+		reconnect:   reconnectPolicy{initial: time.Second, max: time.Minute},
+		state:       StateDisconnected,
+		closeCh:     make(chan struct{}),
+		lastRead:    time.Now(),
 
 		insteonTxCh: make(chan *insteon.Message),
-		insteonRxCh: make(chan *insteon.Message),
-		plmCh:       make(chan *Packet),
+		bus:         insteon.NewBus(),
 	}
+	plm.correlator.unexpected = plm.unexpected
 	plm.linkdb.plm = plm
 	plm.linkdb.timeout = timeout
+	plm.x10Listeners.bufLen = x10BufLen
 
 	for _, o := range options {
 		err := o(plm)
@@ -84,8 +113,22 @@ func New(port *Port, timeout time.Duration, options ...Option) (*PLM, error) {
 		}
 	}
 
+	// the port is open before the read loop starts, so any failure
+	// is seen after the PLM is marked connected
+	plm.setState(StateConnected)
 	go plm.readLoop()
 	go plm.writeLoop()
+	if plm.reconnect.probe > 0 {
+		go plm.probeLoop()
+	}
+
+	// the configuration is cached so that it can be restored after
+	// reconnecting, even if the caller never reads or sets it
+	if plm.reconnect.dial != nil {
+		if _, err := plm.Config(); err != nil {
+			insteon.Log.Infof("Failed to read PLM config, it will not be restored after reconnecting: %v", err)
+		}
+	}
 	return plm, nil
 }
 
@@ -97,10 +140,23 @@ func WriteDelay(d time.Duration) Option {
 	}
 }
 
+// ProductDB can be passed as a parameter to New so that Open uses the device
+// information in the database rather than querying the device.  Devices that
+// are not in the database are queried and the results are saved (such as
+// to a network.FileProductDB)
+func ProductDB(db insteon.ProductDatabase) Option {
+	return func(p *PLM) error {
+		p.productDB = db
+		return nil
+	}
+}
+
 func (plm *PLM) readLoop() {
 	for {
-		buf, err := plm.port.Read()
+		port := plm.currentPort()
+		buf, err := port.Read()
 		if err == nil {
+			plm.markRead()
 			packet := &Packet{}
 			err := packet.UnmarshalBinary(buf)
 
@@ -110,12 +166,20 @@ func (plm *PLM) readLoop() {
 					msg := &insteon.Message{}
 					err := msg.UnmarshalBinary(packet.Payload)
 					if err == nil {
-						plm.insteonRxCh <- msg
+						plm.cleanup.receiveAck(msg)
+						plm.bus.Publish(msg)
 					} else {
 						insteon.Log.Infof("Failed to unmarshal Insteon Message: %v", err)
 					}
+				} else if packet.Command == CmdX10MsgReceived {
+					plm.x10Listeners.receive(packet)
+				} else if packet.Command == CmdAllLinkCleanupFailure || packet.Command == CmdAllLinkCleanupStatus {
+					plm.cleanup.receive(packet)
+					plm.eventListeners.publish(packet)
+				} else if CmdAllLinkComplete <= packet.Command && packet.Command <= CmdUserResetDetected {
+					plm.receiveEvent(packet)
 				} else {
-					plm.plmCh <- packet
+					plm.correlator.dispatch(packet)
 				}
 			} else {
 				insteon.Log.Infof("Failed to unmarshal packet: %v", err)
@@ -124,7 +188,10 @@ func (plm *PLM) readLoop() {
 			if err != io.EOF {
 				insteon.Log.Infof("Failed to read from PLM port: %v", err)
 			}
-			break
+
+			if !plm.reopen(port) {
+				break
+			}
 		}
 	}
 }
@@ -145,7 +212,10 @@ func (plm *PLM) writeLoop() {
 					writeDelay = time.Second * time.Duration(12*msg.Flags.TTL()) / 60
 				}
 			}
-			_, err = plm.send(&Packet{Command: 0x62, Payload: buf}, writeDelay)
+			// the trackers must know about the message before
+			// the device can ack it
+			plm.sent(msg)
+			_, err = plm.send(context.Background(), &Packet{Command: 0x62, Payload: buf}, writeDelay)
 			if err != nil {
 				insteon.Log.Infof("Failed to send packet: %v", err)
 			}
@@ -158,82 +228,173 @@ func (plm *PLM) writeLoop() {
 // transmit a packet and wait for the PLM to ack that the packet was
 // sent.  This is a blocking function. Only callers that have acquired
 // the mutex shoud call this function
-func (plm *PLM) tx(txPacket *Packet, writeDelay time.Duration) (ack *Packet, err error) {
+func (plm *PLM) tx(ctx context.Context, txPacket *Packet, writeDelay time.Duration) (ack *Packet, err error) {
+	req := plm.correlator.register(txPacket.Command)
+	defer plm.correlator.unregister(req)
+	return plm.txRequest(ctx, req, txPacket, writeDelay, plm.timeout)
+}
+
+// txRequest transmits a packet for a request that has already been
+// registered and waits, up to timeout, for the echo.  Any replies the
+// request expects are left for the caller to wait for.  Only callers
+// that have acquired the mutex should call this function
+func (plm *PLM) txRequest(ctx context.Context, req *request, txPacket *Packet, writeDelay, timeout time.Duration) (ack *Packet, err error) {
 	buf, err := txPacket.MarshalBinary()
 	if err == nil {
 		if time.Now().Before(plm.nextWrite) {
-			<-time.After(plm.nextWrite.Sub(time.Now()))
+			select {
+			case <-time.After(plm.nextWrite.Sub(time.Now())):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 
-		plm.port.Write(buf)
+		plm.currentPort().Write(buf)
 		plm.nextWrite = time.Now().Add(writeDelay)
 
-		// loop until either timeout or the appropriate ack is received
-		timeout := time.Now().Add(plm.timeout)
+		// loop until either timeout or the echo is received
+		deadline := time.Now().Add(timeout)
 		for err == nil {
-			select {
-			case rxPacket := <-plm.plmCh:
-				if txPacket.Command == rxPacket.Command {
-					ack = rxPacket
-					if rxPacket.NAK() {
-						err = ErrNak
-					}
-					return
+			var rxPacket *Packet
+			rxPacket, err = req.wait(ctx, deadline.Sub(time.Now()))
+			if err == nil {
+				if rxPacket.Command == CmdNak || rxPacket.NAK() {
+					return rxPacket, ErrNak
+				} else if rxPacket.Command == txPacket.Command {
+					return rxPacket, nil
 				}
-			case <-time.After(plm.timeout):
-				err = ErrAckTimeout
-			}
-
-			if timeout.Before(time.Now()) {
-				err = ErrAckTimeout
 			}
 		}
 	}
 	return
 }
 
+// unexpected is called for every packet that was not expected by an
+// outstanding request.  The packet is published as an event
+func (plm *PLM) unexpected(packet *Packet) {
+	insteon.Log.Debugf("Received unexpected packet %v", packet)
+	plm.eventListeners.publish(packet)
+}
+
 // send a packet and wait for the PLM to ack that the packet was
 // sent.  This is a blocking function
-func (plm *PLM) send(txPacket *Packet, writeDelay time.Duration) (ack *Packet, err error) {
+func (plm *PLM) send(ctx context.Context, txPacket *Packet, writeDelay time.Duration) (ack *Packet, err error) {
 	plm.Lock()
 	defer plm.Unlock()
-	return plm.tx(txPacket, writeDelay)
+	return plm.tx(ctx, txPacket, writeDelay)
 }
 
 func (plm *PLM) Connect(addr insteon.Address, options ...insteon.ConnectionOption) (insteon.Connection, error) {
 	if conn, found := plm.connections[addr]; found {
 		return conn, nil
 	}
-	conn, err := insteon.NewConnection(plm.insteonTxCh, plm.insteonRxCh, addr, options...)
+	rxCh := plm.bus.Subscribe(addr)
+	conn, err := insteon.NewConnection(plm.insteonTxCh, rxCh, addr, options...)
 	if err == nil {
 		plm.connections[addr] = conn
+	} else {
+		plm.bus.Unsubscribe(rxCh)
 	}
 	return conn, err
 }
 
+// Monitor returns a channel that receives every Insteon message received
+// by the modem.  The channel should be passed to StopMonitor when it is
+// no longer needed
+func (plm *PLM) Monitor() <-chan *insteon.Message {
+	return plm.bus.SubscribeAll()
+}
+
+// StopMonitor closes a channel returned by Monitor
+func (plm *PLM) StopMonitor(ch <-chan *insteon.Message) {
+	plm.bus.Unsubscribe(ch)
+}
+
+// DeviceEvents returns a stream of the events (button presses, status
+// changes, heartbeats, etc) that devices broadcast to the network.  The
+// stream should be closed when it is no longer needed
+func (plm *PLM) DeviceEvents() *insteon.EventStream {
+	return insteon.NewEventStream(plm.bus)
+}
+
+// TrackState returns a tracker that caches the levels of devices as they
+// are learned from messages received by the modem.  Group commands sent with
+// SendGroupCommand are not received by the modem, so they must be passed to
+// the tracker's GroupCommand method.  Direct messages sent by the PLM are
+// passed to the tracker's Sent method so their acks can be interpreted
+func (plm *PLM) TrackState(options ...insteon.StateOption) (*insteon.StateTracker, error) {
+	tracker, err := insteon.NewStateTracker(plm.bus, options...)
+	if err == nil {
+		plm.trackerMu.Lock()
+		plm.trackers = append(plm.trackers, tracker)
+		plm.trackerMu.Unlock()
+	}
+	return tracker, err
+}
+
+func (plm *PLM) sent(msg *insteon.Message) {
+	plm.trackerMu.Lock()
+	defer plm.trackerMu.Unlock()
+	for _, tracker := range plm.trackers {
+		tracker.Sent(msg)
+	}
+}
+
+// Open returns a device for the given address.  The device is queried for its
+// engine version and device category unless they were found in the ProductDB
+// passed to New
 func (plm *PLM) Open(addr insteon.Address, options ...insteon.ConnectionOption) (insteon.Device, error) {
+	return plm.OpenContext(context.Background(), addr, options...)
+}
+
+// OpenContext is the same as Open except that querying the device is
+// abandoned when the context is done
+func (plm *PLM) OpenContext(ctx context.Context, addr insteon.Address, options ...insteon.ConnectionOption) (insteon.Device, error) {
 	conn, err := plm.Connect(addr, options...)
 	if err != nil {
 		return nil, err
+	} else if plm.productDB == nil {
+		return insteon.OpenContext(ctx, conn, plm.timeout)
+	}
+
+	info, found := plm.productDB.Find(addr)
+	if found && info.DevCat != (insteon.DevCat{}) && info.EngineVersionKnown {
+		return insteon.Devices.New(info, conn, plm.timeout)
+	}
+
+	info = insteon.DeviceInfo{Address: addr}
+	info.EngineVersion, err = conn.EngineVersionContext(ctx)
+	if err == insteon.ErrNotLinked {
+		plm.productDB.UpdateEngineVersion(addr, insteon.VerI2Cs)
+		device, _ := insteon.New(insteon.VerI2Cs, conn, plm.timeout)
+		return device, err
+	}
+
+	if err == nil {
+		info.FirmwareVersion, info.DevCat, err = conn.IDRequestContext(ctx)
 	}
 
-	return insteon.Open(conn, plm.timeout)
+	if err == nil {
+		info.EngineVersionKnown = true
+		plm.productDB.Update(addr, info)
+		return insteon.Devices.New(info, conn, plm.timeout)
+	}
+	return nil, err
 }
 
 // retry will deliver a packet to the Insteon network. If delivery fails (due
 // to a NAK from the PLM) then we will retry and decrement retries. This
 // continues until the packet is sent (as acknowledged by the PLM) or retries
 // reaches zero
-func (plm *PLM) retry(packet *Packet, retries int) (ack *Packet, err error) {
+func (plm *PLM) retry(ctx context.Context, packet *Packet, retries int) (ack *Packet, err error) {
 	plm.Lock()
 	defer plm.Unlock()
 
-	for err == ErrNak || retries > 0 {
-		ack, err = plm.tx(packet, time.Second)
-		if err == nil {
+	for ; retries > 0; retries-- {
+		ack, err = plm.tx(ctx, packet, time.Second)
+		if err == nil || ctx.Err() != nil {
 			break
 		}
-		retries--
 	}
 
 	if err == ErrNak {
@@ -244,7 +405,13 @@ func (plm *PLM) retry(packet *Packet, retries int) (ack *Packet, err error) {
 }
 
 func (plm *PLM) Info() (info *Info, err error) {
-	ack, err := plm.send(&Packet{Command: CmdGetInfo}, 0)
+	return plm.InfoContext(context.Background())
+}
+
+// InfoContext is the same as Info except that waiting for the modem
+// is abandoned when the context is done
+func (plm *PLM) InfoContext(ctx context.Context) (info *Info, err error) {
+	ack, err := plm.send(ctx, &Packet{Command: CmdGetInfo}, 0)
 	if err == nil {
 		info = &Info{}
 		err = info.UnmarshalBinary(ack.Payload)
@@ -253,38 +420,209 @@ func (plm *PLM) Info() (info *Info, err error) {
 }
 
 func (plm *PLM) Reset() error {
-	timeout := plm.timeout
-	plm.timeout = 20 * time.Second
+	return plm.ResetContext(context.Background())
+}
 
-	_, err := plm.send(&Packet{Command: CmdReset}, 0)
-	plm.timeout = timeout
+// ResetContext is the same as Reset except that waiting for the modem
+// is abandoned when the context is done.  The modem can take several
+// seconds to erase its database, so the echo is waited for up to
+// resetTimeout rather than the usual timeout
+func (plm *PLM) ResetContext(ctx context.Context) error {
+	plm.Lock()
+	defer plm.Unlock()
+
+	txPacket := &Packet{Command: CmdReset}
+	req := plm.correlator.register(txPacket.Command)
+	defer plm.correlator.unregister(req)
+	_, err := plm.txRequest(ctx, req, txPacket, 0, resetTimeout)
 	return err
 }
 
 func (plm *PLM) Config() (config *Config, err error) {
-	ack, err := plm.send(&Packet{Command: CmdGetConfig}, 0)
+	return plm.ConfigContext(context.Background())
+}
+
+// ConfigContext is the same as Config except that waiting for the modem
+// is abandoned when the context is done
+func (plm *PLM) ConfigContext(ctx context.Context) (config *Config, err error) {
+	ack, err := plm.send(ctx, &Packet{Command: CmdGetConfig}, 0)
 	if err == nil {
+		config = new(Config)
 		err = config.UnmarshalBinary(ack.Payload)
+		if err == nil {
+			plm.saveConfig(config)
+		}
 	}
 	return config, err
 }
 
 func (plm *PLM) SetConfig(config *Config) error {
+	return plm.SetConfigContext(context.Background(), config)
+}
+
+// SetConfigContext is the same as SetConfig except that waiting for the
+// modem is abandoned when the context is done
+func (plm *PLM) SetConfigContext(ctx context.Context, config *Config) error {
 	payload, _ := config.MarshalBinary()
-	_, err := plm.send(&Packet{Command: CmdSetConfig, Payload: payload}, 0)
+	_, err := plm.send(ctx, &Packet{Command: CmdSetConfig, Payload: payload}, 0)
+	if err == nil {
+		plm.saveConfig(config)
+	}
+	return err
+}
+
+// NakError is returned when the modem refuses a host command by responding
+// with a NAK
+type NakError struct {
+	Command Command // the command the modem refused
+}
+
+func (ne *NakError) Error() string {
+	return fmt.Sprintf("PLM responded to %v with a NAK", ne.Command)
+}
+
+// RangeError is returned when an argument that is sent to the modem as
+// a single byte is outside of the range 0 to 255
+type RangeError struct {
+	Name  string // the name of the argument
+	Value int    // the value that was out of range
+}
+
+func (re *RangeError) Error() string {
+	return fmt.Sprintf("%s must be between 0 and 255, got %d", re.Name, re.Value)
+}
+
+// byteArg makes sure an argument will fit in a single byte
+func byteArg(name string, value int) (byte, error) {
+	if value < 0 || value > 0xff {
+		return 0, &RangeError{Name: name, Value: value}
+	}
+	return byte(value), nil
+}
+
+// command sends a host command to the modem.  A NAK from the modem is
+// returned as a *NakError
+func (plm *PLM) command(ctx context.Context, command Command, payload []byte) error {
+	_, err := plm.send(ctx, &Packet{Command: command, Payload: payload}, 0)
+	if err == ErrNak {
+		err = &NakError{Command: command}
+	}
+	return err
+}
+
+// SetDeviceCategory sets the device category and firmware version that the
+// modem reports when it is queried (for instance with an ID Request) by
+// other devices on the network.  ErrInvalidDevCat is returned if the device
+// category is unset and a *RangeError if the firmware doesn't fit in a byte
+func (plm *PLM) SetDeviceCategory(devCat insteon.DevCat, firmware int) error {
+	return plm.SetDeviceCategoryContext(context.Background(), devCat, firmware)
+}
+
+// SetDeviceCategoryContext is the same as SetDeviceCategory except that
+// waiting for the modem is abandoned when the context is done
+func (plm *PLM) SetDeviceCategoryContext(ctx context.Context, devCat insteon.DevCat, firmware int) error {
+	if devCat == (insteon.DevCat{}) {
+		return ErrInvalidDevCat
+	}
+
+	fw, err := byteArg("firmware", firmware)
+	if err == nil {
+		err = plm.command(ctx, CmdSetHostCategory, []byte{devCat[0], devCat[1], fw})
+	}
 	return err
 }
 
-func (plm *PLM) SetDeviceCategory(insteon.Category) error {
-	// TODO
-	return ErrNotImplemented
+// RFSleep puts the modem's radio to sleep.  The modem wakes up as soon
+// as the host sends it another command
+func (plm *PLM) RFSleep() error { return plm.RFSleepContext(context.Background()) }
+
+// RFSleepContext is the same as RFSleep except that waiting for the modem
+// is abandoned when the context is done
+func (plm *PLM) RFSleepContext(ctx context.Context) error {
+	return plm.command(ctx, CmdRfSleep, nil)
 }
 
-func (plm *PLM) RFSleep() error {
-	// TODO
-	return ErrNotImplemented
+// SetAckByte sets the command 2 byte the modem uses when it acknowledges
+// direct messages sent to it.  This allows the modem to respond to status
+// requests as though it were a device
+func (plm *PLM) SetAckByte(cmd2 int) error {
+	return plm.SetAckByteContext(context.Background(), cmd2)
 }
 
+// SetAckByteContext is the same as SetAckByte except that waiting for the
+// modem is abandoned when the context is done
+func (plm *PLM) SetAckByteContext(ctx context.Context, cmd2 int) error {
+	b2, err := byteArg("cmd2", cmd2)
+	if err == nil {
+		err = plm.command(ctx, CmdSetAckMsg, []byte{b2})
+	}
+	return err
+}
+
+// SetNakByte sets the command 2 byte the modem uses when it NAKs direct
+// messages sent to it
+func (plm *PLM) SetNakByte(cmd2 int) error {
+	return plm.SetNakByteContext(context.Background(), cmd2)
+}
+
+// SetNakByteContext is the same as SetNakByte except that waiting for the
+// modem is abandoned when the context is done
+func (plm *PLM) SetNakByteContext(ctx context.Context, cmd2 int) error {
+	b2, err := byteArg("cmd2", cmd2)
+	if err == nil {
+		err = plm.command(ctx, CmdSetNakMsgByte, []byte{b2})
+	}
+	return err
+}
+
+// SetNakBytes sets both the command 1 and command 2 bytes the modem uses
+// when it NAKs direct messages sent to it
+func (plm *PLM) SetNakBytes(cmd1, cmd2 int) error {
+	return plm.SetNakBytesContext(context.Background(), cmd1, cmd2)
+}
+
+// SetNakBytesContext is the same as SetNakBytes except that waiting for the
+// modem is abandoned when the context is done
+func (plm *PLM) SetNakBytesContext(ctx context.Context, cmd1, cmd2 int) error {
+	b1, err := byteArg("cmd1", cmd1)
+	if err == nil {
+		var b2 byte
+		b2, err = byteArg("cmd2", cmd2)
+		if err == nil {
+			err = plm.command(ctx, CmdSetNameMsgTwoBytes, []byte{b1, b2})
+		}
+	}
+	return err
+}
+
+// led turns the modem's LED on or off.  The modem only allows the host to
+// control the LED once automatic LED operation has been disabled (see
+// Config.HostLED)
+func (plm *PLM) led(ctx context.Context, command Command) error {
+	config, err := plm.ConfigContext(ctx)
+	if err == nil {
+		if !config.HostLED() {
+			return ErrLEDAutomatic
+		}
+		err = plm.command(ctx, command, nil)
+	}
+	return err
+}
+
+// LEDOn turns the modem's LED on
+func (plm *PLM) LEDOn() error { return plm.led(context.Background(), CmdLedOn) }
+
+// LEDOnContext is the same as LEDOn except that waiting for the modem is
+// abandoned when the context is done
+func (plm *PLM) LEDOnContext(ctx context.Context) error { return plm.led(ctx, CmdLedOn) }
+
+// LEDOff turns the modem's LED off
+func (plm *PLM) LEDOff() error { return plm.led(context.Background(), CmdLedOff) }
+
+// LEDOffContext is the same as LEDOff except that waiting for the modem is
+// abandoned when the context is done
+func (plm *PLM) LEDOffContext(ctx context.Context) error { return plm.led(ctx, CmdLedOff) }
+
 func (plm *PLM) Address() insteon.Address {
 	info, err := plm.Info()
 	if err == nil {
@@ -297,7 +635,22 @@ func (plm *PLM) String() string {
 	return fmt.Sprintf("PLM (%s)", plm.Address())
 }
 
-func (plm *PLM) Close() {
+// Close stops the PLM and closes its port.  The error from closing the
+// port is returned.  Calling Close more than once has no effect
+func (plm *PLM) Close() error {
+	plm.stateMu.Lock()
+	select {
+	case <-plm.closeCh:
+		plm.stateMu.Unlock()
+		return nil
+	default:
+	}
+	close(plm.closeCh)
+	port := plm.port
+	plm.stateMu.Unlock()
+
 	close(plm.insteonTxCh)
-	plm.port.Close()
+	err := port.Close()
+	plm.bus.Close()
+	return err
 }
@@ -3,8 +3,14 @@ package plm
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"fmt"
+	"reflect"
 	"testing"
 	"time"
+
+	"github.com/abates/insteon"
+	"github.com/abates/insteon/plm/plmtest"
 )
 
 func TestPlmOption(t *testing.T) {
@@ -29,3 +35,514 @@ func TestPlmOption(t *testing.T) {
 	}
 
 }
+
+func newTestPLM(t *testing.T) (*PLM, *plmtest.PLM) {
+	emulator := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+	plm, err := New(NewPort(emulator, time.Millisecond), 100*time.Millisecond, WriteDelay(time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error from plm.New(): %v", err)
+	}
+	return plm, emulator
+}
+
+func TestPLMInfo(t *testing.T) {
+	tests := []struct {
+		desc    string
+		faults  []plmtest.Fault
+		want    *Info
+		wantErr error
+	}{
+		{"happy path", nil, &Info{insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42}, nil},
+		{"garbage", []plmtest.Fault{plmtest.FaultGarbage}, &Info{insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42}, nil},
+		{"dropped ack", []plmtest.Fault{plmtest.FaultDropAck}, nil, ErrAckTimeout},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+			emulator.Fault(byte(CmdGetInfo), test.faults...)
+
+			got, err := plm.Info()
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			} else if err == nil && *got != *test.want {
+				t.Errorf("want info %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPLMConfig(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+
+	want := Config(0xc0)
+	err := plm.SetConfig(&want)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if emulator.Config() != byte(want) {
+		t.Errorf("want emulator config %v got %v", want, Config(emulator.Config()))
+	}
+
+	got, err := plm.Config()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if *got != want {
+		t.Errorf("want config %v got %v", want, got)
+	}
+}
+
+func TestPLMRetry(t *testing.T) {
+	tests := []struct {
+		desc    string
+		faults  []plmtest.Fault
+		wantErr error
+	}{
+		{"first try", nil, nil},
+		{"second try", []plmtest.Fault{plmtest.FaultNak}, nil},
+		{"exceeded", []plmtest.Fault{plmtest.FaultNak, plmtest.FaultNak}, ErrRetryCountExceeded},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+			emulator.Fault(byte(CmdCancelAllLink), test.faults...)
+
+			_, err := plm.retry(context.Background(), &Packet{Command: CmdCancelAllLink}, 2)
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPLMLinks(t *testing.T) {
+	want := []*insteon.LinkRecord{
+		insteon.ControllerLink(1, insteon.Address{4, 5, 6}),
+		insteon.ResponderLink(1, insteon.Address{4, 5, 6}),
+	}
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+	emulator.AddLinks(want...)
+
+	got, err := plm.Links()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(want, got) {
+		t.Errorf("want links %v got %v", want, got)
+	}
+}
+
+func TestPLMSend(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+	emulator.OnSend = func(msg *insteon.Message) {
+		emulator.Receive(&insteon.Message{Src: msg.Dst, Dst: msg.Src, Flags: insteon.StandardDirectAck, Command: msg.Command})
+	}
+
+	conn, err := plm.Connect(insteon.Address{4, 5, 6}, insteon.ConnectionTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ack, err := conn.Send(&insteon.Message{Command: insteon.CmdLightOn})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !ack.Ack() || ack.Command[1] != insteon.CmdLightOn[1] {
+		t.Errorf("want %v ack got %v", insteon.CmdLightOn, ack)
+	}
+}
+
+func TestPLMConnections(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+	emulator.OnSend = func(msg *insteon.Message) {
+		emulator.Receive(&insteon.Message{Src: msg.Dst, Dst: msg.Src, Flags: insteon.StandardDirectAck, Command: msg.Command})
+	}
+	monitor := plm.Monitor()
+
+	addresses := []insteon.Address{{4, 5, 6}, {7, 8, 9}, {10, 11, 12}}
+	errCh := make(chan error, len(addresses))
+	for _, addr := range addresses {
+		conn, err := plm.Connect(addr, insteon.ConnectionTimeout(time.Second))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		go func(conn insteon.Connection) {
+			for i := 0; i < 5; i++ {
+				ack, err := conn.Send(&insteon.Message{Command: insteon.CmdLightOn})
+				if err == nil && ack.Src != conn.Address() {
+					err = fmt.Errorf("want ack from %v got %v", conn.Address(), ack)
+				}
+
+				if err != nil {
+					errCh <- err
+					return
+				}
+			}
+			errCh <- nil
+		}(conn)
+	}
+
+	for range addresses {
+		if err := <-errCh; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	count := 0
+	for done := false; !done; {
+		select {
+		case <-monitor:
+			count++
+		case <-time.After(10 * time.Millisecond):
+			done = true
+		}
+	}
+
+	if count != 5*len(addresses) {
+		t.Errorf("want %d monitored messages got %d", 5*len(addresses), count)
+	}
+	plm.StopMonitor(monitor)
+}
+
+func TestPLMSetDeviceCategory(t *testing.T) {
+	tests := []struct {
+		desc     string
+		devCat   insteon.DevCat
+		firmware int
+		want     *Info
+		wantErr  error
+	}{
+		{"happy path", insteon.DevCat{0x01, 0x20}, 0x45, &Info{insteon.Address{1, 2, 3}, insteon.DevCat{0x01, 0x20}, 0x45}, nil},
+		{"unset category", insteon.DevCat{}, 0x45, &Info{insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42}, ErrInvalidDevCat},
+		{"firmware too large", insteon.DevCat{0x01, 0x20}, 0x100, &Info{insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42}, &RangeError{"firmware", 0x100}},
+		{"negative firmware", insteon.DevCat{0x01, 0x20}, -1, &Info{insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42}, &RangeError{"firmware", -1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, _ := newTestPLM(t)
+			defer plm.Close()
+
+			err := plm.SetDeviceCategory(test.devCat, test.firmware)
+			if !reflect.DeepEqual(test.wantErr, err) {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			}
+
+			got, err := plm.Info()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPLMHostCommands(t *testing.T) {
+	tests := []struct {
+		desc    string
+		command Command
+		run     func(context.Context, *PLM) error
+	}{
+		{"RF Sleep", CmdRfSleep, func(ctx context.Context, plm *PLM) error { return plm.RFSleepContext(ctx) }},
+		{"Set Host Category", CmdSetHostCategory, func(ctx context.Context, plm *PLM) error {
+			return plm.SetDeviceCategoryContext(ctx, insteon.DevCat{0x01, 0x20}, 0x45)
+		}},
+		{"Set ACK Byte", CmdSetAckMsg, func(ctx context.Context, plm *PLM) error { return plm.SetAckByteContext(ctx, 0x42) }},
+		{"Set NAK Byte", CmdSetNakMsgByte, func(ctx context.Context, plm *PLM) error { return plm.SetNakByteContext(ctx, 0x42) }},
+		{"Set NAK Bytes", CmdSetNameMsgTwoBytes, func(ctx context.Context, plm *PLM) error { return plm.SetNakBytesContext(ctx, 0x19, 0x42) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+
+			if err := test.run(context.Background(), plm); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			emulator.Fault(byte(test.command), plmtest.FaultNak)
+			want := &NakError{Command: test.command}
+			if err := test.run(context.Background(), plm); !reflect.DeepEqual(want, err) {
+				t.Errorf("want error %v got %v", want, err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := test.run(ctx, plm); err != context.Canceled {
+				t.Errorf("want error %v got %v", context.Canceled, err)
+			}
+		})
+	}
+}
+
+func TestPLMByteArgs(t *testing.T) {
+	tests := []struct {
+		desc string
+		run  func(*PLM) error
+		want error
+	}{
+		{"ack byte", func(plm *PLM) error { return plm.SetAckByte(0x100) }, &RangeError{"cmd2", 0x100}},
+		{"nak byte", func(plm *PLM) error { return plm.SetNakByte(-1) }, &RangeError{"cmd2", -1}},
+		{"nak cmd1", func(plm *PLM) error { return plm.SetNakBytes(0x100, 0x00) }, &RangeError{"cmd1", 0x100}},
+		{"nak cmd2", func(plm *PLM) error { return plm.SetNakBytes(0x00, 0x100) }, &RangeError{"cmd2", 0x100}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, _ := newTestPLM(t)
+			defer plm.Close()
+
+			if err := test.run(plm); !reflect.DeepEqual(test.want, err) {
+				t.Errorf("want error %v got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestPLMLED(t *testing.T) {
+	tests := []struct {
+		desc    string
+		config  byte
+		on      bool
+		want    bool
+		wantErr error
+	}{
+		{"automatic", 0x00, true, false, ErrLEDAutomatic},
+		{"on", 0x20, true, true, nil},
+		{"off", 0x20, false, false, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+			emulator.SetConfig(test.config)
+
+			var err error
+			if test.on {
+				err = plm.LEDOn()
+			} else {
+				err = plm.LEDOff()
+			}
+
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			} else if emulator.LED() != test.want {
+				t.Errorf("want LED %v got %v", test.want, emulator.LED())
+			}
+		})
+	}
+}
+
+func TestPLMReset(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+	emulator.AddLinks(insteon.ControllerLink(1, insteon.Address{4, 5, 6}))
+
+	if err := plm.Reset(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if links := emulator.Links(); len(links) != 0 {
+		t.Errorf("want empty link database got %v", links)
+	}
+}
+
+func TestPLMContext(t *testing.T) {
+	tests := []struct {
+		desc   string
+		cmd    Command
+		faults int
+		cancel bool
+		call   func(context.Context, *PLM) error
+		want   error
+	}{
+		{"info deadline", CmdGetInfo, 1, false, func(ctx context.Context, plm *PLM) error { _, err := plm.InfoContext(ctx); return err }, context.DeadlineExceeded},
+		{"info cancel", CmdGetInfo, 1, true, func(ctx context.Context, plm *PLM) error { _, err := plm.InfoContext(ctx); return err }, context.Canceled},
+		{"links", CmdGetNextAllLink, 1, false, func(ctx context.Context, plm *PLM) error { _, err := plm.LinksContext(ctx); return err }, context.DeadlineExceeded},
+		{"linking mode", CmdStartAllLink, 3, false, func(ctx context.Context, plm *PLM) error { return plm.EnterLinkingModeContext(ctx, 1) }, context.DeadlineExceeded},
+		{"config", CmdGetConfig, 1, true, func(ctx context.Context, plm *PLM) error { _, err := plm.ConfigContext(ctx); return err }, context.Canceled},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+			emulator.AddLinks(insteon.ControllerLink(1, insteon.Address{4, 5, 6}))
+			for i := 0; i < test.faults; i++ {
+				emulator.Fault(byte(test.cmd), plmtest.FaultDropAck)
+			}
+
+			// the context must end well before the PLM's own timeout
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			if test.cancel {
+				ctx, cancel = context.WithCancel(context.Background())
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					cancel()
+				}()
+			}
+			defer cancel()
+
+			if err := test.call(ctx, plm); err != test.want {
+				t.Errorf("want error %v got %v", test.want, err)
+			}
+
+			// the lock must have been released
+			if _, err := plm.Info(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPLMTrackState(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+
+	tracker, err := plm.TrackState()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tracker.Close()
+	ch := tracker.Subscribe()
+
+	src := insteon.Address{4, 5, 6}
+	emulator.OnSend = func(msg *insteon.Message) {
+		emulator.Receive(&insteon.Message{Src: msg.Dst, Dst: msg.Src, Flags: insteon.StandardDirectAck, Command: msg.Command})
+	}
+
+	conn, err := plm.Connect(src, insteon.ConnectionTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := conn.Send(&insteon.Message{Command: insteon.CmdLightOn.SubCommand(0x80)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case state := <-ch:
+		if state.Address != src || state.Level != 0x80 || state.Source != insteon.SourceAck {
+			t.Errorf("want %v level 128 from ack got %v", src, state)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for state change")
+	}
+}
+
+type testProductDB map[insteon.Address]insteon.DeviceInfo
+
+func (db testProductDB) update(address insteon.Address, cb func(*insteon.DeviceInfo)) {
+	info := db[address]
+	info.Address = address
+	cb(&info)
+	db[address] = info
+}
+
+func (db testProductDB) UpdateDevCat(address insteon.Address, devCat insteon.DevCat) {
+	db.update(address, func(info *insteon.DeviceInfo) { info.DevCat = devCat })
+}
+
+func (db testProductDB) UpdateEngineVersion(address insteon.Address, engineVersion insteon.EngineVersion) {
+	db.update(address, func(info *insteon.DeviceInfo) {
+		info.EngineVersion = engineVersion
+		info.EngineVersionKnown = true
+	})
+}
+
+func (db testProductDB) UpdateFirmwareVersion(address insteon.Address, firmwareVersion insteon.FirmwareVersion) {
+	db.update(address, func(info *insteon.DeviceInfo) { info.FirmwareVersion = firmwareVersion })
+}
+
+func (db testProductDB) Update(address insteon.Address, info insteon.DeviceInfo) {
+	db.update(address, func(existing *insteon.DeviceInfo) { *existing = info })
+}
+
+func (db testProductDB) Find(address insteon.Address) (info insteon.DeviceInfo, found bool) {
+	info, found = db[address]
+	return info, found
+}
+
+func TestPLMProductDB(t *testing.T) {
+	dst := insteon.Address{4, 5, 6}
+	dimmer := insteon.DeviceInfo{Address: dst, DevCat: insteon.DevCat{1, 32}, FirmwareVersion: 65, EngineVersion: insteon.VerI2, EngineVersionKnown: true}
+	i1 := insteon.DeviceInfo{Address: dst, DevCat: insteon.DevCat{1, 32}, FirmwareVersion: 65, EngineVersion: insteon.VerI1, EngineVersionKnown: true}
+	tests := []struct {
+		desc     string
+		cached   testProductDB
+		unlinked bool
+		wantSent int
+		wantErr  error
+		wantInfo insteon.DeviceInfo
+	}{
+		{"cached", testProductDB{dst: dimmer}, false, 0, nil, dimmer},
+		{"cached I1", testProductDB{dst: i1}, false, 0, nil, i1},
+		{"not cached", testProductDB{}, false, 2, nil, dimmer},
+		{"engine version unknown", testProductDB{dst: {Address: dst, DevCat: insteon.DevCat{1, 32}}}, false, 2, nil, dimmer},
+		{"unlinked", testProductDB{}, true, 1, insteon.ErrNotLinked, insteon.DeviceInfo{Address: dst, EngineVersion: insteon.VerI2Cs, EngineVersionKnown: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+			plm.productDB = test.cached
+
+			sent := make(chan *insteon.Message, 10)
+			emulator.OnSend = func(msg *insteon.Message) {
+				sent <- msg
+				ack := &insteon.Message{Src: msg.Dst, Dst: msg.Src, Flags: insteon.StandardDirectAck, Command: msg.Command}
+				if msg.Command[1] == insteon.CmdGetEngineVersion[1] {
+					ack.Command[2] = byte(dimmer.EngineVersion)
+					if test.unlinked {
+						ack.Flags = insteon.StandardDirectNak
+						ack.Command[2] = 0xff
+					}
+				}
+				emulator.Receive(ack)
+
+				if msg.Command[1] == insteon.CmdIDRequest[1] {
+					emulator.Receive(&insteon.Message{Src: msg.Dst, Dst: insteon.Address{1, 32, 65}, Flags: insteon.StandardBroadcast, Command: insteon.CmdSetButtonPressedResponder})
+				}
+			}
+
+			device, err := plm.Open(dst)
+			if err != test.wantErr {
+				t.Fatalf("want error %v got %v", test.wantErr, err)
+			} else if device == nil {
+				t.Fatalf("expected a device")
+			}
+
+			if len(sent) != test.wantSent {
+				t.Errorf("want %d messages sent got %d", test.wantSent, len(sent))
+			}
+
+			if got := test.cached[dst]; got != test.wantInfo {
+				t.Errorf("want %+v got %+v", test.wantInfo, got)
+			}
+		})
+	}
+}
+
+func TestPLMClose(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	if err := plm.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := emulator.Write([]byte{0x02, 0x60}); err != plmtest.ErrClosed {
+		t.Errorf("want error %v got %v", plmtest.ErrClosed, err)
+	}
+
+	// closing again has no effect
+	if err := plm.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,607 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plmtest provides a software emulation of an Insteon PowerLinc
+// Modem.  The emulator speaks the serial IM protocol over an io.ReadWriter
+// so it can be handed directly to plm.NewPort, allowing everything above
+// the port to be tested without hardware.
+//
+// The emulator intentionally does not import the plm package so that it can
+// be used by the plm package's own tests.  All IM commands are referenced
+// by their byte values.
+package plmtest
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/abates/insteon"
+)
+
+const (
+	ack = 0x06
+	nak = 0x15
+	stx = 0x02
+)
+
+// IM command bytes understood by the emulator
+const (
+	cmdStdMsgReceived      = 0x50
+	cmdExtMsgReceived      = 0x51
+	cmdX10MsgReceived      = 0x52
+	cmdAllLinkComplete     = 0x53
+	cmdAllLinkCleanupFail  = 0x56
+	cmdUserResetDetected   = 0x55
+	cmdAllLinkRecordResp   = 0x57
+	cmdAllLinkCleanupStat  = 0x58
+	cmdGetInfo             = 0x60
+	cmdSendAllLink         = 0x61
+	cmdSendInsteonMsg      = 0x62
+	cmdSendX10             = 0x63
+	cmdStartAllLink        = 0x64
+	cmdSetHostCategory     = 0x66
+	cmdReset               = 0x67
+	cmdGetFirstAllLink     = 0x69
+	cmdGetNextAllLink      = 0x6a
+	cmdSetConfig           = 0x6b
+	cmdLedOn               = 0x6d
+	cmdLedOff              = 0x6e
+	cmdManageAllLinkRecord = 0x6f
+	cmdGetConfig           = 0x73
+)
+
+// Manage All-Link Record control codes
+const (
+	linkCmdFindFirst    = 0x00
+	linkCmdFindNext     = 0x01
+	linkCmdModFirst     = 0x20
+	linkCmdModFirstCtrl = 0x40
+	linkCmdModFirstResp = 0x41
+	linkCmdDeleteFirst  = 0x80
+)
+
+// hostLens is the number of bytes following the command byte that
+// the host sends for each IM command.  Extended insteon messages (0x62)
+// have an additional 14 bytes that are accounted for separately
+var hostLens = map[byte]int{
+	0x60: 0,
+	0x61: 3,
+	0x62: 6,
+	0x63: 2,
+	0x64: 2,
+	0x65: 0,
+	0x66: 3,
+	0x67: 0,
+	0x68: 1,
+	0x69: 0,
+	0x6a: 0,
+	0x6b: 1,
+	0x6c: 0,
+	0x6d: 0,
+	0x6e: 0,
+	0x6f: 9,
+	0x70: 1,
+	0x71: 2,
+	0x72: 0,
+	0x73: 0,
+}
+
+// ErrClosed is returned by Write once the emulator has been closed
+var ErrClosed = errors.New("emulator is closed")
+
+// Fault is a scripted failure that the emulator applies to the next
+// occurrence of a given IM command
+type Fault int
+
+const (
+	// FaultNone processes the command normally
+	FaultNone Fault = iota
+
+	// FaultNak responds to the command with a NAK instead of an ACK and
+	// does not execute the command
+	FaultNak
+
+	// FaultDropAck silently discards the command.  No response is sent
+	FaultDropAck
+
+	// FaultGarbage prepends bytes that are not part of any IM packet to the
+	// normal response
+	FaultGarbage
+
+	// FaultBusy responds to the command with a lone NAK, as the modem does
+	// when it is too busy to accept a command.  The command is not executed
+	FaultBusy
+)
+
+// Garbage is the byte sequence emitted ahead of a response when
+// FaultGarbage is applied
+var Garbage = []byte{0xde, 0xad, 0xbe, 0xef}
+
+// PLM emulates a PowerLinc Modem.  The zero value is not usable, New
+// must be called to get a properly initialized emulator
+type PLM struct {
+	// Address is the Insteon address reported by Get IM Info
+	Address insteon.Address
+
+	// DevCat is the device category reported by Get IM Info
+	DevCat insteon.DevCat
+
+	// Firmware is the firmware version reported by Get IM Info
+	Firmware byte
+
+	// OnSend, if set, is called with every Insteon message the host
+	// transmits (after the echo has been queued).  Tests can use this
+	// to simulate devices responding by calling Receive
+	OnSend func(*insteon.Message)
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	closed  bool
+	in      []byte
+	out     []byte
+	config  byte
+	links   []*insteon.LinkRecord
+	cursor  int
+	faults  map[byte][]Fault
+	x10     [][2]byte
+	offline map[insteon.Address]bool
+	pending []pendingLink
+	led     bool
+}
+
+type pendingLink struct {
+	linkCode byte
+	address  insteon.Address
+	devCat   insteon.DevCat
+	firmware byte
+}
+
+// New returns an emulated PLM with the given identity and an empty
+// all-link database
+func New(address insteon.Address, devCat insteon.DevCat, firmware byte) *PLM {
+	p := &PLM{
+		Address:  address,
+		DevCat:   devCat,
+		Firmware: firmware,
+		faults:   make(map[byte][]Fault),
+		offline:  make(map[insteon.Address]bool),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Read returns bytes the emulated modem has sent to the host.  Read blocks
+// until data is available or the emulator is closed, in which case io.EOF
+// is returned
+func (p *PLM) Read(buf []byte) (n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.out) == 0 && !p.closed {
+		p.cond.Wait()
+	}
+
+	if len(p.out) == 0 {
+		return 0, io.EOF
+	}
+	n = copy(buf, p.out)
+	p.out = p.out[n:]
+	return n, nil
+}
+
+// Write accepts bytes from the host.  Complete IM commands are processed
+// immediately and their responses are queued to be read.  Partial commands
+// are buffered until the remaining bytes are written
+func (p *PLM) Write(buf []byte) (int, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return 0, ErrClosed
+	}
+	p.in = append(p.in, buf...)
+	sent := p.process()
+	p.mu.Unlock()
+
+	if p.OnSend != nil {
+		for _, msg := range sent {
+			p.OnSend(msg)
+		}
+	}
+	return len(buf), nil
+}
+
+// Close shuts down the emulator.  Pending reads return io.EOF once the
+// output buffer has been drained
+func (p *PLM) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	return nil
+}
+
+// Config returns the current IM configuration byte
+func (p *PLM) Config() byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config
+}
+
+// SetConfig sets the IM configuration byte
+func (p *PLM) SetConfig(config byte) {
+	p.mu.Lock()
+	p.config = config
+	p.mu.Unlock()
+}
+
+// LED returns whether the host has turned the modem's LED on
+func (p *PLM) LED() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.led
+}
+
+// Links returns a copy of the emulator's all-link database
+func (p *PLM) Links() []*insteon.LinkRecord {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	links := make([]*insteon.LinkRecord, len(p.links))
+	for i, link := range p.links {
+		l := *link
+		links[i] = &l
+	}
+	return links
+}
+
+// AddLinks appends the given records to the emulator's all-link database
+func (p *PLM) AddLinks(links ...*insteon.LinkRecord) {
+	p.mu.Lock()
+	for _, link := range links {
+		l := *link
+		p.links = append(p.links, &l)
+	}
+	p.mu.Unlock()
+}
+
+// Fault queues faults for the given IM command.  Each time the command is
+// received, the next fault in the queue is removed and applied
+func (p *PLM) Fault(cmd byte, faults ...Fault) {
+	p.mu.Lock()
+	p.faults[cmd] = append(p.faults[cmd], faults...)
+	p.mu.Unlock()
+}
+
+// Inject queues raw bytes to be read by the host
+func (p *PLM) Inject(buf []byte) {
+	p.mu.Lock()
+	p.write(buf...)
+	p.mu.Unlock()
+}
+
+// Receive simulates the modem receiving an Insteon message from the
+// network.  The message is delivered to the host as a Standard (0x50)
+// or Extended (0x51) Message Received packet
+func (p *PLM) Receive(msg *insteon.Message) {
+	buf, _ := msg.MarshalBinary()
+	cmd := byte(cmdStdMsgReceived)
+	if msg.Flags.Extended() {
+		cmd = cmdExtMsgReceived
+	}
+	p.Inject(append([]byte{stx, cmd}, buf...))
+}
+
+// UserReset simulates holding the set button while the modem is powered
+// up.  The all-link database and configuration are erased and the host
+// is sent a User Reset Detected packet
+func (p *PLM) UserReset() {
+	p.mu.Lock()
+	p.config = 0
+	p.links = nil
+	p.write(stx, cmdUserResetDetected)
+	p.mu.Unlock()
+}
+
+// QueueLink simulates a device that will have its set button pressed the
+// next time the host starts all-linking.  The link code is the value reported
+// in the All-Link Complete packet (0x00 responder, 0x01 controller and 0xff
+// deleted) and the emulator's database is updated to match
+func (p *PLM) QueueLink(linkCode byte, address insteon.Address, devCat insteon.DevCat, firmware byte) {
+	p.mu.Lock()
+	p.pending = append(p.pending, pendingLink{linkCode, address, devCat, firmware})
+	p.mu.Unlock()
+}
+
+// Offline marks the given devices as unreachable.  Offline devices
+// do not acknowledge all-link cleanup messages and the emulator
+// reports them in a Cleanup Failure Report instead
+func (p *PLM) Offline(addresses ...insteon.Address) {
+	p.mu.Lock()
+	for _, address := range addresses {
+		p.offline[address] = true
+	}
+	p.mu.Unlock()
+}
+
+// ReceiveX10 simulates the modem receiving an X10 transmission.  The
+// raw byte and flag are delivered to the host in an X10 Message
+// Received (0x52) packet
+func (p *PLM) ReceiveX10(raw, flag byte) {
+	p.Inject([]byte{stx, cmdX10MsgReceived, raw, flag})
+}
+
+// SentX10 returns the raw byte and flag pairs of every X10 message
+// the host has sent
+func (p *PLM) SentX10() [][2]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][2]byte{}, p.x10...)
+}
+
+func (p *PLM) write(buf ...byte) {
+	p.out = append(p.out, buf...)
+	p.cond.Broadcast()
+}
+
+func (p *PLM) nextFault(cmd byte) Fault {
+	if faults := p.faults[cmd]; len(faults) > 0 {
+		p.faults[cmd] = faults[1:]
+		return faults[0]
+	}
+	return FaultNone
+}
+
+// process consumes all complete commands in the input buffer.  Any
+// Insteon messages sent by the host are returned so that the OnSend
+// callback can be called once the mutex has been released
+func (p *PLM) process() (sent []*insteon.Message) {
+	for {
+		// synchronize on the start of text byte
+		for len(p.in) > 0 && p.in[0] != stx {
+			p.in = p.in[1:]
+		}
+
+		if len(p.in) < 2 {
+			return
+		}
+
+		cmd := p.in[1]
+		length, found := hostLens[cmd]
+		if !found {
+			// the modem responds to unknown commands with a lone NAK
+			p.in = p.in[2:]
+			p.write(nak)
+			continue
+		}
+
+		if cmd == cmdSendInsteonMsg {
+			if len(p.in) < 2+length {
+				return
+			}
+			if insteon.Flags(p.in[5]).Extended() {
+				length += 14
+			}
+		}
+
+		if len(p.in) < 2+length {
+			return
+		}
+
+		payload := make([]byte, length)
+		copy(payload, p.in[2:2+length])
+		p.in = p.in[2+length:]
+
+		if msg := p.handle(cmd, payload); msg != nil {
+			sent = append(sent, msg)
+		}
+	}
+}
+
+func (p *PLM) handle(cmd byte, payload []byte) (sent *insteon.Message) {
+	fault := p.nextFault(cmd)
+	switch fault {
+	case FaultDropAck:
+		return nil
+	case FaultGarbage:
+		p.write(Garbage...)
+	case FaultNak:
+		p.respond(cmd, payload, nak)
+		return nil
+	case FaultBusy:
+		p.write(nak)
+		return nil
+	}
+
+	switch cmd {
+	case cmdGetInfo:
+		p.write(stx, cmd)
+		p.write(p.Address[:]...)
+		p.write(p.DevCat[:]...)
+		p.write(p.Firmware, ack)
+	case cmdGetConfig:
+		p.write(stx, cmd, p.config, 0x00, 0x00, ack)
+	case cmdSetConfig:
+		p.config = payload[0]
+		p.respond(cmd, payload, ack)
+	case cmdSetHostCategory:
+		p.DevCat = insteon.DevCat{payload[0], payload[1]}
+		p.Firmware = payload[2]
+		p.respond(cmd, payload, ack)
+	case cmdLedOn, cmdLedOff:
+		// the host can only control the LED when automatic
+		// LED operation has been disabled
+		if p.config&0x20 == 0 {
+			p.respond(cmd, payload, nak)
+		} else {
+			p.led = cmd == cmdLedOn
+			p.respond(cmd, payload, ack)
+		}
+	case cmdReset:
+		p.config = 0
+		p.links = nil
+		p.respond(cmd, payload, ack)
+	case cmdGetFirstAllLink:
+		p.cursor = 0
+		p.nextLink(cmd)
+	case cmdGetNextAllLink:
+		p.nextLink(cmd)
+	case cmdManageAllLinkRecord:
+		if p.manage(payload) {
+			p.respond(cmd, payload, ack)
+		} else {
+			p.respond(cmd, payload, nak)
+		}
+	case cmdSendAllLink:
+		p.respond(cmd, payload, ack)
+		p.cleanup(insteon.Group(payload[0]), payload[1])
+	case cmdStartAllLink:
+		p.respond(cmd, payload, ack)
+		p.allLink(insteon.Group(payload[1]))
+	case cmdSendX10:
+		p.x10 = append(p.x10, [2]byte{payload[0], payload[1]})
+		p.respond(cmd, payload, ack)
+	case cmdSendInsteonMsg:
+		p.respond(cmd, payload, ack)
+		sent = &insteon.Message{}
+		buf := append(append([]byte{}, p.Address[:]...), payload...)
+		if err := sent.UnmarshalBinary(buf); err != nil {
+			sent = nil
+		}
+	default:
+		p.respond(cmd, payload, ack)
+	}
+	return sent
+}
+
+// allLink completes linking with the next queued device, if any
+func (p *PLM) allLink(group insteon.Group) {
+	if len(p.pending) == 0 {
+		return
+	}
+	pl := p.pending[0]
+	p.pending = p.pending[1:]
+
+	switch pl.linkCode {
+	case 0x00:
+		p.links = append(p.links, &insteon.LinkRecord{Flags: 0xa2, Group: group, Address: pl.address, Data: [3]byte{pl.devCat[0], pl.devCat[1], pl.firmware}})
+	case 0x01:
+		p.links = append(p.links, &insteon.LinkRecord{Flags: 0xe2, Group: group, Address: pl.address, Data: [3]byte{pl.devCat[0], pl.devCat[1], pl.firmware}})
+	case 0xff:
+		if i := p.find(0, false, false, group, pl.address); i >= 0 {
+			p.links = append(p.links[:i], p.links[i+1:]...)
+		}
+	}
+	p.write(stx, cmdAllLinkComplete, pl.linkCode, byte(group))
+	p.write(pl.address[:]...)
+	p.write(pl.devCat[:]...)
+	p.write(pl.firmware)
+}
+
+// cleanup simulates the modem sending an all-link cleanup to every
+// responder of the group.  Online responders acknowledge the cleanup
+// and offline responders are reported as failures
+func (p *PLM) cleanup(group insteon.Group, cmd1 byte) {
+	seen := make(map[insteon.Address]bool)
+	for _, link := range p.links {
+		if link.Group != group || !link.Flags.Controller() || seen[link.Address] {
+			continue
+		}
+		seen[link.Address] = true
+
+		if p.offline[link.Address] {
+			p.write(stx, cmdAllLinkCleanupFail, 0x01, byte(group))
+			p.write(link.Address[:]...)
+		} else {
+			msg := &insteon.Message{
+				Src:     link.Address,
+				Dst:     p.Address,
+				Flags:   insteon.Flag(insteon.MsgTypeAllLinkCleanupAck, false, 3, 3),
+				Command: insteon.Command{0x00, cmd1, byte(group)},
+			}
+			buf, _ := msg.MarshalBinary()
+			p.write(stx, cmdStdMsgReceived)
+			p.write(buf...)
+		}
+	}
+	p.write(stx, cmdAllLinkCleanupStat, ack)
+}
+
+func (p *PLM) respond(cmd byte, payload []byte, ackByte byte) {
+	p.write(stx, cmd)
+	p.write(payload...)
+	p.write(ackByte)
+}
+
+func (p *PLM) nextLink(cmd byte) {
+	if p.cursor < len(p.links) {
+		p.write(stx, cmd, ack)
+		buf, _ := p.links[p.cursor].MarshalBinary()
+		p.write(stx, cmdAllLinkRecordResp)
+		p.write(buf...)
+		p.cursor++
+	} else {
+		p.write(stx, cmd, nak)
+	}
+}
+
+func (p *PLM) find(start int, controller bool, matchType bool, group insteon.Group, address insteon.Address) int {
+	for i := start; i < len(p.links); i++ {
+		link := p.links[i]
+		if link.Group == group && link.Address == address {
+			if !matchType || link.Flags.Controller() == controller {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// manage implements the Manage All-Link Record (0x6f) command.  The return
+// value indicates whether the modem should ACK (true) or NAK (false)
+func (p *PLM) manage(payload []byte) bool {
+	link := &insteon.LinkRecord{}
+	if err := link.UnmarshalBinary(payload[1:]); err != nil {
+		return false
+	}
+
+	switch payload[0] {
+	case linkCmdFindFirst:
+		p.cursor = p.find(0, false, false, link.Group, link.Address) + 1
+		return p.cursor > 0
+	case linkCmdFindNext:
+		i := p.find(p.cursor, false, false, link.Group, link.Address)
+		p.cursor = i + 1
+		return i >= 0
+	case linkCmdModFirst, linkCmdModFirstCtrl, linkCmdModFirstResp:
+		if payload[0] == linkCmdModFirstCtrl {
+			link.Flags |= insteon.AvailableController
+		} else if payload[0] == linkCmdModFirstResp {
+			link.Flags &^= insteon.AvailableController
+		}
+		// records written by the modem are always marked in use
+		link.Flags |= insteon.UnavailableResponder | 0x02
+
+		if i := p.find(0, link.Flags.Controller(), true, link.Group, link.Address); i >= 0 {
+			p.links[i] = link
+		} else {
+			p.links = append(p.links, link)
+		}
+		return true
+	case linkCmdDeleteFirst:
+		if i := p.find(0, false, false, link.Group, link.Address); i >= 0 {
+			p.links = append(p.links[0:i], p.links[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plmtest
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/abates/insteon"
+)
+
+var (
+	testAddr  = insteon.Address{0x01, 0x02, 0x03}
+	testLink1 = &insteon.LinkRecord{Flags: 0xe2, Group: 1, Address: insteon.Address{4, 5, 6}, Data: [3]byte{7, 8, 9}}
+	testLink2 = &insteon.LinkRecord{Flags: 0xa2, Group: 1, Address: insteon.Address{4, 5, 6}, Data: [3]byte{10, 11, 12}}
+)
+
+func readAll(p *PLM) []byte {
+	p.Close()
+	buf := bytes.NewBuffer(nil)
+	io.Copy(buf, p)
+	return buf.Bytes()
+}
+
+func TestPLMCommands(t *testing.T) {
+	tests := []struct {
+		desc   string
+		links  []*insteon.LinkRecord
+		faults map[byte][]Fault
+		input  []byte
+		want   []byte
+	}{
+		{"Get Info", nil, nil, []byte{0x02, 0x60}, []byte{0x02, 0x60, 0x01, 0x02, 0x03, 0x03, 0x15, 0x9b, 0x06}},
+		{"Set/Get Config", nil, nil, []byte{0x02, 0x6b, 0x40, 0x02, 0x73}, []byte{0x02, 0x6b, 0x40, 0x06, 0x02, 0x73, 0x40, 0x00, 0x00, 0x06}},
+		{"Split write", nil, nil, []byte{0x02}, []byte{}},
+		{"Garbage before command", nil, nil, []byte{0xff, 0x00, 0x02, 0x65}, []byte{0x02, 0x65, 0x06}},
+		{"Unknown command", nil, nil, []byte{0x02, 0x99}, []byte{0x15}},
+		{"Get First (empty)", nil, nil, []byte{0x02, 0x69}, []byte{0x02, 0x69, 0x15}},
+		{"Get First/Next", []*insteon.LinkRecord{testLink1}, nil, []byte{0x02, 0x69, 0x02, 0x6a}, []byte{0x02, 0x69, 0x06, 0x02, 0x57, 0xe2, 0x01, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x02, 0x6a, 0x15}},
+		{"Send Standard", nil, nil, []byte{0x02, 0x62, 0x04, 0x05, 0x06, 0x0f, 0x11, 0xff}, []byte{0x02, 0x62, 0x04, 0x05, 0x06, 0x0f, 0x11, 0xff, 0x06}},
+		{"Send Extended", nil, nil, append([]byte{0x02, 0x62, 0x04, 0x05, 0x06, 0x1f, 0x2e, 0x00}, make([]byte, 14)...), append(append([]byte{0x02, 0x62, 0x04, 0x05, 0x06, 0x1f, 0x2e, 0x00}, make([]byte, 14)...), 0x06)},
+		{"Fault NAK", nil, map[byte][]Fault{0x65: {FaultNak}}, []byte{0x02, 0x65, 0x02, 0x65}, []byte{0x02, 0x65, 0x15, 0x02, 0x65, 0x06}},
+		{"Fault Drop", nil, map[byte][]Fault{0x65: {FaultDropAck}}, []byte{0x02, 0x65, 0x02, 0x65}, []byte{0x02, 0x65, 0x06}},
+		{"Fault Garbage", nil, map[byte][]Fault{0x65: {FaultGarbage}}, []byte{0x02, 0x65}, append(append([]byte{}, Garbage...), 0x02, 0x65, 0x06)},
+		{"Fault Busy", nil, map[byte][]Fault{0x65: {FaultBusy}}, []byte{0x02, 0x65, 0x02, 0x65}, []byte{0x15, 0x02, 0x65, 0x06}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := New(testAddr, insteon.DevCat{0x03, 0x15}, 0x9b)
+			p.AddLinks(test.links...)
+			for cmd, faults := range test.faults {
+				p.Fault(cmd, faults...)
+			}
+			p.Write(test.input)
+			got := readAll(p)
+			if !bytes.Equal(test.want, got) {
+				t.Errorf("want % x got % x", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPLMManageRecord(t *testing.T) {
+	tests := []struct {
+		desc      string
+		links     []*insteon.LinkRecord
+		input     []byte
+		wantAck   byte
+		wantLinks []*insteon.LinkRecord
+	}{
+		{"Find First (not found)", nil, []byte{0x00, 0x00, 0x01, 0x04, 0x05, 0x06, 0, 0, 0}, 0x15, []*insteon.LinkRecord{}},
+		{"Find First", []*insteon.LinkRecord{testLink1}, []byte{0x00, 0x00, 0x01, 0x04, 0x05, 0x06, 0, 0, 0}, 0x06, []*insteon.LinkRecord{testLink1}},
+		{"Add Controller", nil, []byte{0x40, 0xe2, 0x01, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}, 0x06, []*insteon.LinkRecord{testLink1}},
+		{"Modify Responder", []*insteon.LinkRecord{testLink1, {Flags: 0xa2, Group: 1, Address: insteon.Address{4, 5, 6}}}, []byte{0x41, 0xa2, 0x01, 0x04, 0x05, 0x06, 0x0a, 0x0b, 0x0c}, 0x06, []*insteon.LinkRecord{testLink1, testLink2}},
+		{"Delete First", []*insteon.LinkRecord{testLink1, testLink2}, []byte{0x80, 0x00, 0x01, 0x04, 0x05, 0x06, 0, 0, 0}, 0x06, []*insteon.LinkRecord{testLink2}},
+		{"Delete (not found)", nil, []byte{0x80, 0x00, 0x01, 0x04, 0x05, 0x06, 0, 0, 0}, 0x15, []*insteon.LinkRecord{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := New(testAddr, insteon.DevCat{}, 0)
+			p.AddLinks(test.links...)
+			p.Write(append([]byte{0x02, 0x6f}, test.input...))
+			want := append(append([]byte{0x02, 0x6f}, test.input...), test.wantAck)
+			got := readAll(p)
+			if !bytes.Equal(want, got) {
+				t.Errorf("want response % x got % x", want, got)
+			}
+
+			if !reflect.DeepEqual(test.wantLinks, p.Links()) {
+				t.Errorf("want links %v got %v", test.wantLinks, p.Links())
+			}
+		})
+	}
+}
+
+func TestPLMReceive(t *testing.T) {
+	msg := &insteon.Message{Src: insteon.Address{4, 5, 6}, Dst: testAddr, Flags: insteon.StandardDirectAck, Command: insteon.CmdLightOn}
+	p := New(testAddr, insteon.DevCat{}, 0)
+	p.Receive(msg)
+	want := []byte{0x02, 0x50, 0x04, 0x05, 0x06, 0x01, 0x02, 0x03, 0x2a, 0x11, 0xff}
+	got := readAll(p)
+	if !bytes.Equal(want, got) {
+		t.Errorf("want % x got % x", want, got)
+	}
+}
+
+func TestPLMOnSend(t *testing.T) {
+	var got *insteon.Message
+	p := New(testAddr, insteon.DevCat{}, 0)
+	p.OnSend = func(msg *insteon.Message) { got = msg }
+	p.Write([]byte{0x02, 0x62, 0x04, 0x05, 0x06, 0x0a, 0x11, 0xff})
+
+	want := &insteon.Message{Src: testAddr, Dst: insteon.Address{4, 5, 6}, Flags: insteon.StandardDirectMessage, Command: insteon.CmdLightOn}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v got %v", want, got)
+	}
+}
+
+func TestPLMX10(t *testing.T) {
+	p := New(testAddr, insteon.DevCat{}, 0)
+	p.Write([]byte{0x02, 0x63, 0x66, 0x00, 0x02, 0x63, 0x62, 0x80})
+	want := [][2]byte{{0x66, 0x00}, {0x62, 0x80}}
+	if got := p.SentX10(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want sent %v got %v", want, got)
+	}
+
+	p.ReceiveX10(0x66, 0x00)
+	wantBuf := []byte{0x02, 0x63, 0x66, 0x00, 0x06, 0x02, 0x63, 0x62, 0x80, 0x06, 0x02, 0x52, 0x66, 0x00}
+	if got := readAll(p); !bytes.Equal(wantBuf, got) {
+		t.Errorf("want % x got % x", wantBuf, got)
+	}
+}
+
+func TestPLMSendAllLink(t *testing.T) {
+	offline := insteon.Address{7, 8, 9}
+	p := New(testAddr, insteon.DevCat{}, 0)
+	p.AddLinks(testLink1, testLink2, &insteon.LinkRecord{Flags: 0xe2, Group: 1, Address: offline})
+	p.Offline(offline)
+	p.Write([]byte{0x02, 0x61, 0x01, 0x11, 0xff})
+	want := []byte{
+		0x02, 0x61, 0x01, 0x11, 0xff, 0x06,
+		0x02, 0x50, 0x04, 0x05, 0x06, 0x01, 0x02, 0x03, 0x6f, 0x11, 0x01,
+		0x02, 0x56, 0x01, 0x01, 0x07, 0x08, 0x09,
+		0x02, 0x58, 0x06,
+	}
+	if got := readAll(p); !bytes.Equal(want, got) {
+		t.Errorf("want % x got % x", want, got)
+	}
+}
+
+func TestPLMUserReset(t *testing.T) {
+	p := New(testAddr, insteon.DevCat{}, 0)
+	p.AddLinks(testLink1)
+	p.SetConfig(0x40)
+	p.UserReset()
+	if len(p.Links()) != 0 || p.Config() != 0 {
+		t.Errorf("want database and config erased got %v %02x", p.Links(), p.Config())
+	}
+
+	want := []byte{0x02, 0x55}
+	if got := readAll(p); !bytes.Equal(want, got) {
+		t.Errorf("want % x got % x", want, got)
+	}
+}
+
+func TestPLMQueueLink(t *testing.T) {
+	p := New(testAddr, insteon.DevCat{}, 0)
+	p.QueueLink(0x01, insteon.Address{4, 5, 6}, insteon.DevCat{0x01, 0x20}, 0x41)
+	p.Write([]byte{0x02, 0x64, 0x03, 0x01})
+	want := []byte{0x02, 0x64, 0x03, 0x01, 0x06, 0x02, 0x53, 0x01, 0x01, 0x04, 0x05, 0x06, 0x01, 0x20, 0x41}
+	if got := readAll(p); !bytes.Equal(want, got) {
+		t.Errorf("want % x got % x", want, got)
+	}
+
+	wantLinks := []*insteon.LinkRecord{{Flags: 0xe2, Group: 1, Address: insteon.Address{4, 5, 6}, Data: [3]byte{0x01, 0x20, 0x41}}}
+	if !reflect.DeepEqual(wantLinks, p.Links()) {
+		t.Errorf("want links %v got %v", wantLinks, p.Links())
+	}
+}
+
+func TestPLMClose(t *testing.T) {
+	p := New(testAddr, insteon.DevCat{}, 0)
+	p.Close()
+	if _, err := p.Write([]byte{0x02, 0x60}); err != ErrClosed {
+		t.Errorf("want error %v got %v", ErrClosed, err)
+	}
+
+	if _, err := p.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("want error %v got %v", io.EOF, err)
+	}
+}
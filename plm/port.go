@@ -54,6 +54,13 @@ func (port *Port) Read() (buf []byte, err error) {
 			break
 		}
 
+		// a lone NAK, sent when the modem is too busy to accept a
+		// command, is the only response without a Start of Text
+		if b == byte(CmdNak) {
+			buf = []byte{0x02, b}
+			break
+		}
+
 		// first byte of PLM packets is always 0x02
 		if b != 0x02 {
 			insteon.Log.Tracef("Expected Start of Text (0x02) got 0x%02x", b)
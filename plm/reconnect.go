@@ -0,0 +1,268 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/abates/insteon"
+)
+
+// ConnectionState indicates whether the PLM is connected to the modem
+type ConnectionState int
+
+// Connection states reported to the StateCallback
+const (
+	// StateDisconnected indicates reading from the port failed
+	StateDisconnected ConnectionState = iota
+
+	// StateReconnecting indicates a new port is being opened
+	StateReconnecting
+
+	// StateConnected indicates the port is open and the modem session
+	// has been restored
+	StateConnected
+)
+
+func (cs ConnectionState) String() string {
+	switch cs {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateReconnecting:
+		return "reconnecting"
+	}
+	return fmt.Sprintf("ConnectionState(%d)", int(cs))
+}
+
+type reconnectPolicy struct {
+	dial    func() (*Port, error)
+	initial time.Duration
+	max     time.Duration
+	probe   time.Duration
+	onState func(ConnectionState)
+}
+
+// Reconnect enables automatic reconnection.  When reading from the port
+// fails, the port is closed and dial is called (with an exponential backoff
+// between attempts) until a new port is opened or the PLM is closed.  The
+// modem configuration is read when the PLM is created.  Once reconnected, the
+// modem info is requested, the last known configuration is restored and the
+// cached link database is marked stale
+func Reconnect(dial func() (*Port, error)) Option {
+	return func(p *PLM) error {
+		p.reconnect.dial = dial
+		return nil
+	}
+}
+
+// ReconnectBackoff sets the initial and maximum delay between reconnection
+// attempts.  The delay doubles after each failed attempt until max is reached
+func ReconnectBackoff(initial, max time.Duration) Option {
+	return func(p *PLM) error {
+		if initial <= 0 || max < initial {
+			return errors.New("reconnect backoff must be positive and no greater than the maximum backoff")
+		}
+		p.reconnect.initial = initial
+		p.reconnect.max = max
+		return nil
+	}
+}
+
+// StateCallback sets a function that is called every time the connection
+// state changes, starting with StateConnected when the PLM is created.  The
+// callback is called from the PLM's internal goroutines and should not block
+func StateCallback(cb func(ConnectionState)) Option {
+	return func(p *PLM) error {
+		p.reconnect.onState = cb
+		return nil
+	}
+}
+
+// IdleProbe enables detection of a modem that stops responding while the PLM
+// is idle (such as a Hub that loses power while connected over TCP).  When
+// nothing has been read from the modem for the given interval, the modem info
+// is requested.  If the modem does not respond then the port is closed, which
+// triggers reconnection when the Reconnect option is also given
+func IdleProbe(interval time.Duration) Option {
+	return func(p *PLM) error {
+		if interval <= 0 {
+			return errors.New("idle probe interval must be positive")
+		}
+		p.reconnect.probe = interval
+		return nil
+	}
+}
+
+func (plm *PLM) markRead() {
+	plm.stateMu.Lock()
+	plm.lastRead = time.Now()
+	plm.stateMu.Unlock()
+}
+
+// probeLoop requests the modem info whenever the modem has been idle
+// for the probe interval and closes the port if there is no response
+func (plm *PLM) probeLoop() {
+	interval := plm.reconnect.probe
+	for {
+		select {
+		case <-time.After(interval):
+		case <-plm.closeCh:
+			return
+		}
+
+		plm.stateMu.Lock()
+		idle := plm.state == StateConnected && time.Since(plm.lastRead) >= interval
+		port := plm.port
+		plm.stateMu.Unlock()
+		if !idle {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), plm.timeout)
+		_, err := plm.InfoContext(ctx)
+		cancel()
+		if err != nil && !plm.isClosed() {
+			insteon.Log.Infof("PLM did not respond while idle: %v", err)
+			// the read loop will fail and reopen the port
+			plm.disconnect(port)
+		}
+	}
+}
+
+func (plm *PLM) setState(state ConnectionState) {
+	plm.stateMu.Lock()
+	plm.state = state
+	plm.stateMu.Unlock()
+	plm.notify(state)
+}
+
+func (plm *PLM) notify(state ConnectionState) {
+	insteon.Log.Debugf("PLM is %v", state)
+	if plm.reconnect.onState != nil {
+		plm.reconnect.onState(state)
+	}
+}
+
+// disconnect marks the PLM disconnected and closes the port.  Both the
+// read loop and the idle probe can find that the port has failed, the
+// port is only closed by whichever gets here first.  A port that has
+// already been replaced, or that belongs to a closed PLM, is left alone
+func (plm *PLM) disconnect(port *Port) {
+	plm.stateMu.Lock()
+	if port != plm.port || plm.state == StateDisconnected || plm.isClosed() {
+		plm.stateMu.Unlock()
+		return
+	}
+	plm.state = StateDisconnected
+	plm.stateMu.Unlock()
+
+	plm.notify(StateDisconnected)
+	port.Close()
+}
+
+func (plm *PLM) currentPort() *Port {
+	plm.stateMu.Lock()
+	defer plm.stateMu.Unlock()
+	return plm.port
+}
+
+func (plm *PLM) isClosed() bool {
+	select {
+	case <-plm.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// saveConfig records the last known modem configuration so that
+// it can be restored after reconnecting
+func (plm *PLM) saveConfig(config *Config) {
+	c := *config
+	plm.stateMu.Lock()
+	plm.config = &c
+	plm.stateMu.Unlock()
+}
+
+// reopen is called by the read loop when the port returns an error.  If
+// reconnection is enabled, the failed port is closed and a new port is
+// opened.  The return value indicates whether the read loop should continue
+func (plm *PLM) reopen(port *Port) bool {
+	if plm.reconnect.dial == nil || plm.isClosed() {
+		return false
+	}
+
+	plm.disconnect(port)
+
+	delay := plm.reconnect.initial
+	for {
+		plm.setState(StateReconnecting)
+		port, err := plm.reconnect.dial()
+		if err == nil {
+			plm.stateMu.Lock()
+			if plm.isClosed() {
+				plm.stateMu.Unlock()
+				port.Close()
+				return false
+			}
+			plm.port = port
+			plm.stateMu.Unlock()
+
+			// the session must be restored outside of the read
+			// loop since the read loop delivers the responses
+			go plm.restore()
+			return true
+		}
+
+		insteon.Log.Infof("Failed to reconnect to PLM (retrying in %v): %v", delay, err)
+		select {
+		case <-time.After(delay):
+		case <-plm.closeCh:
+			return false
+		}
+
+		delay *= 2
+		if delay > plm.reconnect.max {
+			delay = plm.reconnect.max
+		}
+	}
+}
+
+// restore re-establishes the modem session following a reconnect
+func (plm *PLM) restore() {
+	plm.linkdb.invalidate()
+
+	info, err := plm.Info()
+	if err == nil {
+		insteon.Log.Infof("Reconnected to %v", info)
+		plm.stateMu.Lock()
+		config := plm.config
+		plm.stateMu.Unlock()
+
+		if config != nil {
+			err = plm.SetConfig(config)
+		}
+	}
+
+	if err != nil {
+		insteon.Log.Infof("Failed to restore PLM session: %v", err)
+	}
+	plm.setState(StateConnected)
+}
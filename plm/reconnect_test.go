@@ -0,0 +1,232 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abates/insteon"
+	"github.com/abates/insteon/plm/plmtest"
+)
+
+func TestConnectionStateString(t *testing.T) {
+	tests := []struct {
+		input ConnectionState
+		want  string
+	}{
+		{StateConnected, "connected"},
+		{StateDisconnected, "disconnected"},
+		{StateReconnecting, "reconnecting"},
+		{ConnectionState(42), "ConnectionState(42)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.String(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPLMReconnect(t *testing.T) {
+	first := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+	second := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+	second.AddLinks(insteon.ControllerLink(1, insteon.Address{4, 5, 6}))
+
+	// the first dial fails to exercise the backoff
+	dials := 0
+	dial := func() (*Port, error) {
+		dials++
+		if dials == 1 {
+			return nil, errors.New("port not ready")
+		}
+		return NewPort(second, time.Millisecond), nil
+	}
+
+	var mu sync.Mutex
+	var states []ConnectionState
+	connected := make(chan bool, 1)
+	onState := func(state ConnectionState) {
+		mu.Lock()
+		states = append(states, state)
+		mu.Unlock()
+		if state == StateConnected {
+			connected <- true
+		}
+	}
+
+	plm, err := New(NewPort(first, time.Millisecond), 100*time.Millisecond, WriteDelay(time.Millisecond), Reconnect(dial), ReconnectBackoff(time.Millisecond, time.Millisecond), StateCallback(onState))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer plm.Close()
+	<-connected
+
+	config := Config(0x40)
+	if err := plm.SetConfig(&config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if links, _ := plm.Links(); len(links) != 0 {
+		t.Fatalf("want no links got %v", links)
+	}
+
+	// simulate the modem going away
+	first.Close()
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for reconnect")
+	}
+
+	wantStates := []ConnectionState{StateConnected, StateDisconnected, StateReconnecting, StateReconnecting, StateConnected}
+	mu.Lock()
+	if !reflect.DeepEqual(wantStates, states) {
+		t.Errorf("want states %v got %v", wantStates, states)
+	}
+	mu.Unlock()
+
+	if second.Config() != byte(config) {
+		t.Errorf("want restored config %v got %v", config, Config(second.Config()))
+	}
+
+	if links, err := plm.Links(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if len(links) != 1 {
+		t.Errorf("want link database to be refreshed got %v", links)
+	}
+}
+
+func TestPLMReconnectRestoresInitialConfig(t *testing.T) {
+	first := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+	first.SetConfig(0x60)
+	second := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+
+	connected := make(chan bool, 1)
+	onState := func(state ConnectionState) {
+		if state == StateConnected {
+			connected <- true
+		}
+	}
+	dial := func() (*Port, error) { return NewPort(second, time.Millisecond), nil }
+
+	// the caller never reads or sets the config
+	plm, err := New(NewPort(first, time.Millisecond), 100*time.Millisecond, WriteDelay(time.Millisecond), Reconnect(dial), ReconnectBackoff(time.Millisecond, time.Millisecond), StateCallback(onState))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer plm.Close()
+	<-connected
+
+	first.Close()
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for reconnect")
+	}
+
+	if second.Config() != 0x60 {
+		t.Errorf("want restored config %v got %v", Config(0x60), Config(second.Config()))
+	}
+}
+
+func TestPLMReconnectClose(t *testing.T) {
+	emulator := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+	dialed := make(chan bool, 10)
+	dial := func() (*Port, error) {
+		dialed <- true
+		return nil, errors.New("port not ready")
+	}
+
+	plm, err := New(NewPort(emulator, time.Millisecond), 100*time.Millisecond, Reconnect(dial), ReconnectBackoff(time.Hour, time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	emulator.Close()
+	select {
+	case <-dialed:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for reconnect attempt")
+	}
+
+	// closing the PLM must interrupt the backoff
+	done := make(chan bool)
+	go func() {
+		plm.Close()
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out closing the PLM")
+	}
+}
+
+// closeCounter counts the number of times the modem is closed
+type closeCounter struct {
+	*plmtest.PLM
+	mu     sync.Mutex
+	closes int
+}
+
+func (cc *closeCounter) Close() error {
+	cc.mu.Lock()
+	cc.closes++
+	cc.mu.Unlock()
+	return cc.PLM.Close()
+}
+
+func TestPLMIdleProbeClosesPortOnce(t *testing.T) {
+	first := &closeCounter{PLM: plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)}
+	second := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+
+	// the first modem stops responding while idle
+	first.Fault(byte(CmdGetInfo), plmtest.FaultDropAck)
+
+	connected := make(chan bool, 1)
+	onState := func(state ConnectionState) {
+		if state == StateConnected {
+			connected <- true
+		}
+	}
+	dial := func() (*Port, error) { return NewPort(second, time.Millisecond), nil }
+
+	plm, err := New(NewPort(first, time.Millisecond), 100*time.Millisecond, WriteDelay(time.Millisecond), Reconnect(dial), ReconnectBackoff(time.Millisecond, time.Millisecond), IdleProbe(10*time.Millisecond), StateCallback(onState))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer plm.Close()
+	<-connected
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the idle probe to reconnect")
+	}
+
+	first.mu.Lock()
+	if first.closes != 1 {
+		t.Errorf("want the port closed once got %d", first.closes)
+	}
+	first.mu.Unlock()
+}
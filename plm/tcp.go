@@ -0,0 +1,139 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/abates/insteon"
+)
+
+// TCPTransport is an io.ReadWriteCloser connected to a modem that is
+// reachable over TCP, such as the raw IM port (9761) on an Insteon Hub
+// or a serial port exported by ser2net.  The transport does not reconnect:
+// once the connection is lost Read and Write return the error.  Pass the
+// Reconnect option (with a function that calls DialTCP) to New so that the
+// PLM re-establishes the connection and restores the modem session.  TCP
+// keepalives detect connections the operating system knows are gone, while
+// the IdleProbe option detects a modem that vanishes while the PLM is idle
+type TCPTransport struct {
+	address      string
+	dialTimeout  time.Duration
+	keepAlive    time.Duration
+	writeTimeout time.Duration
+
+	conn   net.Conn
+	mu     sync.Mutex
+	closed bool
+}
+
+// TCPOption configures a TCPTransport
+type TCPOption func(t *TCPTransport) error
+
+// DialTimeout sets how long to wait for the connection
+func DialTimeout(d time.Duration) TCPOption {
+	return func(t *TCPTransport) error {
+		t.dialTimeout = d
+		return nil
+	}
+}
+
+// KeepAlive sets the TCP keepalive period used to detect
+// half-open connections
+func KeepAlive(d time.Duration) TCPOption {
+	return func(t *TCPTransport) error {
+		t.keepAlive = d
+		return nil
+	}
+}
+
+// WriteTimeout sets the maximum time a write may block before
+// the connection is considered lost
+func WriteTimeout(d time.Duration) TCPOption {
+	return func(t *TCPTransport) error {
+		t.writeTimeout = d
+		return nil
+	}
+}
+
+// DialTCP connects to the modem at the given address (host:port)
+func DialTCP(address string, options ...TCPOption) (*TCPTransport, error) {
+	t := &TCPTransport{
+		address:      address,
+		dialTimeout:  5 * time.Second,
+		keepAlive:    30 * time.Second,
+		writeTimeout: 5 * time.Second,
+	}
+
+	for _, o := range options {
+		err := o(t)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: t.dialTimeout, KeepAlive: t.keepAlive}
+	conn, err := dialer.Dial("tcp", t.address)
+	if err == nil {
+		insteon.Log.Infof("Connected to %s", t.address)
+		t.conn = conn
+		return t, nil
+	}
+	return nil, err
+}
+
+func (t *TCPTransport) isClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// Read reads from the connection.  io.EOF is returned once the
+// transport has been closed
+func (t *TCPTransport) Read(buf []byte) (int, error) {
+	n, err := t.conn.Read(buf)
+	if err != nil && t.isClosed() {
+		err = io.EOF
+	} else if err != nil {
+		insteon.Log.Infof("Lost connection to %s: %v", t.address, err)
+	}
+	return n, err
+}
+
+// Write writes to the connection
+func (t *TCPTransport) Write(buf []byte) (int, error) {
+	if t.isClosed() {
+		return 0, io.ErrClosedPipe
+	}
+
+	if t.writeTimeout > 0 {
+		t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
+	}
+	return t.conn.Write(buf)
+}
+
+// Close closes the connection
+func (t *TCPTransport) Close() (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		err = t.conn.Close()
+	}
+	return err
+}
@@ -0,0 +1,259 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abates/insteon"
+	"github.com/abates/insteon/plm/plmtest"
+)
+
+func listen(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	return listener
+}
+
+func accept(t *testing.T, listener net.Listener) net.Conn {
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	return conn
+}
+
+// serve bridges the next connection accepted by the listener to the
+// emulator.  The connection is closed when the emulator is closed
+func serve(listener net.Listener, emulator *plmtest.PLM) {
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			go io.Copy(emulator, conn)
+			io.Copy(conn, emulator)
+			conn.Close()
+		}
+	}()
+}
+
+func TestTCPTransportConnectionLost(t *testing.T) {
+	listener := listen(t)
+	defer listener.Close()
+
+	transport, err := DialTCP(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer transport.Close()
+
+	server := accept(t, listener)
+	server.Write([]byte{0x02})
+	buf := make([]byte, 1)
+	if _, err := transport.Read(buf); err != nil || buf[0] != 0x02 {
+		t.Errorf("want 02 got %02x (%v)", buf[0], err)
+	}
+
+	if _, err := transport.Write([]byte{0x60}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if _, err := server.Read(buf); err != nil || buf[0] != 0x60 {
+		t.Errorf("want 60 got %02x (%v)", buf[0], err)
+	}
+
+	// the transport does not reconnect, the error is returned so that
+	// the PLM can reconnect
+	server.Close()
+	if _, err := transport.Read(buf); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestTCPTransportClose(t *testing.T) {
+	listener := listen(t)
+	defer listener.Close()
+	transport, err := DialTCP(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer accept(t, listener).Close()
+
+	errCh := make(chan error)
+	go func() {
+		_, err := transport.Read(make([]byte, 1))
+		errCh <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	transport.Close()
+	select {
+	case err := <-errCh:
+		if err != io.EOF {
+			t.Errorf("want error %v got %v", io.EOF, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for Read to return")
+	}
+
+	if _, err := transport.Write([]byte{0x02}); err != io.ErrClosedPipe {
+		t.Errorf("want error %v got %v", io.ErrClosedPipe, err)
+	}
+}
+
+func TestTCPTransportDialError(t *testing.T) {
+	listener := listen(t)
+	address := listener.Addr().String()
+	listener.Close()
+
+	if _, err := DialTCP(address); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+// testTCPReconnect creates a PLM connected over TCP that reconnects
+// to the listener and returns a channel that receives a value each
+// time the PLM reconnects
+func testTCPReconnect(t *testing.T, listener net.Listener, options ...Option) (*PLM, chan bool) {
+	t.Helper()
+	dial := func() (*Port, error) {
+		transport, err := DialTCP(listener.Addr().String())
+		if err != nil {
+			return nil, err
+		}
+		return NewPort(transport, time.Millisecond), nil
+	}
+
+	connected := make(chan bool, 1)
+	onState := func(state ConnectionState) {
+		if state == StateConnected {
+			connected <- true
+		}
+	}
+
+	port, err := dial()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	options = append([]Option{WriteDelay(time.Millisecond), Reconnect(dial), ReconnectBackoff(time.Millisecond, time.Millisecond), StateCallback(onState)}, options...)
+	plm, err := New(port, 50*time.Millisecond, options...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-connected
+	return plm, connected
+}
+
+func TestTCPTransportPLMReconnect(t *testing.T) {
+	listener := listen(t)
+	defer listener.Close()
+
+	// the first "hub" closes the connection while rebooting
+	first := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+	first.SetConfig(0x40)
+	serve(listener, first)
+
+	plm, connected := testTCPReconnect(t, listener)
+	defer plm.Close()
+
+	second := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 43)
+	serve(listener, second)
+	first.Close()
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for reconnect")
+	}
+
+	if second.Config() != 0x40 {
+		t.Errorf("want restored config %v got %v", Config(0x40), Config(second.Config()))
+	}
+
+	want := &Info{insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 43}
+	if got, err := plm.Info(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v got %v", want, got)
+	}
+}
+
+func TestTCPTransportIdleProbe(t *testing.T) {
+	listener := listen(t)
+	defer listener.Close()
+
+	// the first "hub" accepts the connection and then never responds
+	// (and never closes the connection)
+	hung := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			hung <- conn
+		}
+	}()
+
+	plm, connected := testTCPReconnect(t, listener, IdleProbe(10*time.Millisecond))
+	defer plm.Close()
+	defer func() { (<-hung).Close() }()
+
+	emulator := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+	serve(listener, emulator)
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for the idle probe to reconnect")
+	}
+
+	if _, err := plm.Info(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTCPTransportPLM(t *testing.T) {
+	listener := listen(t)
+	defer listener.Close()
+
+	emulator := plmtest.New(insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			go io.Copy(emulator, conn)
+			io.Copy(conn, emulator)
+		}
+	}()
+
+	transport, err := DialTCP(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plm, err := New(NewPort(transport, time.Millisecond), time.Second, WriteDelay(time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer plm.Close()
+
+	want := &Info{insteon.Address{1, 2, 3}, insteon.DevCat{3, 21}, 42}
+	got, err := plm.Info()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %v got %v", want, got)
+	}
+}
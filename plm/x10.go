@@ -0,0 +1,275 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/abates/insteon"
+)
+
+var (
+	// ErrX10House is returned when an X10 house code is not between A and P
+	ErrX10House = errors.New("X10 house code must be between A and P")
+
+	// ErrX10Unit is returned when an X10 unit code is not between 1 and 16
+	ErrX10Unit = errors.New("X10 unit code must be between 1 and 16")
+
+	// ErrX10Command is returned when an X10 command is not known
+	ErrX10Command = errors.New("unknown X10 command")
+)
+
+// x10Codes maps house codes A-P and unit codes 1-16 to the
+// 4 bit values used on the wire.  Both house and unit codes use
+// the same encoding
+var x10Codes = [16]byte{0x06, 0x0e, 0x02, 0x0a, 0x01, 0x09, 0x05, 0x0d, 0x07, 0x0f, 0x03, 0x0b, 0x00, 0x08, 0x04, 0x0c}
+
+func x10Decode(code byte) int {
+	for i, c := range x10Codes {
+		if c == code&0x0f {
+			return i
+		}
+	}
+	return -1
+}
+
+const (
+	x10UnitFlag    = 0x00
+	x10CommandFlag = 0x80
+
+	// x10BufLen is the number of received events buffered for
+	// each listener before new events are dropped
+	x10BufLen = 10
+)
+
+// X10House is an X10 house code.  House codes are the letters A through P
+type X10House byte
+
+// X10 house codes
+const (
+	X10HouseA X10House = 'A' + iota
+	X10HouseB
+	X10HouseC
+	X10HouseD
+	X10HouseE
+	X10HouseF
+	X10HouseG
+	X10HouseH
+	X10HouseI
+	X10HouseJ
+	X10HouseK
+	X10HouseL
+	X10HouseM
+	X10HouseN
+	X10HouseO
+	X10HouseP
+)
+
+func (h X10House) valid() bool { return X10HouseA <= h && h <= X10HouseP }
+
+func (h X10House) code() byte { return x10Codes[h-X10HouseA] }
+
+// String returns the house code letter
+func (h X10House) String() string {
+	if h.valid() {
+		return string(rune(h))
+	}
+	return fmt.Sprintf("X10House(%d)", byte(h))
+}
+
+// Set parses the house code letter (case insensitive) from the given string
+func (h *X10House) Set(str string) error {
+	str = strings.ToUpper(strings.TrimSpace(str))
+	if len(str) != 1 || !X10House(str[0]).valid() {
+		return ErrX10House
+	}
+	*h = X10House(str[0])
+	return nil
+}
+
+// X10Unit is an X10 unit code between 1 and 16
+type X10Unit byte
+
+func (u X10Unit) valid() bool { return 1 <= u && u <= 16 }
+
+func (u X10Unit) code() byte { return x10Codes[u-1] }
+
+// String returns the decimal unit code
+func (u X10Unit) String() string { return fmt.Sprintf("%d", byte(u)) }
+
+// Set parses the decimal unit code from the given string
+func (u *X10Unit) Set(str string) error {
+	v, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil || !X10Unit(v).valid() {
+		return ErrX10Unit
+	}
+	*u = X10Unit(v)
+	return nil
+}
+
+// X10Command is one of the sixteen X10 function codes
+type X10Command byte
+
+// X10 function codes
+const (
+	X10AllUnitsOff     X10Command = 0x00 // All Units Off
+	X10AllLightsOn     X10Command = 0x01 // All Lights On
+	X10On              X10Command = 0x02 // On
+	X10Off             X10Command = 0x03 // Off
+	X10Dim             X10Command = 0x04 // Dim
+	X10Bright          X10Command = 0x05 // Bright
+	X10AllLightsOff    X10Command = 0x06 // All Lights Off
+	X10ExtendedCode    X10Command = 0x07 // Extended Code
+	X10HailRequest     X10Command = 0x08 // Hail Request
+	X10HailAck         X10Command = 0x09 // Hail Ack
+	X10PresetDim1      X10Command = 0x0a // Preset Dim 1
+	X10PresetDim2      X10Command = 0x0b // Preset Dim 2
+	X10ExtendedData    X10Command = 0x0c // Extended Data
+	X10StatusOn        X10Command = 0x0d // Status On
+	X10StatusOff       X10Command = 0x0e // Status Off
+	X10StatusRequest   X10Command = 0x0f // Status Request
+	x10CommandSentinel X10Command = 0x10
+)
+
+var x10CommandStrings = []string{
+	"All Units Off", "All Lights On", "On", "Off", "Dim", "Bright", "All Lights Off", "Extended Code",
+	"Hail Request", "Hail Ack", "Preset Dim 1", "Preset Dim 2", "Extended Data", "Status On", "Status Off", "Status Request",
+}
+
+func (cmd X10Command) String() string {
+	if cmd < x10CommandSentinel {
+		return x10CommandStrings[cmd]
+	}
+	return fmt.Sprintf("X10Command(%d)", byte(cmd))
+}
+
+// X10Event is a decoded X10 message received by the modem.  X10
+// transmissions are sent as a unit address followed by a command,
+// Unit is the last unit addressed for the house code (or zero if
+// no unit has been addressed, as is the case for house wide commands
+// such as All Units Off)
+type X10Event struct {
+	House   X10House
+	Unit    X10Unit
+	Command X10Command
+}
+
+func (e *X10Event) String() string {
+	if e.Unit == 0 {
+		return fmt.Sprintf("X10 %s %s", e.House, e.Command)
+	}
+	return fmt.Sprintf("X10 %s%s %s", e.House, e.Unit, e.Command)
+}
+
+type x10Listeners struct {
+	mu        sync.Mutex
+	units     map[X10House]X10Unit
+	listeners map[<-chan *X10Event]chan *X10Event
+	bufLen    int
+}
+
+// receive decodes an X10 Message Received packet and delivers any
+// complete events to the listeners.  receive never blocks, if a
+// listener's channel is full then the event is dropped for that listener
+func (xl *x10Listeners) receive(packet *Packet) {
+	if len(packet.Payload) < 2 {
+		insteon.Log.Infof("Short X10 packet: %v", packet)
+		return
+	}
+
+	raw, flag := packet.Payload[0], packet.Payload[1]
+	house := X10House(x10Decode(raw>>4)) + X10HouseA
+
+	xl.mu.Lock()
+	defer xl.mu.Unlock()
+	if xl.units == nil {
+		xl.units = make(map[X10House]X10Unit)
+	}
+
+	if flag == x10UnitFlag {
+		xl.units[house] = X10Unit(x10Decode(raw) + 1)
+		return
+	}
+
+	event := &X10Event{House: house, Unit: xl.units[house], Command: X10Command(raw & 0x0f)}
+	insteon.Log.Debugf("Received %v", event)
+	for _, ch := range xl.listeners {
+		select {
+		case ch <- event:
+		default:
+			insteon.Log.Infof("X10 listener is full, dropping %v", event)
+		}
+	}
+}
+
+// AddX10Listener returns a channel that receives every X10 event
+// decoded by the modem.  Slow listeners will miss events once the
+// channel's buffer is full
+func (xl *x10Listeners) AddX10Listener() <-chan *X10Event {
+	ch := make(chan *X10Event, xl.bufLen)
+	xl.mu.Lock()
+	if xl.listeners == nil {
+		xl.listeners = make(map[<-chan *X10Event]chan *X10Event)
+	}
+	xl.listeners[ch] = ch
+	xl.mu.Unlock()
+	return ch
+}
+
+// RemoveX10Listener closes the channel and stops delivery of X10 events to it
+func (xl *x10Listeners) RemoveX10Listener(ch <-chan *X10Event) {
+	xl.mu.Lock()
+	if listener, found := xl.listeners[ch]; found {
+		close(listener)
+		delete(xl.listeners, ch)
+	}
+	xl.mu.Unlock()
+}
+
+// SendX10 transmits an X10 unit address followed by the command.  If
+// unit is zero then only the command is sent, which is useful for house
+// wide commands such as All Units Off
+func (plm *PLM) SendX10(house X10House, unit X10Unit, cmd X10Command) error {
+	return plm.SendX10Context(context.Background(), house, unit, cmd)
+}
+
+// SendX10Context is the same as SendX10 except that waiting for the
+// modem is abandoned when the context is done
+func (plm *PLM) SendX10Context(ctx context.Context, house X10House, unit X10Unit, cmd X10Command) (err error) {
+	if !house.valid() {
+		return ErrX10House
+	} else if unit != 0 && !unit.valid() {
+		return ErrX10Unit
+	} else if cmd >= x10CommandSentinel {
+		return ErrX10Command
+	}
+
+	plm.Lock()
+	defer plm.Unlock()
+
+	if unit != 0 {
+		_, err = plm.tx(ctx, &Packet{Command: CmdSendX10, Payload: []byte{house.code()<<4 | unit.code(), x10UnitFlag}}, plm.writeDelay)
+	}
+
+	if err == nil {
+		_, err = plm.tx(ctx, &Packet{Command: CmdSendX10, Payload: []byte{house.code()<<4 | byte(cmd), x10CommandFlag}}, plm.writeDelay)
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abates/insteon/plm/plmtest"
+)
+
+func TestX10HouseSet(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    X10House
+		wantErr error
+	}{
+		{"A", X10HouseA, nil},
+		{"p", X10HouseP, nil},
+		{" c ", X10HouseC, nil},
+		{"Q", 0, ErrX10House},
+		{"AB", 0, ErrX10House},
+		{"", 0, ErrX10House},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var got X10House
+			err := got.Set(test.input)
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			} else if got != test.want {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestX10UnitSet(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    X10Unit
+		wantErr error
+	}{
+		{"1", 1, nil},
+		{"16", 16, nil},
+		{"0", 0, ErrX10Unit},
+		{"17", 0, ErrX10Unit},
+		{"A", 0, ErrX10Unit},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var got X10Unit
+			err := got.Set(test.input)
+			if err != test.wantErr {
+				t.Errorf("want error %v got %v", test.wantErr, err)
+			} else if got != test.want {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestX10Strings(t *testing.T) {
+	tests := []struct {
+		input interface{ String() string }
+		want  string
+	}{
+		{X10HouseA, "A"},
+		{X10House(0), "X10House(0)"},
+		{X10Unit(12), "12"},
+		{X10AllUnitsOff, "All Units Off"},
+		{X10StatusRequest, "Status Request"},
+		{X10Command(0x10), "X10Command(16)"},
+		{&X10Event{House: X10HouseB, Unit: 3, Command: X10On}, "X10 B3 On"},
+		{&X10Event{House: X10HouseM, Command: X10AllLightsOff}, "X10 M All Lights Off"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.String(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPLMSendX10(t *testing.T) {
+	tests := []struct {
+		desc    string
+		house   X10House
+		unit    X10Unit
+		cmd     X10Command
+		want    [][2]byte
+		wantErr error
+	}{
+		{"A1 On", X10HouseA, 1, X10On, [][2]byte{{0x66, 0x00}, {0x62, 0x80}}, nil},
+		{"P16 Off", X10HouseP, 16, X10Off, [][2]byte{{0xcc, 0x00}, {0xc3, 0x80}}, nil},
+		{"M All Units Off", X10HouseM, 0, X10AllUnitsOff, [][2]byte{{0x00, 0x80}}, nil},
+		{"Bad house", X10House('Z'), 1, X10On, [][2]byte{}, ErrX10House},
+		{"Bad unit", X10HouseA, 17, X10On, [][2]byte{}, ErrX10Unit},
+		{"Bad command", X10HouseA, 1, X10Command(0x10), [][2]byte{}, ErrX10Command},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			plm, emulator := newTestPLM(t)
+			defer plm.Close()
+
+			err := plm.SendX10(test.house, test.unit, test.cmd)
+			if err != test.wantErr {
+				t.Fatalf("want error %v got %v", test.wantErr, err)
+			}
+
+			if got := emulator.SentX10(); !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want sent %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPLMSendX10Context(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+
+	// the modem never acks, so only the context can stop the wait
+	emulator.Fault(byte(CmdSendX10), plmtest.FaultDropAck)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := plm.SendX10Context(ctx, X10HouseA, 1, X10On); err != context.DeadlineExceeded {
+		t.Errorf("want error %v got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestPLMReceiveX10(t *testing.T) {
+	plm, emulator := newTestPLM(t)
+	defer plm.Close()
+
+	ch := plm.AddX10Listener()
+	emulator.ReceiveX10(0x66, 0x00) // A1
+	emulator.ReceiveX10(0x62, 0x80) // A On
+	emulator.ReceiveX10(0x00, 0x80) // M All Units Off
+
+	want := []*X10Event{
+		{House: X10HouseA, Unit: 1, Command: X10On},
+		{House: X10HouseM, Unit: 0, Command: X10AllUnitsOff},
+	}
+
+	for _, w := range want {
+		select {
+		case got := <-ch:
+			if !reflect.DeepEqual(w, got) {
+				t.Errorf("want %v got %v", w, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %v", w)
+		}
+	}
+
+	plm.RemoveX10Listener(ch)
+	if _, open := <-ch; open {
+		t.Errorf("expected channel to be closed")
+	}
+}
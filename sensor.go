@@ -0,0 +1,131 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"fmt"
+)
+
+// SensorEventType identifies what a sensor reported in a SensorEvent
+type SensorEventType int
+
+// Sensor event types
+const (
+	SensorMotion          SensorEventType = iota // Active when motion is detected
+	SensorDark                                   // Active at dusk, inactive at dawn
+	SensorLowBattery                             // Active when the battery is low
+	SensorHeartbeat                              // Periodic heartbeat, always active
+	SensorOpen                                   // Active when opened, inactive when closed
+	SensorWet                                    // Active when wet, inactive when dry
+	SensorHeartbeatMissed                        // Active when an expected heartbeat did not arrive
+	SensorSmoke                                  // Active when smoke is detected
+	SensorCO                                     // Active when carbon monoxide is detected
+	SensorTest                                   // Detector test button pressed
+	SensorNewDetector                            // A new detector was linked
+	SensorAllClear                               // All alarms have cleared
+	SensorMalfunction                            // Active when a detector has malfunctioned
+)
+
+func (set SensorEventType) String() string {
+	switch set {
+	case SensorMotion:
+		return "Motion"
+	case SensorDark:
+		return "Dark"
+	case SensorLowBattery:
+		return "Low Battery"
+	case SensorHeartbeat:
+		return "Heartbeat"
+	case SensorOpen:
+		return "Open"
+	case SensorWet:
+		return "Wet"
+	case SensorHeartbeatMissed:
+		return "Heartbeat Missed"
+	case SensorSmoke:
+		return "Smoke"
+	case SensorCO:
+		return "CO"
+	case SensorTest:
+		return "Test"
+	case SensorNewDetector:
+		return "New Detector"
+	case SensorAllClear:
+		return "All Clear"
+	case SensorMalfunction:
+		return "Malfunction"
+	}
+	return fmt.Sprintf("SensorEventType(%d)", int(set))
+}
+
+// SensorEvent is an Event from a sensor interpreted according to the
+// meaning of the group it was sent to
+type SensorEvent struct {
+	Src    Address
+	Type   SensorEventType
+	Active bool
+}
+
+func (se *SensorEvent) String() string {
+	return sprintf("%s %s Active(%v)", se.Src, se.Type, se.Active)
+}
+
+// sensorGroup describes the event reported on one of a sensor's groups
+type sensorGroup struct {
+	// eventType is the type of the decoded event
+	eventType SensorEventType
+
+	// inverted groups report an inactive event with an on command (such
+	// as the closed group of a door sensor)
+	inverted bool
+}
+
+// sensorGroups maps the groups of a sensor to the event reported
+// on the group
+type sensorGroups map[Group]sensorGroup
+
+// decode interprets the event.  On commands make the event active and off
+// commands make it inactive (or the opposite for inverted groups).  Heartbeat
+// events are always active and heartbeat commands are decoded regardless
+// of group
+func (sg sensorGroups) decode(src Address, event *Event) (*SensorEvent, bool) {
+	if event.Src != src {
+		return nil, false
+	}
+
+	if event.Action == ActionHeartbeat {
+		return &SensorEvent{Src: src, Type: SensorHeartbeat, Active: true}, true
+	}
+
+	group, found := sg[event.Group]
+	if !found {
+		return nil, false
+	}
+
+	sensorEvent := &SensorEvent{Src: src, Type: group.eventType}
+	switch event.Action {
+	case ActionOn, ActionOnFast:
+		sensorEvent.Active = !group.inverted
+	case ActionOff, ActionOffFast:
+		sensorEvent.Active = group.inverted
+	default:
+		return nil, false
+	}
+
+	if group.eventType == SensorHeartbeat {
+		sensorEvent.Active = true
+	}
+	return sensorEvent, true
+}
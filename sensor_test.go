@@ -0,0 +1,49 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"testing"
+)
+
+func TestSensorEventString(t *testing.T) {
+	tests := []struct {
+		input *SensorEvent
+		want  string
+	}{
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorMotion, Active: true}, "01.02.03 Motion Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorDark}, "01.02.03 Dark Active(false)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorLowBattery, Active: true}, "01.02.03 Low Battery Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorHeartbeat, Active: true}, "01.02.03 Heartbeat Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorOpen, Active: true}, "01.02.03 Open Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorWet}, "01.02.03 Wet Active(false)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorHeartbeatMissed, Active: true}, "01.02.03 Heartbeat Missed Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorSmoke, Active: true}, "01.02.03 Smoke Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorCO, Active: true}, "01.02.03 CO Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorTest, Active: true}, "01.02.03 Test Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorNewDetector, Active: true}, "01.02.03 New Detector Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorAllClear, Active: true}, "01.02.03 All Clear Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorMalfunction, Active: true}, "01.02.03 Malfunction Active(true)"},
+		{&SensorEvent{Src: Address{1, 2, 3}, Type: SensorEventType(42)}, "01.02.03 SensorEventType(42) Active(false)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.String(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
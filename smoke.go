@@ -0,0 +1,218 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+func init() {
+	Devices.Register(0x10, smokeBridgeFactory, SubCategories(0x0a, 0x0a))
+}
+
+func smokeBridgeFactory(info DeviceInfo, device Device, timeout time.Duration) (Device, error) {
+	return newSmokeBridge(device, timeout, productName(info.DevCat, "Smoke Bridge")), nil
+}
+
+// Smoke Bridge groups
+const (
+	SmokeGroup          Group = 0x01 // smoke detected
+	COGroup             Group = 0x02 // carbon monoxide detected
+	TestGroup           Group = 0x03 // detector test button pressed
+	NewDetectorGroup    Group = 0x04 // new detector linked to the bridge
+	AllClearGroup       Group = 0x05 // all alarms cleared
+	SmokeBatteryGroup   Group = 0x06 // detector battery low
+	MalfunctionGroup    Group = 0x07 // detector malfunction
+	SmokeHeartbeatGroup Group = 0x0a // periodic heartbeat
+)
+
+var smokeBridgeGroups = sensorGroups{
+	SmokeGroup:          {eventType: SensorSmoke},
+	COGroup:             {eventType: SensorCO},
+	TestGroup:           {eventType: SensorTest},
+	NewDetectorGroup:    {eventType: SensorNewDetector},
+	AllClearGroup:       {eventType: SensorAllClear},
+	SmokeBatteryGroup:   {eventType: SensorLowBattery},
+	MalfunctionGroup:    {eventType: SensorMalfunction},
+	SmokeHeartbeatGroup: {eventType: SensorHeartbeat},
+}
+
+// AlarmSeverity indicates how urgently an AlarmEvent needs attention
+type AlarmSeverity int
+
+// Alarm severities in increasing order of urgency
+const (
+	SeverityInfo     AlarmSeverity = iota // informational, such as tests and heartbeats
+	SeverityWarning                       // maintenance is needed, such as a low battery
+	SeverityCritical                      // life safety alarms (smoke and CO)
+)
+
+func (as AlarmSeverity) String() string {
+	switch as {
+	case SeverityInfo:
+		return "Info"
+	case SeverityWarning:
+		return "Warning"
+	case SeverityCritical:
+		return "Critical"
+	}
+	return fmt.Sprintf("AlarmSeverity(%d)", int(as))
+}
+
+var alarmSeverities = map[SensorEventType]AlarmSeverity{
+	SensorSmoke:       SeverityCritical,
+	SensorCO:          SeverityCritical,
+	SensorLowBattery:  SeverityWarning,
+	SensorMalfunction: SeverityWarning,
+}
+
+// AlarmEvent is a SensorEvent with the severity of the alarm.  Inactive
+// events (off commands) are always informational
+type AlarmEvent struct {
+	SensorEvent
+	Severity AlarmSeverity
+}
+
+func (ae *AlarmEvent) String() string {
+	return sprintf("%s %s", &ae.SensorEvent, ae.Severity)
+}
+
+// SmokeBridgeState is the latched alarm state of a Smoke Bridge as
+// determined from the events passed to DecodeEvent.  Alarms are only
+// cleared by the all clear event
+type SmokeBridgeState struct {
+	Smoke       bool
+	CO          bool
+	LowBattery  bool
+	Malfunction bool
+
+	// Updated is the time an alarm was last raised or cleared
+	Updated time.Time
+
+	// Heartbeat is the time of the last heartbeat
+	Heartbeat time.Time
+}
+
+// Alarm returns true if either the smoke or CO alarm is latched
+func (sbs SmokeBridgeState) Alarm() bool {
+	return sbs.Smoke || sbs.CO
+}
+
+// SmokeBridge is any device that satisfies the following interface
+type SmokeBridge interface {
+	Device
+
+	// DecodeEvent interprets an Event sent by the bridge and updates the
+	// latched alarm state.  The return value indicates whether the event
+	// was sent by the bridge to one of the Smoke Bridge groups
+	DecodeEvent(event *Event) (*AlarmEvent, bool)
+
+	// State returns the latched alarm state.  Since alarms stay latched
+	// until all clear is received, State can be polled to recover alarms
+	// whose events were missed
+	State() SmokeBridgeState
+}
+
+// LinkableSmokeBridge represents a SmokeBridge that supports remote
+// linking (Insteon Engine version 2 or higher)
+type LinkableSmokeBridge interface {
+	SmokeBridge
+	Linkable
+}
+
+type smokeBridge struct {
+	Device
+	timeout time.Duration
+	name    string
+	now     func() time.Time
+
+	mu    sync.Mutex
+	state SmokeBridgeState
+}
+
+type linkableSmokeBridge struct {
+	LinkableDevice
+	*smokeBridge
+}
+
+// NewSmokeBridge is a factory function that will return the correctly
+// configured Smoke Bridge based on the underlying device
+func NewSmokeBridge(device Device, timeout time.Duration) SmokeBridge {
+	return newSmokeBridge(device, timeout, "Smoke Bridge")
+}
+
+func newSmokeBridge(device Device, timeout time.Duration, name string) SmokeBridge {
+	sb := &smokeBridge{Device: device, timeout: timeout, name: name, now: time.Now}
+	if linkable, ok := device.(LinkableDevice); ok {
+		return &linkableSmokeBridge{LinkableDevice: linkable, smokeBridge: sb}
+	}
+	return sb
+}
+
+func (sb *smokeBridge) DecodeEvent(event *Event) (*AlarmEvent, bool) {
+	sensorEvent, found := smokeBridgeGroups.decode(sb.Address(), event)
+	if !found {
+		return nil, false
+	}
+
+	alarmEvent := &AlarmEvent{SensorEvent: *sensorEvent}
+	if sensorEvent.Active {
+		alarmEvent.Severity = alarmSeverities[sensorEvent.Type]
+	}
+
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if sensorEvent.Type == SensorHeartbeat {
+		sb.state.Heartbeat = sb.now()
+		return alarmEvent, true
+	}
+
+	// off commands never clear a latched alarm
+	if !sensorEvent.Active {
+		return alarmEvent, true
+	}
+
+	switch sensorEvent.Type {
+	case SensorSmoke:
+		sb.state.Smoke = true
+	case SensorCO:
+		sb.state.CO = true
+	case SensorLowBattery:
+		sb.state.LowBattery = true
+	case SensorMalfunction:
+		sb.state.Malfunction = true
+	case SensorAllClear:
+		sb.state.Smoke = false
+		sb.state.CO = false
+		sb.state.LowBattery = false
+		sb.state.Malfunction = false
+	default:
+		return alarmEvent, true
+	}
+	sb.state.Updated = sb.now()
+	return alarmEvent, true
+}
+
+func (sb *smokeBridge) State() SmokeBridgeState {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.state
+}
+
+func (sb *smokeBridge) String() string {
+	return fmt.Sprintf("%s (%s)", sb.name, sb.Address())
+}
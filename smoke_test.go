@@ -0,0 +1,161 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSmokeBridgeFactory(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input Device
+		want  reflect.Type
+	}{
+		{"Smoke Bridge", &i1Device{}, reflect.TypeOf(&smokeBridge{})},
+		{"Linkable Smoke Bridge", &i2Device{}, reflect.TypeOf(&linkableSmokeBridge{})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := reflect.TypeOf(NewSmokeBridge(test.input, 0))
+			if test.want != got {
+				t.Errorf("want type %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSmokeBridgeRegistry(t *testing.T) {
+	device, err := Devices.New(DeviceInfo{DevCat: DevCat{0x10, 0x0a}, EngineVersion: VerI2Cs}, &testConnection{addr: Address{1, 2, 3}}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := device.(LinkableSmokeBridge); !ok {
+		t.Errorf("want LinkableSmokeBridge got %T", device)
+	}
+
+	if want, got := "2982-222 Smoke Bridge (01.02.03)", fmt.Sprintf("%v", device); want != got {
+		t.Errorf("want %q got %q", want, got)
+	}
+}
+
+func TestAlarmSeverityString(t *testing.T) {
+	tests := []struct {
+		input AlarmSeverity
+		want  string
+	}{
+		{SeverityInfo, "Info"},
+		{SeverityWarning, "Warning"},
+		{SeverityCritical, "Critical"},
+		{AlarmSeverity(42), "AlarmSeverity(42)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.String(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+
+	event := &AlarmEvent{SensorEvent: SensorEvent{Src: Address{1, 2, 3}, Type: SensorSmoke, Active: true}, Severity: SeverityCritical}
+	if want, got := "01.02.03 Smoke Active(true) Critical", event.String(); want != got {
+		t.Errorf("want %q got %q", want, got)
+	}
+}
+
+func TestSmokeBridgeDecodeEvent(t *testing.T) {
+	src := Address{1, 2, 3}
+	alarm := func(eventType SensorEventType, active bool, severity AlarmSeverity) *AlarmEvent {
+		return &AlarmEvent{SensorEvent: SensorEvent{Src: src, Type: eventType, Active: active}, Severity: severity}
+	}
+
+	tests := []struct {
+		desc      string
+		input     *Event
+		want      *AlarmEvent
+		wantFound bool
+	}{
+		{"smoke", &Event{Src: src, Group: SmokeGroup, Action: ActionOn}, alarm(SensorSmoke, true, SeverityCritical), true},
+		{"co", &Event{Src: src, Group: COGroup, Action: ActionOn}, alarm(SensorCO, true, SeverityCritical), true},
+		{"test", &Event{Src: src, Group: TestGroup, Action: ActionOn}, alarm(SensorTest, true, SeverityInfo), true},
+		{"new detector", &Event{Src: src, Group: NewDetectorGroup, Action: ActionOn}, alarm(SensorNewDetector, true, SeverityInfo), true},
+		{"all clear", &Event{Src: src, Group: AllClearGroup, Action: ActionOn}, alarm(SensorAllClear, true, SeverityInfo), true},
+		{"low battery", &Event{Src: src, Group: SmokeBatteryGroup, Action: ActionOn}, alarm(SensorLowBattery, true, SeverityWarning), true},
+		{"malfunction", &Event{Src: src, Group: MalfunctionGroup, Action: ActionOn}, alarm(SensorMalfunction, true, SeverityWarning), true},
+		{"heartbeat", &Event{Src: src, Group: SmokeHeartbeatGroup, Action: ActionOn}, alarm(SensorHeartbeat, true, SeverityInfo), true},
+		{"smoke off", &Event{Src: src, Group: SmokeGroup, Action: ActionOff}, alarm(SensorSmoke, false, SeverityInfo), true},
+		{"unknown group", &Event{Src: src, Group: 8, Action: ActionOn}, nil, false},
+		{"other device", &Event{Src: Address{4, 5, 6}, Group: SmokeGroup, Action: ActionOn}, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			sb := NewSmokeBridge(&testConnection{addr: src}, 0)
+			got, found := sb.DecodeEvent(test.input)
+			if found != test.wantFound {
+				t.Errorf("want found %v got %v", test.wantFound, found)
+			} else if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSmokeBridgeState(t *testing.T) {
+	src := Address{1, 2, 3}
+	smoke := time.Date(2019, 3, 14, 15, 9, 26, 0, time.UTC)
+	co := smoke.Add(time.Second)
+	later := co.Add(time.Minute)
+	cleared := later.Add(time.Minute)
+
+	sb := NewSmokeBridge(&testConnection{addr: src}, 0).(*smokeBridge)
+	tests := []struct {
+		desc      string
+		now       time.Time
+		input     *Event
+		want      SmokeBridgeState
+		wantAlarm bool
+	}{
+		{"smoke", smoke, &Event{Src: src, Group: SmokeGroup, Action: ActionOn}, SmokeBridgeState{Smoke: true, Updated: smoke}, true},
+		{"co", co, &Event{Src: src, Group: COGroup, Action: ActionOn}, SmokeBridgeState{Smoke: true, CO: true, Updated: co}, true},
+		{"smoke off stays latched", later, &Event{Src: src, Group: SmokeGroup, Action: ActionOff}, SmokeBridgeState{Smoke: true, CO: true, Updated: co}, true},
+		{"test does not clear", later, &Event{Src: src, Group: TestGroup, Action: ActionOn}, SmokeBridgeState{Smoke: true, CO: true, Updated: co}, true},
+		{"low battery", later, &Event{Src: src, Group: SmokeBatteryGroup, Action: ActionOn}, SmokeBridgeState{Smoke: true, CO: true, LowBattery: true, Updated: later}, true},
+		{"heartbeat", later, &Event{Src: src, Group: SmokeHeartbeatGroup, Action: ActionOn}, SmokeBridgeState{Smoke: true, CO: true, LowBattery: true, Updated: later, Heartbeat: later}, true},
+		{"all clear", cleared, &Event{Src: src, Group: AllClearGroup, Action: ActionOn}, SmokeBridgeState{Updated: cleared, Heartbeat: later}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			now := test.now
+			sb.now = func() time.Time { return now }
+			sb.DecodeEvent(test.input)
+			got := sb.State()
+			if got != test.want {
+				t.Errorf("want %+v got %+v", test.want, got)
+			}
+
+			if got.Alarm() != test.wantAlarm {
+				t.Errorf("want alarm %v got %v", test.wantAlarm, got.Alarm())
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StateSource indicates where a device's last known state came from
+type StateSource int
+
+// Sources of device state
+const (
+	// SourceAck is the acknowledgement of a direct command sent to the device
+	SourceAck StateSource = iota
+
+	// SourceBroadcast is a group broadcast sent by the device itself
+	SourceBroadcast
+
+	// SourceGroup is the on level from the device's responder link when a
+	// controller fired the group
+	SourceGroup
+
+	// SourceStatus is the response to a status request
+	SourceStatus
+)
+
+func (ss StateSource) String() string {
+	switch ss {
+	case SourceAck:
+		return "ack"
+	case SourceBroadcast:
+		return "broadcast"
+	case SourceGroup:
+		return "group"
+	case SourceStatus:
+		return "status"
+	}
+	return fmt.Sprintf("StateSource(%d)", int(ss))
+}
+
+// State is the last known level of a device
+type State struct {
+	Address Address
+	Level   int
+	Source  StateSource
+	Updated time.Time
+}
+
+func (s State) String() string {
+	return sprintf("%s Level(%d) from %s at %s", s.Address, s.Level, s.Source, s.Updated.Format(time.RFC3339))
+}
+
+// StateOption provides a means to customize the state tracker
+type StateOption func(*StateTracker) error
+
+// StateMaxAge sets the age after which Level will re-verify the cached
+// state with a status request.  A zero age (the default) means the cached
+// state is always used once it is known
+func StateMaxAge(age time.Duration) StateOption {
+	return func(st *StateTracker) error {
+		if age < 0 {
+			return fmt.Errorf("invalid max age %v, must not be negative", age)
+		}
+		st.maxAge = age
+		return nil
+	}
+}
+
+// stateAckTimeout is how long a command passed to Sent waits for the
+// device's ack before it is forgotten
+const stateAckTimeout = 10 * time.Second
+
+type sentCommand struct {
+	cmd  Command
+	sent time.Time
+}
+
+type groupKey struct {
+	controller Address
+	group      Group
+}
+
+// StateTracker keeps the last known level of devices up to date by
+// watching the messages published on a Bus.  Levels are learned from the
+// acks of direct lighting commands (passed to Sent), from the group broadcasts
+// devices send when their paddles are pressed and, for responders whose links
+// have been added with AddLinks, from the broadcasts of their controllers.
+// A manual change (a held paddle) makes the levels unknown, so the next call
+// to Level sends a status request
+type StateTracker struct {
+	mu        sync.Mutex
+	bus       *Bus
+	msgs      <-chan *Message
+	maxAge    time.Duration
+	states    map[Address]State
+	sent      map[Address][]sentCommand
+	ownLevels map[Address]int
+	onLevels  map[groupKey]map[Address]int
+	listeners map[<-chan State]chan State
+	done      chan struct{}
+}
+
+// NewStateTracker subscribes to the bus and starts tracking device state.
+// Close should be called when the tracker is no longer needed
+func NewStateTracker(bus *Bus, options ...StateOption) (*StateTracker, error) {
+	st := &StateTracker{
+		bus:       bus,
+		states:    make(map[Address]State),
+		sent:      make(map[Address][]sentCommand),
+		ownLevels: make(map[Address]int),
+		onLevels:  make(map[groupKey]map[Address]int),
+		listeners: make(map[<-chan State]chan State),
+		done:      make(chan struct{}),
+	}
+
+	for _, option := range options {
+		if err := option(st); err != nil {
+			return nil, err
+		}
+	}
+
+	st.msgs = bus.SubscribeAll()
+	go st.readLoop()
+	return st, nil
+}
+
+func (st *StateTracker) readLoop() {
+	for msg := range st.msgs {
+		st.receive(msg)
+	}
+	close(st.done)
+}
+
+// Sent records a direct message sent to a device.  The ack for a direct
+// message carries a level that depends on the command that was sent (and a
+// status request ack carries the device's database delta in command 1), so
+// acks are only interpreted when they can be matched with the sent command.
+// Trackers returned by the PLM's TrackState are told about every message the
+// PLM sends
+func (st *StateTracker) Sent(msg *Message) {
+	if msg.Flags.Type() != MsgTypeDirect {
+		return
+	}
+
+	st.mu.Lock()
+	st.sent[msg.Dst] = append(st.expire(msg.Dst), sentCommand{msg.Command, time.Now()})
+	st.mu.Unlock()
+}
+
+// expire removes the commands sent to the device that are too old to
+// be acked and returns the rest.  The caller must hold the lock
+func (st *StateTracker) expire(addr Address) []sentCommand {
+	sent := st.sent[addr]
+	for len(sent) > 0 && time.Since(sent[0].sent) > stateAckTimeout {
+		sent = sent[1:]
+	}
+	return sent
+}
+
+// acked returns the oldest command sent to the device that has not
+// been acked.  Devices ack commands in the order they were received
+func (st *StateTracker) acked(addr Address) (cmd Command, found bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sent := st.expire(addr)
+	if len(sent) == 0 {
+		delete(st.sent, addr)
+		return cmd, false
+	}
+
+	cmd = sent[0].cmd
+	if len(sent) == 1 {
+		delete(st.sent, addr)
+	} else {
+		st.sent[addr] = sent[1:]
+	}
+	return cmd, true
+}
+
+// SetOnLevel records the level the device turns its load on to when its
+// paddle is tapped on (such as DimmerConfig.OnLevel).  The level is used
+// when the device broadcasts a group on command.  The default is 255
+func (st *StateTracker) SetOnLevel(addr Address, level int) {
+	st.mu.Lock()
+	st.ownLevels[addr] = level
+	st.mu.Unlock()
+}
+
+func (st *StateTracker) onLevel(addr Address) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if level, found := st.ownLevels[addr]; found {
+		return level
+	}
+	return 0xff
+}
+
+func (st *StateTracker) receive(msg *Message) {
+	switch msg.Flags.Type() {
+	case MsgTypeDirectAck, MsgTypeDirectNak:
+		cmd, found := st.acked(msg.Src)
+		if !found || msg.Flags.Type() == MsgTypeDirectNak {
+			return
+		}
+
+		switch cmd[1] {
+		case CmdLightOn[1], CmdLightOnFast[1], CmdLightInstantChange[1]:
+			st.update(msg.Src, int(msg.Command[2]), SourceAck)
+		case CmdLightOff[1], CmdLightOffFast[1]:
+			st.update(msg.Src, 0, SourceAck)
+		case CmdLightStatusRequest[1]:
+			// other status requests (such as the keypad LED
+			// request) do not return the level
+			if cmd[2] == CmdLightStatusRequest[2] {
+				st.update(msg.Src, int(msg.Command[2]), SourceStatus)
+			}
+		}
+		return
+	}
+
+	if event, found := DecodeEvent(msg); found {
+		// group 1 is the device's own load, the other groups (such
+		// as keypad buttons) only control their responders
+		own := event.Group == 1
+		switch event.Action {
+		case ActionOn:
+			if own {
+				st.update(event.Src, st.onLevel(event.Src), SourceBroadcast)
+			}
+			st.GroupCommand(event.Src, event.Group, true)
+		case ActionOnFast:
+			if own {
+				st.update(event.Src, 0xff, SourceBroadcast)
+			}
+			st.GroupCommand(event.Src, event.Group, true)
+		case ActionOff, ActionOffFast:
+			if own {
+				st.update(event.Src, 0, SourceBroadcast)
+			}
+			st.GroupCommand(event.Src, event.Group, false)
+		case ActionStartBrighten, ActionStartDim, ActionStopChange:
+			// the level a manual change stops at is not broadcast, so
+			// the next call to Level must ask the devices
+			if own {
+				st.invalidate(event.Src)
+			}
+			for responder := range st.responders(event.Src, event.Group) {
+				st.invalidate(responder)
+			}
+		}
+	}
+}
+
+// invalidate forgets the device's level
+func (st *StateTracker) invalidate(addr Address) {
+	st.mu.Lock()
+	delete(st.states, addr)
+	st.mu.Unlock()
+}
+
+func (st *StateTracker) update(addr Address, level int, source StateSource) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	previous, found := st.states[addr]
+	state := State{Address: addr, Level: level, Source: source, Updated: time.Now()}
+	st.states[addr] = state
+	if found && previous.Level == level {
+		return
+	}
+
+	Log.Debugf("State changed %v", state)
+	for _, ch := range st.listeners {
+		select {
+		case ch <- state:
+		default:
+			Log.Infof("State listener is full, dropping %v", state)
+		}
+	}
+}
+
+// AddLinks records the responder links from a device's all-link database.
+// When a controller in one of the links turns its group on, the device's
+// level is set to the on level stored in the link.  When the group is turned
+// off, the device's level is set to 0
+func (st *StateTracker) AddLinks(responder Address, links ...*LinkRecord) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, link := range links {
+		if link.Flags.InUse() && link.Flags.Responder() {
+			key := groupKey{link.Address, link.Group}
+			if st.onLevels[key] == nil {
+				st.onLevels[key] = make(map[Address]int)
+			}
+			st.onLevels[key][responder] = int(link.Data[0])
+		}
+	}
+}
+
+// GroupCommand updates the responders of the controller's group as though
+// the group had been turned on or off.  This is called automatically for
+// broadcasts seen on the bus, but must be called by the application for
+// group commands that the local modem sends, since those are not received
+func (st *StateTracker) GroupCommand(controller Address, group Group, on bool) {
+	for responder, level := range st.responders(controller, group) {
+		if !on {
+			level = 0
+		}
+		st.update(responder, level, SourceGroup)
+	}
+}
+
+// responders returns a copy of the on levels of the responders to the
+// controller's group
+func (st *StateTracker) responders(controller Address, group Group) map[Address]int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	responders := make(map[Address]int)
+	for responder, level := range st.onLevels[groupKey{controller, group}] {
+		responders[responder] = level
+	}
+	return responders
+}
+
+// State returns the last known state of the device.  The return value
+// indicates whether the device's state is known
+func (st *StateTracker) State(addr Address) (State, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	state, found := st.states[addr]
+	return state, found
+}
+
+// States returns the last known state of every tracked device
+func (st *StateTracker) States() []State {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	states := make([]State, 0, len(st.states))
+	for _, state := range st.states {
+		states = append(states, state)
+	}
+	return states
+}
+
+// Level returns the switch's last known level.  If the level is unknown,
+// or older than the max age, then the switch is sent a status request
+func (st *StateTracker) Level(sw Switch) (int, error) {
+	return st.LevelContext(context.Background(), sw)
+}
+
+// LevelContext is the same as Level except that the status request is
+// abandoned when the context is done
+func (st *StateTracker) LevelContext(ctx context.Context, sw Switch) (int, error) {
+	state, found := st.State(sw.Address())
+	if found && (st.maxAge == 0 || time.Since(state.Updated) < st.maxAge) {
+		return state.Level, nil
+	}
+
+	level, err := sw.StatusContext(ctx)
+	if err == nil {
+		st.update(sw.Address(), level, SourceStatus)
+	}
+	return level, err
+}
+
+// Subscribe returns a channel that receives the new state every time the
+// level of a device changes
+func (st *StateTracker) Subscribe() <-chan State {
+	ch := make(chan State, BusBufLen)
+	st.mu.Lock()
+	st.listeners[ch] = ch
+	st.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe stops delivery to, and closes, the given channel
+func (st *StateTracker) Unsubscribe(ch <-chan State) {
+	st.mu.Lock()
+	if listener, found := st.listeners[ch]; found {
+		close(listener)
+		delete(st.listeners, ch)
+	}
+	st.mu.Unlock()
+}
+
+// Close stops tracking state and closes every subscribed channel
+func (st *StateTracker) Close() {
+	st.bus.Unsubscribe(st.msgs)
+	<-st.done
+
+	st.mu.Lock()
+	for ch, listener := range st.listeners {
+		close(listener)
+		delete(st.listeners, ch)
+	}
+	st.mu.Unlock()
+}
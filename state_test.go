@@ -0,0 +1,260 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type statusSwitch struct {
+	Switch
+	addr   Address
+	level  int
+	called int
+}
+
+func (ss *statusSwitch) Address() Address { return ss.addr }
+
+func (ss *statusSwitch) StatusContext(context.Context) (int, error) {
+	ss.called++
+	return ss.level, nil
+}
+
+func TestStateSourceString(t *testing.T) {
+	tests := []struct {
+		input StateSource
+		want  string
+	}{
+		{SourceAck, "ack"},
+		{SourceBroadcast, "broadcast"},
+		{SourceGroup, "group"},
+		{SourceStatus, "status"},
+		{StateSource(42), "StateSource(42)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.String(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestStateTracker(t *testing.T) {
+	plm := Address{1, 1, 1}
+	controller := Address{1, 2, 3}
+	responder := Address{4, 5, 6}
+	other := Address{7, 8, 9}
+
+	link := ResponderLink(1, controller)
+	link.Data = [3]byte{0x40, 0x1c, 0x01}
+
+	tests := []struct {
+		desc    string
+		sent    *Message
+		onLevel int
+		input   *Message
+		want    []State
+	}{
+		{"on ack", eventMsg(MsgTypeDirect, plm, other, 0x11, 0x80), 0, eventMsg(MsgTypeDirectAck, other, plm, 0x11, 0x80), []State{{Address: other, Level: 0x80, Source: SourceAck}}},
+		{"instant change ack", eventMsg(MsgTypeDirect, plm, other, 0x21, 0x20), 0, eventMsg(MsgTypeDirectAck, other, plm, 0x21, 0x20), []State{{Address: other, Level: 0x20, Source: SourceAck}}},
+		{"off ack", eventMsg(MsgTypeDirect, plm, other, 0x13, 0x00), 0, eventMsg(MsgTypeDirectAck, other, plm, 0x13, 0x00), []State{{Address: other, Level: 0, Source: SourceAck}}},
+		{"status ack", eventMsg(MsgTypeDirect, plm, other, 0x19, 0x00), 0, eventMsg(MsgTypeDirectAck, other, plm, 0x11, 0x40), []State{{Address: other, Level: 0x40, Source: SourceStatus}}},
+		{"led status ack", eventMsg(MsgTypeDirect, plm, other, 0x19, 0x01), 0, eventMsg(MsgTypeDirectAck, other, plm, 0x11, 0x0f), nil},
+		{"unsolicited ack", nil, 0, eventMsg(MsgTypeDirectAck, other, plm, 0x11, 0x80), nil},
+		{"ack from other device", eventMsg(MsgTypeDirect, plm, controller, 0x11, 0x80), 0, eventMsg(MsgTypeDirectAck, other, plm, 0x11, 0x80), nil},
+		{"nak", eventMsg(MsgTypeDirect, plm, other, 0x11, 0x80), 0, eventMsg(MsgTypeDirectNak, other, plm, 0x11, 0xff), nil},
+		{"group on", nil, 0, eventMsg(MsgTypeAllLinkBroadcast, controller, Address{0, 0, 1}, 0x11, 0x00), []State{{Address: controller, Level: 255, Source: SourceBroadcast}, {Address: responder, Level: 0x40, Source: SourceGroup}}},
+		{"group on configured level", nil, 0x80, eventMsg(MsgTypeAllLinkBroadcast, controller, Address{0, 0, 1}, 0x11, 0x00), []State{{Address: controller, Level: 0x80, Source: SourceBroadcast}, {Address: responder, Level: 0x40, Source: SourceGroup}}},
+		{"group fast on", nil, 0x80, eventMsg(MsgTypeAllLinkBroadcast, controller, Address{0, 0, 1}, 0x12, 0x00), []State{{Address: controller, Level: 255, Source: SourceBroadcast}, {Address: responder, Level: 0x40, Source: SourceGroup}}},
+		{"group off cleanup", nil, 0, eventMsg(MsgTypeAllLinkCleanup, controller, plm, 0x13, 0x01), []State{{Address: controller, Level: 0, Source: SourceBroadcast}, {Address: responder, Level: 0, Source: SourceGroup}}},
+		{"other group", nil, 0, eventMsg(MsgTypeAllLinkBroadcast, controller, Address{0, 0, 2}, 0x11, 0x00), nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			bus := NewBus()
+			defer bus.Close()
+			st, _ := NewStateTracker(bus)
+			defer st.Close()
+			st.AddLinks(responder, link, ControllerLink(2, controller))
+			if test.onLevel != 0 {
+				st.SetOnLevel(controller, test.onLevel)
+			}
+			if test.sent != nil {
+				st.Sent(test.sent)
+			}
+			ch := st.Subscribe()
+
+			bus.Publish(test.input)
+			for _, want := range test.want {
+				select {
+				case got := <-ch:
+					if got.Address != want.Address || got.Level != want.Level || got.Source != want.Source {
+						t.Errorf("want %v got %v", want, got)
+					}
+					if state, _ := st.State(want.Address); state != got {
+						t.Errorf("want state %v got %v", got, state)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("timed out waiting for %v", want)
+				}
+			}
+
+			select {
+			case got := <-ch:
+				t.Errorf("unexpected state %v", got)
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			if len(st.States()) != len(test.want) {
+				t.Errorf("want %d states got %v", len(test.want), st.States())
+			}
+		})
+	}
+}
+
+func TestStateTrackerManualChange(t *testing.T) {
+	controller := Address{1, 2, 3}
+	responder := Address{4, 5, 6}
+	other := Address{7, 8, 9}
+
+	tests := []struct {
+		desc  string
+		input *Message
+	}{
+		{"start brighten", eventMsg(MsgTypeAllLinkBroadcast, controller, Address{0, 0, 1}, 0x17, 0x01)},
+		{"start dim", eventMsg(MsgTypeAllLinkBroadcast, controller, Address{0, 0, 1}, 0x17, 0x00)},
+		{"stop change", eventMsg(MsgTypeAllLinkBroadcast, controller, Address{0, 0, 1}, 0x18, 0x00)},
+		{"stop change cleanup", eventMsg(MsgTypeAllLinkCleanup, controller, Address{}, 0x18, 0x01)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			bus := NewBus()
+			defer bus.Close()
+			st, _ := NewStateTracker(bus)
+			defer st.Close()
+			st.AddLinks(responder, ResponderLink(1, controller))
+			st.update(controller, 0x80, SourceAck)
+			st.update(responder, 0x80, SourceGroup)
+			ch := st.Subscribe()
+
+			bus.Publish(test.input)
+			// messages are received in order, so once the state of the
+			// other device changes the manual change has been seen
+			bus.Publish(eventMsg(MsgTypeAllLinkBroadcast, other, Address{0, 0, 1}, 0x11, 0x00))
+			select {
+			case <-ch:
+			case <-time.After(time.Second):
+				t.Fatalf("timed out waiting for state")
+			}
+
+			for _, addr := range []Address{controller, responder} {
+				if state, found := st.State(addr); found {
+					t.Errorf("want %v to be unknown got %v", addr, state)
+				}
+
+				sw := &statusSwitch{addr: addr, level: 42}
+				if level, _ := st.Level(sw); level != 42 || sw.called != 1 {
+					t.Errorf("want level 42 from 1 status request got %d from %d", level, sw.called)
+				}
+			}
+		})
+	}
+}
+
+func TestStateTrackerUnchanged(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+	st, _ := NewStateTracker(bus)
+	ch := st.Subscribe()
+
+	src := Address{1, 2, 3}
+	st.Sent(eventMsg(MsgTypeDirect, Address{}, src, 0x11, 0xff))
+	st.Sent(eventMsg(MsgTypeDirect, Address{}, src, 0x13, 0x00))
+	bus.Publish(eventMsg(MsgTypeDirectAck, src, Address{}, 0x11, 0xff))
+	bus.Publish(eventMsg(MsgTypeAllLinkBroadcast, src, Address{0, 0, 1}, 0x11, 0x00))
+	bus.Publish(eventMsg(MsgTypeDirectAck, src, Address{}, 0x13, 0x00))
+
+	for _, want := range []int{255, 0} {
+		select {
+		case got := <-ch:
+			if got.Level != want {
+				t.Errorf("want level %d got %v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for level %d", want)
+		}
+	}
+
+	st.Close()
+	if _, open := <-ch; open {
+		t.Errorf("expected channel to be closed")
+	}
+}
+
+func TestStateTrackerLevel(t *testing.T) {
+	tests := []struct {
+		desc       string
+		maxAge     time.Duration
+		known      bool
+		wantLevel  int
+		wantCalled int
+	}{
+		{"unknown", 0, false, 42, 1},
+		{"cached", 0, true, 255, 0},
+		{"fresh", time.Hour, true, 255, 0},
+		{"stale", time.Nanosecond, true, 42, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			bus := NewBus()
+			defer bus.Close()
+			st, err := NewStateTracker(bus, StateMaxAge(test.maxAge))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer st.Close()
+
+			sw := &statusSwitch{addr: Address{1, 2, 3}, level: 42}
+			if test.known {
+				st.update(sw.addr, 255, SourceAck)
+				time.Sleep(time.Millisecond)
+			}
+
+			level, err := st.Level(sw)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if level != test.wantLevel || sw.called != test.wantCalled {
+				t.Errorf("want level %d (%d status requests) got %d (%d status requests)", test.wantLevel, test.wantCalled, level, sw.called)
+			}
+
+			if state, _ := st.State(sw.addr); state.Level != test.wantLevel {
+				t.Errorf("want cached level %d got %v", test.wantLevel, state)
+			}
+		})
+	}
+
+	bus := NewBus()
+	defer bus.Close()
+	if _, err := NewStateTracker(bus, StateMaxAge(-time.Second)); err == nil {
+		t.Errorf("expected an error for a negative max age")
+	}
+}
@@ -15,6 +15,7 @@
 package insteon
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -91,6 +92,26 @@ type Switch interface {
 
 	// SwitchConfig queries the device and returns the returned configuration
 	SwitchConfig() (SwitchConfig, error)
+
+	// OnContext is On that stops waiting for the device when the
+	// context is done
+	OnContext(ctx context.Context) error
+
+	// OffContext is Off that stops waiting for the device when the
+	// context is done
+	OffContext(ctx context.Context) error
+
+	// StatusContext is Status that stops waiting for the device when
+	// the context is done
+	StatusContext(ctx context.Context) (level int, err error)
+
+	// OperatingFlagsContext is OperatingFlags that stops querying the
+	// device when the context is done
+	OperatingFlagsContext(ctx context.Context) (LightFlags, error)
+
+	// SwitchConfigContext is SwitchConfig that stops waiting for the
+	// device when the context is done
+	SwitchConfigContext(ctx context.Context) (SwitchConfig, error)
 }
 
 // LinkableSwitch represents a switch that contains an Insteon version 2
@@ -146,6 +167,7 @@ func (lf LightFlags) CleanupReport() bool { return lf[4]&0x04 == 0x04 }
 type switchedDevice struct {
 	Device
 	timeout time.Duration
+	name    string
 }
 
 type linkableSwitch struct {
@@ -157,21 +179,37 @@ type linkableSwitch struct {
 // NewSwitch is a factory function that will return the correctly
 // configured switch based on the underlying device
 func NewSwitch(device Device, timeout time.Duration) Switch {
-	sw := &switchedDevice{Device: device, timeout: timeout}
+	return newSwitch(device, timeout, "Switch")
+}
+
+func newSwitch(device Device, timeout time.Duration, name string) Switch {
+	sw := &switchedDevice{Device: device, timeout: timeout, name: name}
 	if linkable, ok := device.(LinkableDevice); ok {
 		return &linkableSwitch{LinkableDevice: linkable, switchedDevice: sw}
 	}
 	return sw
 }
 
-func (sd *switchedDevice) On() error  { return extractError(sd.SendCommand(CmdLightOn, nil)) }
-func (sd *switchedDevice) Off() error { return extractError(sd.SendCommand(CmdLightOff, nil)) }
+func (sd *switchedDevice) On() error  { return sd.OnContext(context.Background()) }
+func (sd *switchedDevice) Off() error { return sd.OffContext(context.Background()) }
+
+func (sd *switchedDevice) OnContext(ctx context.Context) error {
+	return extractError(sd.SendCommandContext(ctx, CmdLightOn, nil))
+}
+
+func (sd *switchedDevice) OffContext(ctx context.Context) error {
+	return extractError(sd.SendCommandContext(ctx, CmdLightOff, nil))
+}
 
 // Status sends a LightStatusRequest to determine the device's current
 // level. For switched devices this is either 0 or 255, dimmable devices
 // will be the current dim level between 0 and 255
 func (sd *switchedDevice) Status() (level int, err error) {
-	response, err := sd.SendCommand(CmdLightStatusRequest, nil)
+	return sd.StatusContext(context.Background())
+}
+
+func (sd *switchedDevice) StatusContext(ctx context.Context) (level int, err error) {
+	response, err := sd.SendCommandContext(ctx, CmdLightStatusRequest, nil)
 	if err == nil {
 		level = int(response[2])
 	}
@@ -179,7 +217,7 @@ func (sd *switchedDevice) Status() (level int, err error) {
 }
 
 func (sd *switchedDevice) String() string {
-	return fmt.Sprintf("Switch (%s)", sd.Address())
+	return fmt.Sprintf("%s (%s)", sd.name, sd.Address())
 }
 
 func (sd *switchedDevice) SetX10Address(button int, houseCode, unitCode byte) error {
@@ -187,10 +225,14 @@ func (sd *switchedDevice) SetX10Address(button int, houseCode, unitCode byte) er
 }
 
 func (sd *switchedDevice) SwitchConfig() (config SwitchConfig, err error) {
+	return sd.SwitchConfigContext(context.Background())
+}
+
+func (sd *switchedDevice) SwitchConfigContext(ctx context.Context) (config SwitchConfig, err error) {
 	// SEE DimmerConfig() notes for explanation of D1 and D2 (payload[0] and payload[1])
-	_, err = sd.Device.SendCommand(CmdExtendedGetSet, []byte{0x00, 0x00})
+	_, err = sd.Device.SendCommandContext(ctx, CmdExtendedGetSet, []byte{0x00, 0x00})
 	if err == nil {
-		err = Receive(sd, sd.timeout, func(msg *Message) error {
+		err = ReceiveContext(ctx, sd, sd.timeout, func(msg *Message) error {
 			if msg.Command == CmdExtendedGetSet {
 				err = config.UnmarshalBinary(msg.Payload)
 				if err == nil {
@@ -217,6 +259,10 @@ func (sd *switchedDevice) SetLoadSense(flag bool) error   { return sd.setOperati
 func (sd *switchedDevice) SetLED(flag bool) error         { return sd.setOperatingFlags(8, !flag) }
 
 func (sd *switchedDevice) OperatingFlags() (flags LightFlags, err error) {
+	return sd.OperatingFlagsContext(context.Background())
+}
+
+func (sd *switchedDevice) OperatingFlagsContext(ctx context.Context) (flags LightFlags, err error) {
 	commands := []Command{
 		CmdGetOperatingFlags.SubCommand(0x00),
 		CmdGetOperatingFlags.SubCommand(0x01),
@@ -226,7 +272,7 @@ func (sd *switchedDevice) OperatingFlags() (flags LightFlags, err error) {
 	}
 
 	for i := 0; i < len(commands) && err == nil; i++ {
-		commands[i], err = sd.SendCommand(commands[i], nil)
+		commands[i], err = sd.SendCommandContext(ctx, commands[i], nil)
 		flags[i] = commands[i][2]
 	}
 	return
@@ -0,0 +1,398 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func init() {
+	Devices.Register(0x05, thermostatFactory)
+}
+
+func thermostatFactory(info DeviceInfo, device Device, timeout time.Duration) (Device, error) {
+	return newThermostat(device, timeout, productName(info.DevCat, "Thermostat")), nil
+}
+
+// ThermostatMode is the system mode of a thermostat
+type ThermostatMode int
+
+// Thermostat system modes
+const (
+	ModeOff ThermostatMode = iota
+	ModeAuto
+	ModeHeat
+	ModeCool
+	ModeProgram
+)
+
+func (tm ThermostatMode) String() string {
+	switch tm {
+	case ModeOff:
+		return "Off"
+	case ModeAuto:
+		return "Auto"
+	case ModeHeat:
+		return "Heat"
+	case ModeCool:
+		return "Cool"
+	case ModeProgram:
+		return "Program"
+	}
+	return fmt.Sprintf("ThermostatMode(%d)", int(tm))
+}
+
+// FanMode indicates whether the thermostat runs the fan continuously
+// or only while heating or cooling
+type FanMode int
+
+// Thermostat fan modes
+const (
+	FanAuto FanMode = iota
+	FanOn
+)
+
+func (fm FanMode) String() string {
+	switch fm {
+	case FanAuto:
+		return "Auto"
+	case FanOn:
+		return "On"
+	}
+	return fmt.Sprintf("FanMode(%d)", int(fm))
+}
+
+// thermostatModes and fanModes map the modes to the command 2 byte of the
+// thermostat control command
+var (
+	thermostatModes = map[ThermostatMode]int{
+		ModeHeat:    0x04,
+		ModeCool:    0x05,
+		ModeAuto:    0x06,
+		ModeOff:     0x09,
+		ModeProgram: 0x0c,
+	}
+
+	fanModes = map[FanMode]int{
+		FanOn:   0x07,
+		FanAuto: 0x08,
+	}
+)
+
+// thermostatActions maps the command 1 byte of the direct messages
+// thermostats send when their status changes to their actions
+var thermostatActions = map[byte]Action{
+	CmdTemperatureChange[1]:  ActionTemperatureChange,
+	CmdHumidityChange[1]:     ActionHumidityChange,
+	CmdModeChange[1]:         ActionModeChange,
+	CmdCoolSetpointChange[1]: ActionCoolSetpointChange,
+	CmdHeatSetpointChange[1]: ActionHeatSetpointChange,
+}
+
+// ThermostatFlags indicate the current state of the thermostat
+type ThermostatFlags byte
+
+// Cooling indicates the cooling equipment is running
+func (tf ThermostatFlags) Cooling() bool { return tf&0x01 == 0x01 }
+
+// Heating indicates the heating equipment is running
+func (tf ThermostatFlags) Heating() bool { return tf&0x02 == 0x02 }
+
+// EnergySaving indicates the thermostat is in energy saving mode
+func (tf ThermostatFlags) EnergySaving() bool { return tf&0x04 == 0x04 }
+
+// Celsius indicates the thermostat displays (and the setpoints are) in Celsius
+func (tf ThermostatFlags) Celsius() bool { return tf&0x08 == 0x08 }
+
+// Hold indicates the thermostat is holding the current setpoints
+func (tf ThermostatFlags) Hold() bool { return tf&0x10 == 0x10 }
+
+// ThermostatStatus is the information returned by the thermostat's
+// extended status command
+type ThermostatStatus struct {
+	// Day, Hour, Minute and Second are the thermostat's clock
+	Day    time.Weekday
+	Hour   int
+	Minute int
+	Second int
+
+	Mode    ThermostatMode
+	FanMode FanMode
+
+	// CoolSetpoint and HeatSetpoint are in the thermostat's
+	// display units (see Flags.Celsius)
+	CoolSetpoint int
+	HeatSetpoint int
+
+	// Humidity is the relative humidity (percent)
+	Humidity int
+
+	// Temperature is the ambient temperature in degrees Celsius
+	Temperature float64
+
+	Flags ThermostatFlags
+}
+
+// Fahrenheit returns the ambient temperature in degrees Fahrenheit
+func (ts *ThermostatStatus) Fahrenheit() float64 {
+	return ts.Temperature*9/5 + 32
+}
+
+// thermostatStatusResponse is the D1 selector of the extended status
+// response
+const thermostatStatusResponse = 0x01
+
+// UnmarshalBinary will parse the extended status payload into the receiver.
+// The first byte (D1) of the payload is the selector, the status begins
+// with the second byte
+func (ts *ThermostatStatus) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 14 {
+		return ErrBufferTooShort
+	}
+	ts.Day = time.Weekday(buf[1])
+	ts.Hour = int(buf[2])
+	ts.Minute = int(buf[3])
+	ts.Second = int(buf[4])
+	ts.Mode = ThermostatMode(buf[5] >> 4)
+	ts.FanMode = FanMode(buf[5] & 0x0f)
+	ts.CoolSetpoint = int(buf[6])
+	ts.Humidity = int(buf[7])
+	ts.Temperature = float64(int(buf[8])<<8|int(buf[9])) / 10
+	ts.Flags = ThermostatFlags(buf[10])
+	ts.HeatSetpoint = int(buf[11])
+	return nil
+}
+
+// MarshalBinary will convert the ThermostatStatus receiver to an
+// extended status payload
+func (ts *ThermostatStatus) MarshalBinary() ([]byte, error) {
+	temperature := int(ts.Temperature*10 + 0.5)
+	buf := make([]byte, 14)
+	buf[0] = thermostatStatusResponse
+	buf[1] = byte(ts.Day)
+	buf[2] = byte(ts.Hour)
+	buf[3] = byte(ts.Minute)
+	buf[4] = byte(ts.Second)
+	buf[5] = byte(ts.Mode)<<4 | byte(ts.FanMode)&0x0f
+	buf[6] = byte(ts.CoolSetpoint)
+	buf[7] = byte(ts.Humidity)
+	buf[8] = byte(temperature >> 8)
+	buf[9] = byte(temperature)
+	buf[10] = byte(ts.Flags)
+	buf[11] = byte(ts.HeatSetpoint)
+	return buf, nil
+}
+
+// Thermostat is any device that satisfies the following interface.  Thermostats
+// report changes to their status (temperature, humidity, mode and setpoints)
+// with direct messages that DecodeMessage decodes into Events
+type Thermostat interface {
+	Device
+
+	// DecodeMessage decodes the direct messages the thermostat sends
+	// when its status changes.  The event's Level is the new value.  The
+	// return value indicates whether the message was a status change
+	// sent by this thermostat
+	DecodeMessage(msg *Message) (*Event, bool)
+
+	// Status queries the thermostat for the ambient temperature, humidity,
+	// setpoints and modes
+	Status() (ThermostatStatus, error)
+
+	// SetMode changes the system mode
+	SetMode(mode ThermostatMode) error
+
+	// SetFanMode changes the fan mode
+	SetFanMode(mode FanMode) error
+
+	// SetCoolSetpoint sets the cooling setpoint (in the thermostat's
+	// display units).  ErrIllegalValue is returned for setpoints outside
+	// of 0 to 127
+	SetCoolSetpoint(temperature int) error
+
+	// SetHeatSetpoint sets the heating setpoint (in the thermostat's
+	// display units).  ErrIllegalValue is returned for setpoints outside
+	// of 0 to 127
+	SetHeatSetpoint(temperature int) error
+
+	// SetClock sets the thermostat's day of the week and time
+	SetClock(t time.Time) error
+
+	// StatusContext is Status that stops waiting for the device when
+	// the context is done
+	StatusContext(ctx context.Context) (ThermostatStatus, error)
+
+	// SetModeContext is SetMode that stops waiting for the device when
+	// the context is done
+	SetModeContext(ctx context.Context, mode ThermostatMode) error
+
+	// SetFanModeContext is SetFanMode that stops waiting for the device
+	// when the context is done
+	SetFanModeContext(ctx context.Context, mode FanMode) error
+
+	// SetCoolSetpointContext is SetCoolSetpoint that stops waiting for
+	// the device when the context is done
+	SetCoolSetpointContext(ctx context.Context, temperature int) error
+
+	// SetHeatSetpointContext is SetHeatSetpoint that stops waiting for
+	// the device when the context is done
+	SetHeatSetpointContext(ctx context.Context, temperature int) error
+
+	// SetClockContext is SetClock that stops waiting for the device when
+	// the context is done
+	SetClockContext(ctx context.Context, t time.Time) error
+}
+
+// LinkableThermostat represents a Thermostat that supports remote
+// linking (Insteon Engine version 2 or higher)
+type LinkableThermostat interface {
+	Thermostat
+	Linkable
+}
+
+type thermostat struct {
+	Device
+	timeout time.Duration
+	name    string
+}
+
+type linkableThermostat struct {
+	LinkableDevice
+	*thermostat
+}
+
+// NewThermostat is a factory function that will return the correctly
+// configured thermostat based on the underlying device
+func NewThermostat(device Device, timeout time.Duration) Thermostat {
+	return newThermostat(device, timeout, "Thermostat")
+}
+
+func newThermostat(device Device, timeout time.Duration, name string) Thermostat {
+	th := &thermostat{Device: device, timeout: timeout, name: name}
+	if linkable, ok := device.(LinkableDevice); ok {
+		return &linkableThermostat{LinkableDevice: linkable, thermostat: th}
+	}
+	return th
+}
+
+// control sends a thermostat command.  Thermostat commands are sent as
+// extended messages since I2CS thermostats ignore the standard versions
+func (th *thermostat) control(ctx context.Context, cmd Command, payload []byte) error {
+	buf := make([]byte, 14)
+	copy(buf, payload)
+	_, err := th.SendCommandContext(ctx, cmd, buf)
+	return err
+}
+
+func (th *thermostat) DecodeMessage(msg *Message) (*Event, bool) {
+	if msg.Src != th.Address() || msg.Flags.Type() != MsgTypeDirect || msg.Flags.Extended() {
+		return nil, false
+	}
+
+	if action, found := thermostatActions[msg.Command[1]]; found {
+		return &Event{Src: msg.Src, Action: action, Level: int(msg.Command[2])}, true
+	}
+	return nil, false
+}
+
+func (th *thermostat) Status() (ThermostatStatus, error) {
+	return th.StatusContext(context.Background())
+}
+
+func (th *thermostat) StatusContext(ctx context.Context) (status ThermostatStatus, err error) {
+	err = th.control(ctx, CmdThermostatGetSet, nil)
+	if err == nil {
+		err = ReceiveContext(ctx, th, th.timeout, func(msg *Message) error {
+			if msg.Command == CmdThermostatGetSet {
+				err = status.UnmarshalBinary(msg.Payload)
+				if err == nil {
+					err = ErrReceiveComplete
+				}
+			}
+			return err
+		})
+	}
+	return status, err
+}
+
+func (th *thermostat) SetMode(mode ThermostatMode) error {
+	return th.SetModeContext(context.Background(), mode)
+}
+
+func (th *thermostat) SetModeContext(ctx context.Context, mode ThermostatMode) error {
+	cmd2, found := thermostatModes[mode]
+	if !found {
+		return ErrIllegalValue
+	}
+	return th.control(ctx, CmdThermostatControl.SubCommand(cmd2), nil)
+}
+
+func (th *thermostat) SetFanMode(mode FanMode) error {
+	return th.SetFanModeContext(context.Background(), mode)
+}
+
+func (th *thermostat) SetFanModeContext(ctx context.Context, mode FanMode) error {
+	cmd2, found := fanModes[mode]
+	if !found {
+		return ErrIllegalValue
+	}
+	return th.control(ctx, CmdThermostatControl.SubCommand(cmd2), nil)
+}
+
+// checkSetpoint makes sure the setpoint can be sent in command 2, which
+// carries twice the temperature
+func checkSetpoint(temperature int) error {
+	if temperature < 0 || 127 < temperature {
+		return ErrIllegalValue
+	}
+	return nil
+}
+
+func (th *thermostat) SetCoolSetpoint(temperature int) error {
+	return th.SetCoolSetpointContext(context.Background(), temperature)
+}
+
+func (th *thermostat) SetCoolSetpointContext(ctx context.Context, temperature int) error {
+	if err := checkSetpoint(temperature); err != nil {
+		return err
+	}
+	return th.control(ctx, CmdSetCoolSetpoint.SubCommand(temperature*2), nil)
+}
+
+func (th *thermostat) SetHeatSetpoint(temperature int) error {
+	return th.SetHeatSetpointContext(context.Background(), temperature)
+}
+
+func (th *thermostat) SetHeatSetpointContext(ctx context.Context, temperature int) error {
+	if err := checkSetpoint(temperature); err != nil {
+		return err
+	}
+	return th.control(ctx, CmdSetHeatSetpoint.SubCommand(temperature*2), nil)
+}
+
+func (th *thermostat) SetClock(t time.Time) error {
+	return th.SetClockContext(context.Background(), t)
+}
+
+func (th *thermostat) SetClockContext(ctx context.Context, t time.Time) error {
+	// D1 0x02 selects setting the clock
+	return th.control(ctx, CmdThermostatGetSet, []byte{0x02, byte(t.Weekday()), byte(t.Hour()), byte(t.Minute()), byte(t.Second())})
+}
+
+func (th *thermostat) String() string {
+	return fmt.Sprintf("%s (%s)", th.name, th.Address())
+}
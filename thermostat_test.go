@@ -0,0 +1,215 @@
+// Copyright 2019 Andrew Bates
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package insteon
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestThermostatFactory(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input Device
+		want  reflect.Type
+	}{
+		{"Thermostat", &i1Device{}, reflect.TypeOf(&thermostat{})},
+		{"Linkable Thermostat", &i2Device{}, reflect.TypeOf(&linkableThermostat{})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := reflect.TypeOf(NewThermostat(test.input, 0))
+			if test.want != got {
+				t.Errorf("want type %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestThermostatRegistry(t *testing.T) {
+	device, err := Devices.New(DeviceInfo{DevCat: DevCat{0x05, 0x0b}, EngineVersion: VerI2Cs}, &testConnection{addr: Address{1, 2, 3}}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := device.(LinkableThermostat); !ok {
+		t.Errorf("want LinkableThermostat got %T", device)
+	}
+
+	if want, got := "2441TH Thermostat (01.02.03)", fmt.Sprintf("%v", device); want != got {
+		t.Errorf("want %q got %q", want, got)
+	}
+}
+
+func TestThermostatModeString(t *testing.T) {
+	tests := []struct {
+		input interface{ String() string }
+		want  string
+	}{
+		{ModeOff, "Off"},
+		{ModeAuto, "Auto"},
+		{ModeHeat, "Heat"},
+		{ModeCool, "Cool"},
+		{ModeProgram, "Program"},
+		{ThermostatMode(42), "ThermostatMode(42)"},
+		{FanAuto, "Auto"},
+		{FanOn, "On"},
+		{FanMode(42), "FanMode(42)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.input.String(); got != test.want {
+				t.Errorf("want %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestThermostatStatus(t *testing.T) {
+	tests := []struct {
+		desc    string
+		input   []byte
+		want    ThermostatStatus
+		wantErr error
+	}{
+		{"heating", mkPayload(0x01, 0x02, 13, 45, 10, 0x21, 78, 40, 0x00, 0xd7, 0x02, 68), ThermostatStatus{Day: time.Tuesday, Hour: 13, Minute: 45, Second: 10, Mode: ModeHeat, FanMode: FanOn, CoolSetpoint: 78, Humidity: 40, Temperature: 21.5, Flags: 0x02, HeatSetpoint: 68}, nil},
+		{"cooling celsius", mkPayload(0x01, 0x06, 23, 59, 59, 0x30, 24, 55, 0x01, 0x04, 0x09, 18), ThermostatStatus{Day: time.Saturday, Hour: 23, Minute: 59, Second: 59, Mode: ModeCool, FanMode: FanAuto, CoolSetpoint: 24, Humidity: 55, Temperature: 26, Flags: 0x09, HeatSetpoint: 18}, nil},
+		{"short buffer", nil, ThermostatStatus{}, ErrBufferTooShort},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := ThermostatStatus{}
+			err := got.UnmarshalBinary(test.input)
+			if err != test.wantErr {
+				t.Fatalf("want error %v got %v", test.wantErr, err)
+			} else if err != nil {
+				return
+			}
+
+			if got != test.want {
+				t.Errorf("want %+v got %+v", test.want, got)
+			}
+
+			buf, _ := got.MarshalBinary()
+			if !reflect.DeepEqual(test.input, buf) {
+				t.Errorf("want %x got %x", test.input, buf)
+			}
+		})
+	}
+}
+
+func TestThermostatFlags(t *testing.T) {
+	flags := ThermostatFlags(0x1d)
+	if !flags.Cooling() || flags.Heating() || !flags.EnergySaving() || !flags.Celsius() || !flags.Hold() {
+		t.Errorf("flags %02x decoded incorrectly", byte(flags))
+	}
+
+	status := ThermostatStatus{Temperature: 20}
+	if got := status.Fahrenheit(); got != 68 {
+		t.Errorf("want 68 got %v", got)
+	}
+}
+
+func TestThermostatCommands(t *testing.T) {
+	clock := time.Date(2019, 3, 14, 15, 9, 26, 0, time.UTC)
+	tests := []*commandTest{
+		{"SetMode", func(d Device) error { return d.(Thermostat).SetMode(ModeCool) }, CmdThermostatControl.SubCommand(0x05), nil, make([]byte, 14)},
+		{"SetMode Off", func(d Device) error { return d.(Thermostat).SetMode(ModeOff) }, CmdThermostatControl.SubCommand(0x09), nil, make([]byte, 14)},
+		{"SetFanMode", func(d Device) error { return d.(Thermostat).SetFanMode(FanOn) }, CmdThermostatControl.SubCommand(0x07), nil, make([]byte, 14)},
+		{"SetCoolSetpoint", func(d Device) error { return d.(Thermostat).SetCoolSetpoint(76) }, CmdSetCoolSetpoint.SubCommand(152), nil, make([]byte, 14)},
+		{"SetHeatSetpoint", func(d Device) error { return d.(Thermostat).SetHeatSetpoint(68) }, CmdSetHeatSetpoint.SubCommand(136), nil, make([]byte, 14)},
+		{"SetClock", func(d Device) error { return d.(Thermostat).SetClock(clock) }, CmdThermostatGetSet, nil, mkPayload(0x02, 0x04, 15, 9, 26)},
+	}
+
+	testDeviceCommands(t, func(conn *testConnection) Device {
+		return NewThermostat(conn, time.Nanosecond)
+	}, tests)
+
+	for _, input := range []error{
+		NewThermostat(&testConnection{}, 0).SetMode(ThermostatMode(42)),
+		NewThermostat(&testConnection{}, 0).SetFanMode(FanMode(42)),
+		NewThermostat(&testConnection{}, 0).SetCoolSetpoint(-1),
+		NewThermostat(&testConnection{}, 0).SetCoolSetpoint(128),
+		NewThermostat(&testConnection{}, 0).SetHeatSetpoint(-1),
+		NewThermostat(&testConnection{}, 0).SetHeatSetpoint(128),
+	} {
+		if input != ErrIllegalValue {
+			t.Errorf("want error %v got %v", ErrIllegalValue, input)
+		}
+	}
+}
+
+func TestThermostatDecodeMessage(t *testing.T) {
+	src := Address{1, 2, 3}
+	plm := Address{7, 8, 9}
+	extended := eventMsg(MsgTypeDirect, src, plm, 0x6e, 0x8c)
+	extended.Flags = ExtendedDirectMessage
+
+	tests := []struct {
+		desc  string
+		input *Message
+		want  *Event
+	}{
+		{"temperature change", eventMsg(MsgTypeDirect, src, plm, 0x6e, 0x8c), &Event{src, 0, ActionTemperatureChange, 0x8c}},
+		{"humidity change", eventMsg(MsgTypeDirect, src, plm, 0x6f, 0x2a), &Event{src, 0, ActionHumidityChange, 0x2a}},
+		{"mode change", eventMsg(MsgTypeDirect, src, plm, 0x70, 0x12), &Event{src, 0, ActionModeChange, 0x12}},
+		{"cool setpoint change", eventMsg(MsgTypeDirect, src, plm, 0x71, 0x4e), &Event{src, 0, ActionCoolSetpointChange, 0x4e}},
+		{"heat setpoint change", eventMsg(MsgTypeDirect, src, plm, 0x72, 0x44), &Event{src, 0, ActionHeatSetpointChange, 0x44}},
+		{"other device", eventMsg(MsgTypeDirect, Address{4, 5, 6}, plm, 0x6e, 0x8c), nil},
+		{"ack", eventMsg(MsgTypeDirectAck, src, plm, 0x6e, 0x8c), nil},
+		{"extended", extended, nil},
+		{"other command", eventMsg(MsgTypeDirect, src, plm, 0x11, 0xff), nil},
+	}
+
+	th := NewThermostat(&testConnection{addr: src}, 0)
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, found := th.DecodeMessage(test.input)
+			if found != (test.want != nil) {
+				t.Fatalf("want found %v got %v", test.want != nil, found)
+			}
+
+			if !reflect.DeepEqual(test.want, got) {
+				t.Errorf("want %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestThermostatStatusRequest(t *testing.T) {
+	conn := &testConnection{recvCh: make(chan *Message, 1), sendCh: make(chan *Message, 1), ackCh: make(chan *Message, 1)}
+	th := NewThermostat(conn, time.Millisecond)
+	want := ThermostatStatus{Day: time.Friday, Hour: 7, Mode: ModeAuto, CoolSetpoint: 76, HeatSetpoint: 68, Humidity: 45, Temperature: 22}
+	payload, _ := want.MarshalBinary()
+	conn.recvCh <- &Message{Command: CmdThermostatGetSet, Payload: payload}
+	conn.ackCh <- TestAck
+
+	got, err := th.Status()
+	sent := <-conn.sendCh
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if got != want {
+		t.Errorf("want status %+v got %+v", want, got)
+	}
+
+	if sent.Command != CmdThermostatGetSet || !reflect.DeepEqual(make([]byte, 14), sent.Payload) {
+		t.Errorf("want status request got %v % x", sent.Command, sent.Payload)
+	}
+}
@@ -217,7 +217,21 @@ func Link(group insteon.Group, controller, responder insteon.AddressableLinkable
 	return err
 }
 
+// DeviceFinder looks up the information known about a device
+// (such as an insteon.ProductDatabase)
+type DeviceFinder interface {
+	Find(address insteon.Address) (insteon.DeviceInfo, bool)
+}
+
+// PrintLinks writes the link database of the linkable to out
 func PrintLinks(out io.Writer, linkable insteon.Linkable) error {
+	return PrintLinksWithDevices(out, linkable, nil)
+}
+
+// PrintLinksWithDevices writes the link database of the linkable to out.  The
+// product (from insteon.Products) of each linked device found in devices is
+// printed alongside the link
+func PrintLinksWithDevices(out io.Writer, linkable insteon.Linkable, devices DeviceFinder) error {
 	dbLinks, err := linkable.Links()
 	fmt.Fprintf(out, "Link Database:\n")
 	if len(dbLinks) > 0 {
@@ -236,7 +250,13 @@ func PrintLinks(out io.Writer, linkable insteon.Linkable) error {
 
 		for _, linkAddress := range linkAddresses {
 			for _, link := range links[linkAddress] {
-				fmt.Fprintf(out, "    %-5s %5s %8s   %02x %02x %02x\n", link.Flags, link.Group, link.Address, link.Data[0], link.Data[1], link.Data[2])
+				fmt.Fprintf(out, "    %-5s %5s %8s   %02x %02x %02x", link.Flags, link.Group, link.Address, link.Data[0], link.Data[1], link.Data[2])
+				if devices != nil {
+					if info, found := devices.Find(link.Address); found && info.DevCat != (insteon.DevCat{}) {
+						fmt.Fprintf(out, "   %s", insteon.Products.Describe(info.DevCat))
+					}
+				}
+				fmt.Fprintf(out, "\n")
 			}
 		}
 	} else {
@@ -1,6 +1,9 @@
 package util
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -21,6 +24,15 @@ func (tl *testLinkable) EnterLinkingMode(insteon.Group) error     { return nil }
 func (tl *testLinkable) EnterUnlinkingMode(insteon.Group) error   { return nil }
 func (tl *testLinkable) ExitLinkingMode() error                   { return nil }
 
+func (tl *testLinkable) LinksContext(context.Context) ([]*insteon.LinkRecord, error) {
+	return tl.Links()
+}
+func (tl *testLinkable) WriteLinksContext(context.Context, ...*insteon.LinkRecord) error  { return nil }
+func (tl *testLinkable) UpdateLinksContext(context.Context, ...*insteon.LinkRecord) error { return nil }
+func (tl *testLinkable) EnterLinkingModeContext(context.Context, insteon.Group) error     { return nil }
+func (tl *testLinkable) EnterUnlinkingModeContext(context.Context, insteon.Group) error   { return nil }
+func (tl *testLinkable) ExitLinkingModeContext(context.Context) error                     { return nil }
+
 func TestFindDuplicateLinks(t *testing.T) {
 	links := []*insteon.LinkRecord{
 		{Flags: insteon.UnavailableController, Group: 1, Address: insteon.Address{1, 2, 3}},
@@ -79,3 +91,31 @@ func TestFindLinkRecord(t *testing.T) {
 		})
 	}
 }
+
+type testDeviceFinder map[insteon.Address]insteon.DeviceInfo
+
+func (tdf testDeviceFinder) Find(address insteon.Address) (info insteon.DeviceInfo, found bool) {
+	info, found = tdf[address]
+	return info, found
+}
+
+func TestPrintLinksWithDevices(t *testing.T) {
+	tl := &testLinkable{links: []*insteon.LinkRecord{
+		{Flags: insteon.UnavailableController, Group: 1, Address: insteon.Address{4, 5, 6}},
+		{Flags: insteon.UnavailableController, Group: 1, Address: insteon.Address{1, 2, 3}},
+	}}
+	devices := testDeviceFinder{
+		insteon.Address{1, 2, 3}: {Address: insteon.Address{1, 2, 3}, DevCat: insteon.DevCat{0x01, 0x20}},
+	}
+
+	want := "Link Database:\n" +
+		"    Flags Group Address    Data\n" +
+		fmt.Sprintf("    %-5s %5s %8s   00 00 00   2477D SwitchLinc Dimmer (Dual-Band)\n", insteon.UnavailableController, insteon.Group(1), insteon.Address{1, 2, 3}) +
+		fmt.Sprintf("    %-5s %5s %8s   00 00 00\n", insteon.UnavailableController, insteon.Group(1), insteon.Address{4, 5, 6})
+
+	buf := &bytes.Buffer{}
+	PrintLinksWithDevices(buf, tl, devices)
+	if got := buf.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}